@@ -0,0 +1,133 @@
+// Command workflow-e2e-cleanup deletes users, apps and certs that look like they were created by
+// workflow-e2e and are older than a given age, either by one run or by any run. It is meant for
+// long-lived clusters shared by several pipelines, where aborted runs leave things behind that
+// no single run's resource registry knows about.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/deis/workflow-e2e/tests/api"
+	"github.com/deis/workflow-e2e/tests/naming"
+)
+
+// orphan is a user, app or cert selected for deletion.
+type orphan struct {
+	kind    string
+	name    string
+	owner   string
+	created time.Time
+	del     func() error
+}
+
+func main() {
+	controllerURL := flag.String("controller", os.Getenv("DEIS_CONTROLLER_URL"), "URL of the Workflow controller")
+	username := flag.String("username", "admin", "username of an admin on the controller")
+	password := flag.String("password", "admin", "password of that admin")
+	age := flag.Duration("age", 24*time.Hour, "only delete things created at least this long ago")
+	dryRun := flag.Bool("dry-run", false, "list what would be deleted without deleting anything")
+	runID := flag.String("run", "", "only delete things created by the run with this ID (default: any run)")
+	flag.Parse()
+
+	if *controllerURL == "" {
+		fmt.Fprintln(os.Stderr, "Either -controller or DEIS_CONTROLLER_URL must be set")
+		os.Exit(2)
+	}
+	client, err := api.Login(*controllerURL, *username, *password)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not log in as %s (%s)\n", *username, err)
+		os.Exit(1)
+	}
+
+	orphans, err := find(client, time.Now().Add(-*age), *runID)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	failed := false
+	for _, o := range orphans {
+		desc := fmt.Sprintf("%s %s (owner %s, created %s)", o.kind, o.name, o.owner, o.created.Format(time.RFC3339))
+		if *dryRun {
+			fmt.Printf("would delete %s\n", desc)
+			continue
+		}
+		if err := o.del(); err != nil && !api.IsNotFound(err) {
+			fmt.Fprintf(os.Stderr, "could not delete %s (%s)\n", desc, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("deleted %s\n", desc)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// find returns the test certs, apps and users created before the cutoff by the given run, or by
+// any run if runID is empty, in the order in which they must be deleted.
+func find(client *api.Client, cutoff time.Time, runID string) ([]orphan, error) {
+	var orphans []orphan
+	// Users and apps are named test-<run ID>-<node>-<counter>, and certs the same with a "-cert"
+	// suffix (see tests/model).
+	testName := naming.Matcher("test", runID, "")
+	certName := naming.Matcher("test", runID, "-cert")
+
+	certs, err := client.Certs()
+	if err != nil {
+		return nil, err
+	}
+	for _, cert := range certs {
+		name := cert.Name
+		if !certName.MatchString(name) {
+			continue
+		}
+		if created, ok := olderThan(cert.Created, cutoff); ok {
+			orphans = append(orphans, orphan{"cert", name, cert.Owner, created, func() error { return client.DeleteCert(name) }})
+		}
+	}
+
+	apps, err := client.Apps()
+	if err != nil {
+		return nil, err
+	}
+	for _, app := range apps {
+		id := app.ID
+		if !testName.MatchString(id) {
+			continue
+		}
+		if created, ok := olderThan(app.Created, cutoff); ok {
+			orphans = append(orphans, orphan{"app", id, app.Owner, created, func() error { return client.DeleteApp(id) }})
+		}
+	}
+
+	users, err := client.Users()
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		username := user.Username
+		if !testName.MatchString(username) {
+			continue
+		}
+		if created, ok := olderThan(user.DateJoined, cutoff); ok {
+			orphans = append(orphans, orphan{"user", username, username, created, func() error { return client.Cancel(username) }})
+		}
+	}
+
+	return orphans, nil
+}
+
+// olderThan parses a timestamp returned by the controller and reports whether it is before the
+// cutoff. Timestamps that cannot be parsed are never considered old enough.
+func olderThan(timestamp string, cutoff time.Time) (time.Time, bool) {
+	t, err := api.ParseTime(timestamp)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: could not parse timestamp %q (%s), skipping\n", timestamp, err)
+		return t, false
+	}
+	return t, t.Before(cutoff)
+}
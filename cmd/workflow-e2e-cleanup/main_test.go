@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/deis/workflow-e2e/tests/api"
+	"github.com/deis/workflow-e2e/tests/fake"
+	"github.com/deis/workflow-e2e/tests/pki"
+)
+
+// cleanupTest is a fake controller holding users, apps and certs, some of them named the way the
+// tests name them.
+type cleanupTest struct {
+	t          *testing.T
+	controller *fake.Controller
+	admin      *api.Client
+}
+
+func newCleanupTest(t *testing.T) *cleanupTest {
+	c := &cleanupTest{t: t, controller: fake.NewController()}
+	if _, err := api.NewClient(c.controller.URL).Register("admin", "admin", "admin@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	if c.admin, err = api.Login(c.controller.URL, "admin", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// user registers a user and returns a client acting as it.
+func (c *cleanupTest) user(username string) *api.Client {
+	if _, err := c.admin.Register(username, username, username+"@example.com"); err != nil {
+		c.t.Fatal(err)
+	}
+	client, err := api.Login(c.controller.URL, username, username)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return client
+}
+
+func (c *cleanupTest) app(client *api.Client, id string) {
+	if _, err := client.CreateApp(id); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// cert adds a certificate as the client's user; the API client has no call for it.
+func (c *cleanupTest) cert(client *api.Client, name string) {
+	ca, err := pki.Default()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	leaf, err := ca.Issue(pki.Options{CommonName: "www.example.com", KeyType: pki.ECDSA})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	body, err := json.Marshal(map[string]string{"name": name, "certificate": string(leaf.CertPEM), "key": string(leaf.KeyPEM)})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	req, err := http.NewRequest("POST", c.controller.URL+"/v2/certs/", bytes.NewReader(body))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "token "+client.Token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		c.t.Fatalf("could not add cert %s: %s", name, resp.Status)
+	}
+}
+
+func describe(orphans []orphan) []string {
+	var descs []string
+	for _, o := range orphans {
+		descs = append(descs, fmt.Sprintf("%s %s of %s", o.kind, o.name, o.owner))
+	}
+	return descs
+}
+
+func TestFind(t *testing.T) {
+	c := newCleanupTest(t)
+	defer c.controller.Close()
+
+	// Resources from two runs, and some that merely look like them, created before the cutoff. An
+	// app named like one from run "abcd" is only found when looking for any run.
+	old := c.user("test-abc-1-1")
+	c.app(old, "test-abc-1-2")
+	c.cert(old, "test-abc-1-3-cert")
+	other := c.user("test-xyz-1-1")
+	c.app(other, "test-xyz-1-2")
+	c.cert(other, "test-xyz-1-3-cert")
+	bob := c.user("bob")
+	for _, id := range []string{"bobs-app", "test-abc-1-4-5", "my-test-abc-1-6", "test-abcd-1-7", "test-abc-1"} {
+		c.app(bob, id)
+	}
+	for _, name := range []string{"test-abc-1-8", "test-abc-1-9-cert-2", "bobs-cert"} {
+		c.cert(bob, name)
+	}
+	c.user("test-abc-1-10-admin")
+
+	// The controller records times to the second, so anything created more than a second after the
+	// cutoff is recorded as younger than it.
+	cutoff := time.Now()
+	time.Sleep(1100 * time.Millisecond)
+	young := c.user("test-abc-2-1")
+	c.app(young, "test-abc-2-2")
+	c.cert(young, "test-abc-2-3-cert")
+
+	tests := []struct {
+		runID    string
+		cutoff   time.Time
+		expected []string
+	}{
+		{"abc", cutoff, []string{
+			"cert test-abc-1-3-cert of test-abc-1-1",
+			"app test-abc-1-2 of test-abc-1-1",
+			"user test-abc-1-1 of test-abc-1-1",
+		}},
+		{"", cutoff, []string{
+			"cert test-abc-1-3-cert of test-abc-1-1",
+			"cert test-xyz-1-3-cert of test-xyz-1-1",
+			"app test-abc-1-2 of test-abc-1-1",
+			"app test-abcd-1-7 of bob",
+			"app test-xyz-1-2 of test-xyz-1-1",
+			"user test-abc-1-1 of test-abc-1-1",
+			"user test-xyz-1-1 of test-xyz-1-1",
+		}},
+		{"ab", cutoff, nil},
+		{"abc", cutoff.Add(-time.Hour), nil},
+		{"abc", time.Now().Add(time.Hour), []string{
+			"cert test-abc-1-3-cert of test-abc-1-1",
+			"cert test-abc-2-3-cert of test-abc-2-1",
+			"app test-abc-1-2 of test-abc-1-1",
+			"app test-abc-2-2 of test-abc-2-1",
+			"user test-abc-1-1 of test-abc-1-1",
+			"user test-abc-2-1 of test-abc-2-1",
+		}},
+	}
+	for _, test := range tests {
+		orphans, err := find(c.admin, test.cutoff, test.runID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := describe(orphans); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("run %q before %s: expected %q, got %q", test.runID, test.cutoff, test.expected, got)
+		}
+	}
+
+	// Deleting in the order found succeeds, as each user's certs and apps go before the user does.
+	orphans, err := find(c.admin, cutoff, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range orphans {
+		if err := o.del(); err != nil {
+			t.Fatalf("could not delete %s %s: %s", o.kind, o.name, err)
+		}
+	}
+	if orphans, err := find(c.admin, time.Now().Add(time.Hour), ""); err != nil || len(orphans) != 3 {
+		t.Errorf("expected only the young run's resources to be left, got %q (%v)", describe(orphans), err)
+	}
+	apps, err := c.admin.Apps()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(apps) != 5 {
+		t.Errorf("expected the rest of bob's apps and the young run's to be left, got %v", apps)
+	}
+}
+
+func TestOlderThan(t *testing.T) {
+	cutoff := time.Date(2016, 8, 1, 17, 38, 2, 0, time.UTC)
+	tests := []struct {
+		timestamp string
+		expected  bool
+	}{
+		{"2016-08-01T17:38:01UTC", true},
+		{"2016-08-01T17:38:01Z", true},
+		{"2016-08-01T17:38:02UTC", false},
+		{"2016-08-02T00:00:00Z", false},
+		{"", false},
+		{"yesterday", false},
+	}
+	for _, test := range tests {
+		if _, got := olderThan(test.timestamp, cutoff); got != test.expected {
+			t.Errorf("%q: expected %t, got %t", test.timestamp, test.expected, got)
+		}
+	}
+}
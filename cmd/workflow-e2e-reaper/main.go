@@ -0,0 +1,74 @@
+// Command workflow-e2e-reaper deletes the users, apps, domains, certs and keys recorded in the
+// resource registries left behind by workflow-e2e runs that did not clean up after themselves,
+// for example because they were killed before the end of the suite.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/deis/workflow-e2e/tests/api"
+	"github.com/deis/workflow-e2e/tests/naming"
+	"github.com/deis/workflow-e2e/tests/reaper"
+)
+
+func main() {
+	controllerURL := flag.String("controller", os.Getenv("DEIS_CONTROLLER_URL"), "URL of the Workflow controller")
+	username := flag.String("username", "admin", "username of an admin on the controller")
+	password := flag.String("password", "admin", "password of that admin")
+	dir := flag.String("dir", os.Getenv("HOME"), "directory holding the e2e-resources-*.json registries")
+	runID := flag.String("run", "", "only reap what the run with this ID left behind (default: any run)")
+	dryRun := flag.Bool("dry-run", false, "list what would be reaped without deleting anything")
+	flag.Parse()
+
+	if *runID != "" && !naming.ValidRunID(*runID) {
+		fmt.Fprintf(os.Stderr, "%q is not a valid run ID; run IDs match %s\n", *runID, naming.RunIDPattern)
+		os.Exit(2)
+	}
+	paths, err := reaper.Glob(*dir, *runID)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if len(paths) == 0 {
+		fmt.Printf("No resource registries found in %s\n", *dir)
+		return
+	}
+
+	var client *api.Client
+	if !*dryRun {
+		if *controllerURL == "" {
+			fmt.Fprintln(os.Stderr, "Either -controller or DEIS_CONTROLLER_URL must be set")
+			os.Exit(2)
+		}
+		if client, err = api.Login(*controllerURL, *username, *password); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not log in as %s (%s)\n", *username, err)
+			os.Exit(1)
+		}
+	}
+
+	failed := false
+	for _, path := range paths {
+		registry, err := reaper.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+			continue
+		}
+		if *dryRun {
+			for _, res := range registry.Resources() {
+				fmt.Printf("would reap %s (%s)\n", res, filepath.Base(path))
+			}
+			continue
+		}
+		if err := registry.Reap(client, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,40 @@
+package api
+
+import "net/http"
+
+// App is a Workflow application as returned by the controller.
+type App struct {
+	UUID      string         `json:"uuid"`
+	ID        string         `json:"id"`
+	Owner     string         `json:"owner"`
+	Structure map[string]int `json:"structure"`
+	URL       string         `json:"url"`
+	Created   string         `json:"created"`
+	Updated   string         `json:"updated"`
+}
+
+// CreateApp creates an app with the given name, without a git remote.
+func (c *Client) CreateApp(id string) (App, error) {
+	var app App
+	err := c.do("POST", "/v2/apps/", map[string]string{"id": id}, &app, http.StatusCreated)
+	return app, err
+}
+
+// App returns the named app.
+func (c *Client) App(id string) (App, error) {
+	var app App
+	err := c.do("GET", "/v2/apps/"+id+"/", nil, &app, http.StatusOK)
+	return app, err
+}
+
+// Apps lists every app the client's user can see.
+func (c *Client) Apps() ([]App, error) {
+	var apps []App
+	err := c.list("/v2/apps/", &apps)
+	return apps, err
+}
+
+// DeleteApp destroys the named app.
+func (c *Client) DeleteApp(id string) error {
+	return c.do("DELETE", "/v2/apps/"+id+"/", nil, nil, http.StatusNoContent)
+}
@@ -0,0 +1,35 @@
+package api
+
+import "net/http"
+
+// Build is a build of an app as returned by the controller.
+type Build struct {
+	UUID       string            `json:"uuid"`
+	Owner      string            `json:"owner"`
+	App        string            `json:"app"`
+	Image      string            `json:"image"`
+	Sha        string            `json:"sha"`
+	Procfile   map[string]string `json:"procfile"`
+	Dockerfile string            `json:"dockerfile"`
+	Created    string            `json:"created"`
+	Updated    string            `json:"updated"`
+}
+
+// CreateBuild deploys the given image to the named app, as `deis builds:create` does. The
+// procfile may be nil.
+func (c *Client) CreateBuild(appID, image string, procfile map[string]string) (Build, error) {
+	body := map[string]interface{}{"image": image}
+	if procfile != nil {
+		body["procfile"] = procfile
+	}
+	var build Build
+	err := c.do("POST", "/v2/apps/"+appID+"/builds/", body, &build, http.StatusCreated)
+	return build, err
+}
+
+// Builds lists the builds of the named app, newest first.
+func (c *Client) Builds(appID string) ([]Build, error) {
+	var builds []Build
+	err := c.list("/v2/apps/"+appID+"/builds/", &builds)
+	return builds, err
+}
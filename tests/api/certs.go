@@ -0,0 +1,31 @@
+package api
+
+import "net/http"
+
+// Cert is a certificate as returned by the controller.
+type Cert struct {
+	Name        string   `json:"name"`
+	Owner       string   `json:"owner"`
+	CommonName  string   `json:"common_name"`
+	SubjectAlt  []string `json:"san"`
+	Fingerprint string   `json:"fingerprint"`
+	Issuer      string   `json:"issuer"`
+	Subject     string   `json:"subject"`
+	Starts      string   `json:"starts"`
+	Expires     string   `json:"expires"`
+	Domains     []string `json:"domains"`
+	Created     string   `json:"created"`
+	Updated     string   `json:"updated"`
+}
+
+// Certs lists every certificate the client's user can see.
+func (c *Client) Certs() ([]Cert, error) {
+	var certs []Cert
+	err := c.list("/v2/certs/", &certs)
+	return certs, err
+}
+
+// DeleteCert removes the named certificate.
+func (c *Client) DeleteCert(name string) error {
+	return c.do("DELETE", "/v2/certs/"+name+"/", nil, nil, http.StatusNoContent)
+}
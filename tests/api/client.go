@@ -0,0 +1,232 @@
+package api
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The code in this package implements a small, typed client for the parts of the Workflow
+// controller's v2 API that test fixtures need. Fixtures use it to arrange and clean up state
+// without shelling out to `deis`, so that the CLI is only exercised by the specs themselves and a
+// failing fixture cannot be mistaken for a failing CLI command.
+
+// DefaultResponseLimit is the page size requested from list endpoints, and the response limit
+// written to profiles.
+const DefaultResponseLimit = 100
+
+// Client talks to a controller on behalf of a single user.
+type Client struct {
+	ControllerURL string
+	Username      string
+	Token         string
+	SSLVerify     bool
+}
+
+// Error is returned whenever the controller answers with an unexpected status code.
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Body       string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.StatusCode, strings.TrimSpace(e.Body))
+}
+
+// IsNotFound returns true if err is an *Error for a 404 response.
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(*Error)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+// timeLayouts are the ways the controller formats timestamps, both in its responses and, through
+// the CLI, in the output of `deis` commands.
+var timeLayouts = []string{"2006-01-02T15:04:05MST", time.RFC3339Nano}
+
+// ParseTime parses a timestamp as formatted by the controller, e.g. "2016-08-01T17:38:02UTC".
+func ParseTime(value string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time %q", value)
+}
+
+// profile mirrors the JSON written by `deis auth:login` to $HOME/.deis/<profile>.json.
+type profile struct {
+	Username      string `json:"username"`
+	SSLVerify     bool   `json:"ssl_verify"`
+	Controller    string `json:"controller"`
+	Token         string `json:"token"`
+	ResponseLimit int    `json:"response_limit"`
+}
+
+// NewClient returns an unauthenticated client for the controller at the given URL.
+func NewClient(controllerURL string) *Client {
+	return &Client{ControllerURL: strings.TrimSuffix(controllerURL, "/"), SSLVerify: true}
+}
+
+// Login authenticates against the controller at the given URL and returns a client acting as
+// that user.
+func Login(controllerURL, username, password string) (*Client, error) {
+	c := NewClient(controllerURL)
+	body := map[string]string{"username": username, "password": password}
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if err := c.do("POST", "/v2/auth/login/", body, &resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+	c.Username = username
+	c.Token = resp.Token
+	return c, nil
+}
+
+// LoadProfile returns a client for the named CLI profile, as written by `deis auth:login` or by
+// SaveProfile.
+func LoadProfile(name string) (*Client, error) {
+	data, err := ioutil.ReadFile(profilePath(name))
+	if err != nil {
+		return nil, err
+	}
+	var p profile
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("could not parse profile %s (%s)", name, err)
+	}
+	return &Client{ControllerURL: p.Controller, Username: p.Username, Token: p.Token, SSLVerify: p.SSLVerify}, nil
+}
+
+// SaveProfile writes the client's credentials to the named CLI profile, so that `deis` commands
+// run with DEIS_PROFILE=name act as the same user.
+func (c *Client) SaveProfile(name string) error {
+	data, err := json.Marshal(profile{
+		Username:      c.Username,
+		SSLVerify:     c.SSLVerify,
+		Controller:    c.ControllerURL,
+		Token:         c.Token,
+		ResponseLimit: DefaultResponseLimit,
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(profilePath(name)), 0775); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(profilePath(name), data, 0600)
+}
+
+// RemoveProfile deletes the named CLI profile, if it exists.
+func RemoveProfile(name string) error {
+	if err := os.Remove(profilePath(name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func profilePath(name string) string {
+	return filepath.Join(os.Getenv("HOME"), ".deis", name+".json")
+}
+
+// do sends a request to the controller, encoding body as JSON if it is not nil and decoding the
+// response into out if it is not nil. Any status other than the expected one is an *Error.
+func (c *Client) do(method, path string, body, out interface{}, expected int) error {
+	data, err := c.request(method, c.ControllerURL+path, body, expected)
+	if err != nil {
+		return err
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("%s %s: could not parse response (%s)", method, path, err)
+	}
+	return nil
+}
+
+// list fetches every page of a list endpoint and decodes the combined results into out, which
+// must be a pointer to a slice.
+func (c *Client) list(path string, out interface{}) error {
+	var results []json.RawMessage
+	next := fmt.Sprintf("%s%s?limit=%d", c.ControllerURL, path, DefaultResponseLimit)
+	for next != "" {
+		data, err := c.request("GET", next, nil, http.StatusOK)
+		if err != nil {
+			return err
+		}
+		var page struct {
+			Next    *string           `json:"next"`
+			Results []json.RawMessage `json:"results"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return fmt.Errorf("GET %s: could not parse response (%s)", path, err)
+		}
+		results = append(results, page.Results...)
+		next = ""
+		if page.Next != nil {
+			next = *page.Next
+		}
+	}
+	if results == nil {
+		results = []json.RawMessage{}
+	}
+	data, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+func (c *Client) request(method, rawURL string, body interface{}, expected int) ([]byte, error) {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequest(method, rawURL, &reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "token "+c.Token)
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != expected {
+		path := rawURL
+		if u, err := url.Parse(rawURL); err == nil {
+			path = u.Path
+		}
+		return nil, &Error{Method: method, Path: path, StatusCode: resp.StatusCode, Body: string(data)}
+	}
+	return data, nil
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.SSLVerify {
+		return http.DefaultClient
+	}
+	return &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+}
@@ -0,0 +1,222 @@
+// The fake controller is built on this package, so these tests live outside of it.
+package api_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/deis/workflow-e2e/tests/api"
+	"github.com/deis/workflow-e2e/tests/fake"
+)
+
+func TestLogin(t *testing.T) {
+	controller := fake.NewController()
+	defer controller.Close()
+	if _, err := api.NewClient(controller.URL+"/").Register("admin", "secret", "admin@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := api.Login(controller.URL, "admin", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Username != "admin" || client.Token == "" {
+		t.Errorf("expected a client for admin with a token, got %+v", client)
+	}
+	user, err := client.Whoami()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Username != "admin" || !user.IsSuperuser {
+		t.Errorf("expected the first user to be an admin, got %+v", user)
+	}
+
+	_, err = api.Login(controller.URL, "admin", "wrong")
+	apiErr, ok := err.(*api.Error)
+	if !ok || apiErr.Method != "POST" || apiErr.Path != "/v2/auth/login/" || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected a 400 from POST /v2/auth/login/, got %#v", err)
+	}
+	expected := "POST /v2/auth/login/: 400 " + `{"non_field_errors":["Unable to log in with provided credentials."]}`
+	if apiErr.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, apiErr.Error())
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	controller := fake.NewController()
+	defer controller.Close()
+	if _, err := api.NewClient(controller.URL).Register("admin", "secret", "admin@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	client, err := api.Login(controller.URL, "admin", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, missing := client.App("no-such-app")
+	unauthorized := api.NewClient(controller.URL).DeleteApp("no-such-app")
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{missing, true},
+		{client.DeleteApp("no-such-app"), true},
+		{unauthorized, false},
+		{&api.Error{StatusCode: http.StatusNotFound}, true},
+		{fmt.Errorf("404 Not Found"), false},
+		{nil, false},
+	}
+	for _, test := range tests {
+		if got := api.IsNotFound(test.err); got != test.expected {
+			t.Errorf("%v: expected %t, got %t", test.err, test.expected, got)
+		}
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	home, err := ioutil.TempDir("", "api-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+
+	client := &api.Client{ControllerURL: "http://deis.example.com", Username: "admin", Token: "abc123"}
+	if err := client.SaveProfile("admin"); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := api.LoadProfile("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, client) {
+		t.Errorf("expected %+v, got %+v", client, loaded)
+	}
+
+	// Profiles written by `deis auth:login` load the same way.
+	path := filepath.Join(home, ".deis", "cli.json")
+	cli := `{"username":"bob","ssl_verify":true,"controller":"https://deis.example.com","token":"def456","response_limit":100}`
+	if err := ioutil.WriteFile(path, []byte(cli), 0600); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err = api.LoadProfile("cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &api.Client{ControllerURL: "https://deis.example.com", Username: "bob", Token: "def456", SSLVerify: true}
+	if !reflect.DeepEqual(loaded, expected) {
+		t.Errorf("expected %+v, got %+v", expected, loaded)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.LoadProfile("cli"); err == nil {
+		t.Error("expected a malformed profile not to load")
+	}
+	if err := api.RemoveProfile("admin"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.LoadProfile("admin"); !os.IsNotExist(err) {
+		t.Errorf("expected a removed profile not to exist, got %v", err)
+	}
+	if err := api.RemoveProfile("admin"); err != nil {
+		t.Errorf("expected removing a missing profile to succeed, got %s", err)
+	}
+}
+
+func TestListFollowsPages(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token abc123" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// Serve three apps, two to a page, linking to the next page as the controller does.
+		page := map[string]interface{}{"count": 3, "previous": nil}
+		switch r.URL.Query().Get("offset") {
+		case "":
+			if r.URL.Query().Get("limit") != fmt.Sprint(api.DefaultResponseLimit) {
+				t.Errorf("expected a limit of %d, got %s", api.DefaultResponseLimit, r.URL)
+			}
+			page["results"] = []api.App{{ID: "app-1"}, {ID: "app-2"}}
+			page["next"] = server.URL + r.URL.Path + "?limit=2&offset=2"
+		case "2":
+			page["results"] = []api.App{{ID: "app-3"}}
+			page["next"] = nil
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+	defer server.Close()
+
+	client := &api.Client{ControllerURL: server.URL, Token: "abc123"}
+	apps, err := client.Apps()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, app := range apps {
+		ids = append(ids, app.ID)
+	}
+	if expected := []string{"app-1", "app-2", "app-3"}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected %v, got %v", expected, ids)
+	}
+
+	client.Token = "wrong"
+	if _, err := client.Apps(); err == nil || err.(*api.Error).StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected a 401, got %v", err)
+	}
+}
+
+func TestListWithoutResults(t *testing.T) {
+	controller := fake.NewController()
+	defer controller.Close()
+	if _, err := api.NewClient(controller.URL).Register("admin", "secret", "admin@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	client, err := api.Login(controller.URL, "admin", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	apps, err := client.Apps()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if apps == nil || len(apps) != 0 {
+		t.Errorf("expected an empty list of apps, got %#v", apps)
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Time
+		ok       bool
+	}{
+		{"2016-08-01T17:38:02UTC", time.Date(2016, 8, 1, 17, 38, 2, 0, time.UTC), true},
+		{"2016-08-01T17:38:02Z", time.Date(2016, 8, 1, 17, 38, 2, 0, time.UTC), true},
+		{"2016-08-01T17:38:02.123456Z", time.Date(2016, 8, 1, 17, 38, 2, 123456000, time.UTC), true},
+		{"2016-08-01T19:38:02+02:00", time.Date(2016, 8, 1, 17, 38, 2, 0, time.UTC), true},
+		{"2016-08-01 17:38:02", time.Time{}, false},
+		{"", time.Time{}, false},
+	}
+	for _, test := range tests {
+		got, err := api.ParseTime(test.value)
+		if (err == nil) != test.ok {
+			t.Errorf("%q: expected ok to be %t, got %v", test.value, test.ok, err)
+			continue
+		}
+		if !got.Equal(test.expected) {
+			t.Errorf("%q: expected %s, got %s", test.value, test.expected, got)
+		}
+	}
+}
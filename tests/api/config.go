@@ -0,0 +1,34 @@
+package api
+
+import "net/http"
+
+// Config is the configuration of an app as returned by the controller.
+type Config struct {
+	UUID        string                 `json:"uuid"`
+	Owner       string                 `json:"owner"`
+	App         string                 `json:"app"`
+	Values      map[string]interface{} `json:"values"`
+	Memory      map[string]interface{} `json:"memory"`
+	CPU         map[string]interface{} `json:"cpu"`
+	Tags        map[string]interface{} `json:"tags"`
+	Registry    map[string]interface{} `json:"registry"`
+	Healthcheck map[string]interface{} `json:"healthcheck"`
+	Created     string                 `json:"created"`
+	Updated     string                 `json:"updated"`
+}
+
+// Config returns the current configuration of the named app.
+func (c *Client) Config(appID string) (Config, error) {
+	var config Config
+	err := c.do("GET", "/v2/apps/"+appID+"/config/", nil, &config, http.StatusOK)
+	return config, err
+}
+
+// SetConfig merges the given environment variables into the named app's configuration, creating
+// a new release. A nil value unsets that variable.
+func (c *Client) SetConfig(appID string, values map[string]interface{}) (Config, error) {
+	body := map[string]interface{}{"values": values}
+	var config Config
+	err := c.do("POST", "/v2/apps/"+appID+"/config/", body, &config, http.StatusCreated)
+	return config, err
+}
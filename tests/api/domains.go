@@ -0,0 +1,8 @@
+package api
+
+import "net/http"
+
+// DeleteDomain removes the given domain from the named app.
+func (c *Client) DeleteDomain(appID, domain string) error {
+	return c.do("DELETE", "/v2/apps/"+appID+"/domains/"+domain+"/", nil, nil, http.StatusNoContent)
+}
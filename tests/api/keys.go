@@ -0,0 +1,33 @@
+package api
+
+import "net/http"
+
+// Key is an SSH public key as returned by the controller.
+type Key struct {
+	UUID    string `json:"uuid"`
+	ID      string `json:"id"`
+	Owner   string `json:"owner"`
+	Public  string `json:"public"`
+	Created string `json:"created"`
+	Updated string `json:"updated"`
+}
+
+// CreateKey adds a public key, in authorized_keys format, to the client's user.
+func (c *Client) CreateKey(id, public string) (Key, error) {
+	body := map[string]string{"id": id, "public": public}
+	var key Key
+	err := c.do("POST", "/v2/keys/", body, &key, http.StatusCreated)
+	return key, err
+}
+
+// Keys lists the client's user's keys.
+func (c *Client) Keys() ([]Key, error) {
+	var keys []Key
+	err := c.list("/v2/keys/", &keys)
+	return keys, err
+}
+
+// DeleteKey removes the key with the given id.
+func (c *Client) DeleteKey(id string) error {
+	return c.do("DELETE", "/v2/keys/"+id+"/", nil, nil, http.StatusNoContent)
+}
@@ -0,0 +1,17 @@
+package api
+
+// Pod is a process of an app as returned by the controller.
+type Pod struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	State   string `json:"state"`
+	Release string `json:"release"`
+	Started string `json:"started"`
+}
+
+// Pods lists the processes of the named app.
+func (c *Client) Pods(appID string) ([]Pod, error) {
+	var pods []Pod
+	err := c.list("/v2/apps/"+appID+"/pods/", &pods)
+	return pods, err
+}
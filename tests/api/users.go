@@ -0,0 +1,49 @@
+package api
+
+import "net/http"
+
+// User is a Workflow user as returned by the controller.
+type User struct {
+	Username    string `json:"username"`
+	Email       string `json:"email"`
+	FirstName   string `json:"first_name"`
+	LastName    string `json:"last_name"`
+	LastLogin   string `json:"last_login"`
+	IsSuperuser bool   `json:"is_superuser"`
+	IsStaff     bool   `json:"is_staff"`
+	IsActive    bool   `json:"is_active"`
+	DateJoined  string `json:"date_joined"`
+}
+
+// Register creates a new user. Unless the controller has no users yet, the client must belong to
+// an admin.
+func (c *Client) Register(username, password, email string) (User, error) {
+	body := map[string]string{"username": username, "password": password, "email": email}
+	var user User
+	err := c.do("POST", "/v2/auth/register/", body, &user, http.StatusCreated)
+	return user, err
+}
+
+// Cancel deletes the account of the named user, or of the client's own user if username is
+// empty. Only admins may cancel other users' accounts.
+func (c *Client) Cancel(username string) error {
+	var body map[string]string
+	if username != "" {
+		body = map[string]string{"username": username}
+	}
+	return c.do("DELETE", "/v2/auth/cancel/", body, nil, http.StatusNoContent)
+}
+
+// Whoami returns the client's own user.
+func (c *Client) Whoami() (User, error) {
+	var user User
+	err := c.do("GET", "/v2/auth/whoami/", nil, &user, http.StatusOK)
+	return user, err
+}
+
+// Users lists every user. The client must belong to an admin.
+func (c *Client) Users() ([]User, error) {
+	var users []User
+	err := c.list("/v2/users/", &users)
+	return users, err
+}
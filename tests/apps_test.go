@@ -9,6 +9,7 @@ import (
 	"github.com/deis/workflow-e2e/tests/cmd/apps"
 	"github.com/deis/workflow-e2e/tests/cmd/auth"
 	"github.com/deis/workflow-e2e/tests/cmd/builds"
+	"github.com/deis/workflow-e2e/tests/fixtures"
 	"github.com/deis/workflow-e2e/tests/model"
 	"github.com/deis/workflow-e2e/tests/settings"
 	"github.com/deis/workflow-e2e/tests/util"
@@ -34,7 +35,13 @@ var _ = Describe("deis apps", func() {
 		})
 
 		Specify("that user can create an app without a git remote", func() {
-			app := apps.Create(user, "--no-remote")
+			// Always use the CLI here, even when fixtures use the API; this is the command under test.
+			app := model.NewApp()
+			sess, err := cmd.Start("deis apps:create %s --no-remote", &user, app.Name)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(sess).Should(Say("created %s", app.Name))
+			Eventually(sess).Should(Say("If you want to add a git remote for this app later, use "))
+			Eventually(sess).Should(Exit(0))
 			apps.Destroy(user, app)
 		})
 
@@ -133,13 +140,14 @@ var _ = Describe("deis apps", func() {
 
 		Context("who has a local git repo containing source code", func() {
 
+			var dir string
+
 			BeforeEach(func() {
-				output, err := cmd.Execute(`git clone https://github.com/deis/example-go.git`)
-				Expect(err).NotTo(HaveOccurred(), output)
+				dir, _ = fixtures.Repo(fixtures.Go)
 			})
 
 			Specify("that user can create an app with a git remote", func() {
-				os.Chdir("example-go")
+				os.Chdir(dir)
 				app := apps.Create(user)
 				apps.Destroy(user, app)
 			})
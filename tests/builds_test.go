@@ -5,6 +5,7 @@ import (
 	"github.com/deis/workflow-e2e/tests/cmd/apps"
 	"github.com/deis/workflow-e2e/tests/cmd/auth"
 	"github.com/deis/workflow-e2e/tests/cmd/builds"
+	"github.com/deis/workflow-e2e/tests/cmd/ps"
 	"github.com/deis/workflow-e2e/tests/model"
 	"github.com/deis/workflow-e2e/tests/settings"
 	"github.com/deis/workflow-e2e/tests/util"
@@ -64,7 +65,11 @@ var _ = Describe("deis builds", func() {
 			})
 
 			Specify("that user can create a new build of that app from an existing image", func() {
-				builds.Create(user, app)
+				// Always use the CLI here, even when fixtures use the API; this is the command under test.
+				sess, err := cmd.Start("deis builds:create --app=%s %s", &user, app.Name, builds.ExampleImage)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(sess).Should(Say("Creating build..."))
+				Eventually(sess, settings.MaxEventuallyTimeout).Should(Exit(0))
 			})
 
 			Specify("that user can create a new build of that app from an existing image using `deis pull`", func() {
@@ -89,9 +94,9 @@ var _ = Describe("deis builds", func() {
 				Eventually(sess, settings.MaxEventuallyTimeout).Should(Exit(1))
 
 				// test that the old rc is not deleted after a failed build
-				procsListing := listProcs(user, app, "").Out.Contents()
-				procs := scrapeProcs(app.Name, procsListing)
-				Expect(len(procs)).To(Equal(1))
+				procs := ps.List(user, app)
+				Expect(procs).To(HaveLen(1))
+				Expect(procs[0].State).To(Equal("up"))
 			})
 
 			Specify("that user can create multiple builds of that app with DEPLOY_BATCHES set to 5", func() {
@@ -139,7 +144,11 @@ var _ = Describe("deis builds", func() {
 			})
 
 			Specify("that user can create a new build of that app from an existing image", func() {
-				builds.Create(user, app)
+				// Always use the CLI here, even when fixtures use the API; this is the command under test.
+				sess, err := cmd.Start("deis builds:create --app=%s %s", &user, app.Name, builds.ExampleImage)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(sess).Should(Say("Creating build..."))
+				Eventually(sess, settings.MaxEventuallyTimeout).Should(Exit(0))
 			})
 
 			Specify("that user can create a new build of that app from an existing image using `deis pull`", func() {
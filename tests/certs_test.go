@@ -1,9 +1,6 @@
 package tests
 
 import (
-	"fmt"
-	"net/http"
-	"strconv"
 	"strings"
 
 	deis "github.com/deis/controller-sdk-go"
@@ -13,10 +10,14 @@ import (
 	"github.com/deis/workflow-e2e/tests/cmd/builds"
 	"github.com/deis/workflow-e2e/tests/cmd/certs"
 	"github.com/deis/workflow-e2e/tests/cmd/domains"
+	"github.com/deis/workflow-e2e/tests/http"
 	"github.com/deis/workflow-e2e/tests/model"
+	"github.com/deis/workflow-e2e/tests/pki"
+	"github.com/deis/workflow-e2e/tests/settings"
 	"github.com/deis/workflow-e2e/tests/util"
 
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 	. "github.com/onsi/gomega/gbytes"
 	. "github.com/onsi/gomega/gexec"
@@ -29,7 +30,7 @@ var _ = Describe("deis certs", func() {
 	var cert model.Cert
 
 	BeforeEach(func() {
-		cert = model.NewCert()
+		cert = newCert(pki.Options{CommonName: "www.foo.com"})
 	})
 
 	Context("with an existing user", func() {
@@ -76,6 +77,29 @@ var _ = Describe("deis certs", func() {
 			Eventually(sess).Should(Exit(1))
 		})
 
+		Specify("that user cannot add an expired cert", func() {
+			expired := newCert(pki.Options{CommonName: "www.foo.com"}.Expired())
+			sess, err := cmd.Start("deis certs:add %s %s %s", &user, expired.Name, expired.CertPath, expired.KeyPath)
+			Eventually(sess.Err, settings.MaxEventuallyTimeout).Should(Say("expired"))
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(sess).Should(Exit(1))
+			Expect(certs.List(user).Out.Contents()).NotTo(ContainSubstring(expired.Name))
+		})
+
+		DescribeTable("that user can add a cert and get info on it",
+			func(opts pki.Options) {
+				cert := newCert(opts)
+				certs.Add(user, cert)
+				defer certs.Remove(user, cert)
+				certs.ExpectMatchesFile(certs.Info(user, cert), cert)
+			},
+			Entry("with an RSA key", pki.Options{CommonName: "www.foo.com"}),
+			Entry("with a 4096-bit RSA key", pki.Options{CommonName: "www.foo.com", Bits: 4096}),
+			Entry("with an ECDSA key", pki.Options{CommonName: "www.foo.com", KeyType: pki.ECDSA}),
+			Entry("for a wildcard domain", pki.Options{CommonName: "*.foo.com", DNSNames: []string{"*.foo.com", "foo.com"}}),
+			Entry("with several subject alternative names", pki.Options{CommonName: "foo.com", DNSNames: []string{"foo.com", "www.foo.com", "api.foo.com"}}),
+		)
+
 		Specify("that user cannot get info on a non-existent cert", func() {
 			sess, err := cmd.Start("deis certs:info %s", &user, nonExistentCertName)
 			Eventually(sess.Err).Should(Say(util.PrependError(certs.ErrNoCertMatch)))
@@ -145,6 +169,13 @@ var _ = Describe("deis certs", func() {
 				certs.Remove(user, cert)
 			})
 
+			Specify("that user can get info on that cert", func() {
+				info := certs.Info(user, cert)
+				certs.ExpectMatchesFile(info, cert)
+				Expect(info.Owner).To(Equal(user.Username))
+				Expect(info.Domains).To(BeEmpty())
+			})
+
 			Specify("that user cannot attach a cert to a non-existent domain", func() {
 				sess, err := cmd.Start("deis certs:attach %s %s", &user, cert.Name, nonExistentDomain)
 				Eventually(sess.Err).Should(Say(util.PrependError(domains.ErrNoDomainMatch)))
@@ -166,8 +197,8 @@ var _ = Describe("deis certs", func() {
 			var cert1, cert2 model.Cert
 
 			BeforeEach(func() {
-				cert1 = model.NewCert()
-				cert2 = model.NewCert()
+				cert1 = newCert(pki.Options{CommonName: "www.foo.com"})
+				cert2 = newCert(pki.Options{CommonName: "www.foo.com"})
 				certs.Add(user, cert1)
 				certs.Add(user, cert2)
 			})
@@ -240,9 +271,17 @@ var _ = Describe("deis certs", func() {
 
 					Specify("that user can attach/detach that cert to/from that domain", func() {
 						certs.Attach(user, cert, domain)
-						curlCmd := model.Cmd{CommandLineString: fmt.Sprintf(`curl -k -H "Host: %s" -sL -w "%%{http_code}\\n" "%s" -o /dev/null`, domain, app.URL)}
-						Eventually(cmd.Retry(curlCmd, strconv.Itoa(http.StatusOK), 60)).Should(BeTrue())
+						certs.ExpectAttached(user, cert, domain)
+						Expect(domains.List(user, app)).To(ContainElement(domain))
+						http.Probe{URL: app.URL, Host: domain, FollowRedirects: true, InsecureSkipVerify: true}.Until(
+							settings.MaxEventuallyTimeout, http.RespondWith(200))
+						served := http.TLSProbe{URL: app.URL, ServerName: domain}
+						served.Until(settings.MaxEventuallyTimeout, http.PresentCertificate(cert))
 						certs.Detach(user, cert, domain)
+						certs.ExpectAttached(user, cert)
+						// The router should still complete the handshake, with its default certificate.
+						served.Until(settings.MaxEventuallyTimeout, http.CompleteHandshake(), Not(http.PresentCertificate(cert)))
+						Expect(domains.List(user, app)).To(ContainElement(domain))
 					})
 
 				})
@@ -254,3 +293,10 @@ var _ = Describe("deis certs", func() {
 	})
 
 })
+
+// newCert issues a cert with the run's throwaway CA.
+func newCert(opts pki.Options) model.Cert {
+	cert, err := pki.NewCert(opts)
+	Expect(err).NotTo(HaveOccurred())
+	return cert
+}
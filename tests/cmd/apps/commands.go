@@ -9,8 +9,10 @@ import (
 	"strings"
 
 	"github.com/deis/workflow-e2e/shims"
+	"github.com/deis/workflow-e2e/tests/api"
 	"github.com/deis/workflow-e2e/tests/cmd"
 	"github.com/deis/workflow-e2e/tests/model"
+	"github.com/deis/workflow-e2e/tests/reaper"
 	"github.com/deis/workflow-e2e/tests/settings"
 
 	. "github.com/onsi/gomega"
@@ -24,7 +26,12 @@ var ErrNoAppMatch = errors.New("\"No App matches the given query.\"")
 // This allows each of these to be re-used easily in multiple contexts.
 
 // Create executes `deis apps:create` as the specified user with the specified, arvitrary options.
+// If settings.APIFixtures is set and the only option is --no-remote, the app is created through
+// the API instead.
 func Create(user model.User, options ...string) model.App {
+	if settings.APIFixtures && len(options) == 1 && options[0] == "--no-remote" {
+		return createWithAPI(user)
+	}
 	noRemote := false
 	app := model.NewApp()
 	sess, err := cmd.Start("deis apps:create %s %s", &user, app.Name, strings.Join(options, " "))
@@ -45,6 +52,7 @@ func Create(user model.User, options ...string) model.App {
 		Eventually(sess).Should(Say("Git remote deis successfully created for app"))
 	}
 	Eventually(sess).Should(Exit(0))
+	Expect(reaper.Track(reaper.Resource{Kind: reaper.App, Name: app.Name})).To(Succeed())
 	return app
 }
 
@@ -83,5 +91,16 @@ func Destroy(user model.User, app model.App) *Session {
 	Eventually(sess).Should(Say("Destroying %s...", app.Name))
 	Eventually(sess).Should(Say(`done in `))
 	Eventually(sess).Should(Exit(0))
+	Expect(reaper.Untrack(reaper.Resource{Kind: reaper.App, Name: app.Name})).To(Succeed())
 	return sess
 }
+
+func createWithAPI(user model.User) model.App {
+	client, err := api.LoadProfile(user.Username)
+	Expect(err).NotTo(HaveOccurred(), "fixture: loading profile for %s", user.Username)
+	app := model.NewApp()
+	_, err = client.CreateApp(app.Name)
+	Expect(err).NotTo(HaveOccurred(), "fixture: creating app %s", app.Name)
+	Expect(reaper.Track(reaper.Resource{Kind: reaper.App, Name: app.Name})).To(Succeed())
+	return app
+}
@@ -3,8 +3,11 @@ package auth
 import (
 	"fmt"
 
+	"github.com/deis/workflow-e2e/tests/api"
 	"github.com/deis/workflow-e2e/tests/cmd"
+	"github.com/deis/workflow-e2e/tests/cmd/keys"
 	"github.com/deis/workflow-e2e/tests/model"
+	"github.com/deis/workflow-e2e/tests/reaper"
 	"github.com/deis/workflow-e2e/tests/settings"
 
 	. "github.com/onsi/gomega"
@@ -56,6 +59,7 @@ func Register() model.User {
 	Eventually(sess).Should(Exit(0))
 	Expect(err).NotTo(HaveOccurred())
 	Eventually(sess).Should(Say(fmt.Sprintf("Registered %s\n", user.Username)))
+	Expect(reaper.Track(reaper.Resource{Kind: reaper.User, Name: user.Username})).To(Succeed())
 
 	return user
 }
@@ -73,7 +77,11 @@ func Login(user model.User) {
 }
 
 // RegisterAndLogin registers a user using a randomized username and then logs in as the registered user.
+// If settings.APIFixtures is set, this is done through the API rather than the CLI.
 func RegisterAndLogin() model.User {
+	if settings.APIFixtures {
+		return registerAndLoginWithAPI()
+	}
 	user := Register()
 	Login(user)
 	return user
@@ -116,13 +124,21 @@ func Logout(user model.User) {
 	Eventually(sess).Should(Say("Logged out\n"))
 }
 
-// Cancel executes `deis auth:cancel` as the specified user.
+// Cancel executes `deis auth:cancel` as the specified user. If settings.APIFixtures is set, the
+// account is cancelled through the API instead.
 func Cancel(user model.User) {
-	sess, err := cmd.Start("deis auth:cancel --username=%s --password=%s --yes", &user, user.Username, user.Password)
-	Expect(err).To(BeNil())
-	Eventually(sess).Should(Exit(0))
-	Expect(err).NotTo(HaveOccurred())
-	Eventually(sess).Should(Say("Account cancelled\n"))
+	if settings.APIFixtures {
+		cancelWithAPI(user)
+	} else {
+		sess, err := cmd.Start("deis auth:cancel --username=%s --password=%s --yes", &user, user.Username, user.Password)
+		Expect(err).To(BeNil())
+		Eventually(sess).Should(Exit(0))
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(sess).Should(Say("Account cancelled\n"))
+	}
+	Expect(reaper.Untrack(reaper.Resource{Kind: reaper.User, Name: user.Username})).To(Succeed())
+	// Cancelling the account removed the user's keys, so only the key material is left.
+	keys.Discard(user)
 }
 
 // CancelAdmin deletes the admin user that was created to facilitate the tests.
@@ -134,3 +150,29 @@ func CancelAdmin() {
 	Expect(err).NotTo(HaveOccurred())
 	Eventually(sess).Should(Say("Account cancelled\n"))
 }
+
+// registerAndLoginWithAPI registers a user with a randomized username as the admin and writes a
+// profile for that user, just as `deis auth:login` would have.
+func registerAndLoginWithAPI() model.User {
+	admin, err := api.Login(settings.DeisControllerURL, model.Admin.Username, model.Admin.Password)
+	Expect(err).NotTo(HaveOccurred(), "fixture: logging in as %s", model.Admin.Username)
+
+	user := model.NewUser()
+	_, err = admin.Register(user.Username, user.Password, user.Email)
+	Expect(err).NotTo(HaveOccurred(), "fixture: registering %s", user.Username)
+	Expect(reaper.Track(reaper.Resource{Kind: reaper.User, Name: user.Username})).To(Succeed())
+
+	client, err := api.Login(settings.DeisControllerURL, user.Username, user.Password)
+	Expect(err).NotTo(HaveOccurred(), "fixture: logging in as %s", user.Username)
+	Expect(client.SaveProfile(user.Username)).To(Succeed(), "fixture: saving profile for %s", user.Username)
+	return user
+}
+
+// cancelWithAPI cancels the user's account and removes their profile, just as `deis auth:cancel`
+// would have.
+func cancelWithAPI(user model.User) {
+	client, err := api.Login(settings.DeisControllerURL, user.Username, user.Password)
+	Expect(err).NotTo(HaveOccurred(), "fixture: logging in as %s", user.Username)
+	Expect(client.Cancel("")).To(Succeed(), "fixture: cancelling %s", user.Username)
+	Expect(api.RemoveProfile(user.Username)).To(Succeed(), "fixture: removing profile for %s", user.Username)
+}
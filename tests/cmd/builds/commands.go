@@ -1,8 +1,12 @@
 package builds
 
 import (
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/deis/workflow-e2e/tests/api"
 	"github.com/deis/workflow-e2e/tests/cmd"
 	"github.com/deis/workflow-e2e/tests/model"
 	"github.com/deis/workflow-e2e/tests/settings"
@@ -17,11 +21,45 @@ import (
 
 const ExampleImage = "deis/example-dockerfile-http"
 
-// Create executes `deis builds:create` as the specified user.
+// Create executes `deis builds:create` as the specified user. If settings.APIFixtures is set, the
+// build is created through the API instead.
 func Create(user model.User, app model.App) {
+	if settings.APIFixtures {
+		createWithAPI(user, app)
+		return
+	}
 	createOrPull(user, app, "builds:create")
 }
 
+// CreateWithProcfile executes `deis builds:create` as the specified user to deploy an image with
+// the given Procfile, mapping process types to the commands they run. If settings.APIFixtures is
+// set, the build is created through the API instead.
+func CreateWithProcfile(user model.User, app model.App, image string, procfile map[string]string) {
+	if settings.APIFixtures {
+		client, err := api.LoadProfile(user.Username)
+		Expect(err).NotTo(HaveOccurred(), "fixture: loading profile for %s", user.Username)
+		_, err = client.CreateBuild(app.Name, image, procfile)
+		Expect(err).NotTo(HaveOccurred(), "fixture: creating build of %s for %s", image, app.Name)
+		time.Sleep(10 * time.Second)
+		return
+	}
+	types := make([]string, 0, len(procfile))
+	for procType := range procfile {
+		types = append(types, procType)
+	}
+	sort.Strings(types)
+	entries := make([]string, len(types))
+	for i, procType := range types {
+		entries[i] = fmt.Sprintf("%s: %s", procType, procfile[procType])
+	}
+	// --procfile takes YAML, so the flow style keeps it on one line.
+	sess, err := cmd.Start("deis builds:create %s --app=%s --procfile='{%s}'", &user, image, app.Name, strings.Join(entries, ", "))
+	Expect(err).NotTo(HaveOccurred())
+	Eventually(sess).Should(Say("Creating build..."))
+	Eventually(sess, settings.MaxEventuallyTimeout).Should(Exit(0))
+	time.Sleep(10 * time.Second)
+}
+
 // Pull executes the `deis pull` shortcut as the specified user.
 func Pull(user model.User, app model.App) {
 	createOrPull(user, app, "pull")
@@ -34,3 +72,11 @@ func createOrPull(user model.User, app model.App, command string) {
 	Eventually(sess, settings.MaxEventuallyTimeout).Should(Exit(0))
 	time.Sleep(10 * time.Second)
 }
+
+func createWithAPI(user model.User, app model.App) {
+	client, err := api.LoadProfile(user.Username)
+	Expect(err).NotTo(HaveOccurred(), "fixture: loading profile for %s", user.Username)
+	_, err = client.CreateBuild(app.Name, ExampleImage, nil)
+	Expect(err).NotTo(HaveOccurred(), "fixture: creating build of %s for %s", ExampleImage, app.Name)
+	time.Sleep(10 * time.Second)
+}
@@ -1,11 +1,20 @@
 package certs
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"regexp"
+	"strings"
+	"time"
 
+	"github.com/deis/workflow-e2e/tests/api"
 	"github.com/deis/workflow-e2e/tests/cmd"
 	"github.com/deis/workflow-e2e/tests/model"
+	"github.com/deis/workflow-e2e/tests/pki"
+	"github.com/deis/workflow-e2e/tests/reaper"
 	"github.com/deis/workflow-e2e/tests/settings"
 
 	. "github.com/onsi/gomega"
@@ -33,6 +42,7 @@ func Add(user model.User, cert model.Cert) {
 	Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("done"))
 	Expect(err).NotTo(HaveOccurred())
 	Eventually(sess).Should(Exit(0))
+	Expect(reaper.Track(reaper.Resource{Kind: reaper.Cert, Name: cert.Name})).To(Succeed())
 	Eventually(List(user).Wait().Out.Contents()).Should(ContainSubstring(cert.Name))
 }
 
@@ -43,6 +53,7 @@ func Remove(user model.User, cert model.Cert) {
 	Eventually(sess).Should(Say("done"))
 	Expect(err).NotTo(HaveOccurred())
 	Eventually(sess).Should(Exit(0))
+	Expect(reaper.Untrack(reaper.Resource{Kind: reaper.Cert, Name: cert.Name})).To(Succeed())
 	Eventually(List(user).Wait().Out.Contents()).ShouldNot(ContainSubstring(cert.Name))
 }
 
@@ -72,10 +83,136 @@ func Detach(user model.User, cert model.Cert, domain string) {
 
 // Info executes `deis certs:info` as the specified user to retrieve information about the
 // specified cert.
-func Info(user model.User, cert model.Cert) *Session {
+func Info(user model.User, cert model.Cert) model.CertInfo {
 	sess, err := cmd.Start("deis certs:info %s", &user, cert.Name)
 	Eventually(sess).Should(Say("=== %s Certificate", cert.Name))
 	Expect(err).NotTo(HaveOccurred())
 	Eventually(sess).Should(Exit(0))
-	return sess
+	return ParseInfo(sess.Out.Contents())
+}
+
+// ExpectAttached asserts that `deis certs:info` lists exactly the given domains as those the
+// specified cert is attached to.
+func ExpectAttached(user model.User, cert model.Cert, domains ...string) {
+	attached := Info(user, cert).Domains
+	if len(domains) == 0 {
+		Expect(attached).To(BeEmpty(), "%s should not be attached to any domain", cert.Name)
+		return
+	}
+	Expect(pki.SortedNames(attached)).To(Equal(pki.SortedNames(domains)), "%s is attached to the wrong domains", cert.Name)
+}
+
+// ExpectMatchesFile asserts that info describes the certificate in the PEM file of the cert:
+// that the controller read the same names, validity window and fingerprint from it.
+func ExpectMatchesFile(info model.CertInfo, cert model.Cert) {
+	x := ReadPEM(cert.CertPath)
+	Expect(info.Name).To(Equal(cert.Name))
+	Expect(info.CommonName).To(Equal(x.Subject.CommonName), "common name of %s", cert.Name)
+	if len(x.DNSNames) == 0 {
+		Expect(info.SANs).To(BeEmpty(), "subject alternative names of %s", cert.Name)
+	} else {
+		Expect(pki.SortedNames(info.SANs)).To(Equal(pki.SortedNames(x.DNSNames)), "subject alternative names of %s", cert.Name)
+	}
+	// `deis certs:info` may only print the date, so compare dates in UTC.
+	Expect(info.Starts.UTC().Format(dateFormat)).To(Equal(x.NotBefore.UTC().Format(dateFormat)), "start of %s", cert.Name)
+	Expect(info.Expires.UTC().Format(dateFormat)).To(Equal(x.NotAfter.UTC().Format(dateFormat)), "expiry of %s", cert.Name)
+	Expect(normalizeFingerprint(info.Fingerprint)).To(Equal(pki.Fingerprint(x)), "fingerprint of %s", cert.Name)
+}
+
+const dateFormat = "2006-01-02"
+
+// ReadPEM reads and parses the first certificate in the PEM file at path.
+func ReadPEM(path string) *x509.Certificate {
+	x, err := pki.ReadCertificate(path)
+	Expect(err).NotTo(HaveOccurred(), "reading %s", path)
+	return x
+}
+
+// normalizeFingerprint returns a hex fingerprint, with or without colons, in the form
+// pki.Fingerprint returns.
+func normalizeFingerprint(fingerprint string) string {
+	digits := strings.ToUpper(strings.Replace(fingerprint, ":", "", -1))
+	var pairs []string
+	for i := 0; i+2 <= len(digits); i += 2 {
+		pairs = append(pairs, digits[i:i+2])
+	}
+	return strings.Join(pairs, ":")
+}
+
+var infoLineRegexp = regexp.MustCompile(`^([A-Za-z ()]+):\s*(.*)$`)
+
+// ParseInfo parses the output of `deis certs:info`:
+//
+//	=== www-foo-com Certificate
+//
+//	Common Name(s):     www.foo.com
+//	Expires At:         14 Jan 2017
+//	Starts At:          15 Jan 2016
+//	Fingerprint:        35:FA:8F:58:FF:EA:E0:22:79:29:0B:85:58:73:C2:A5:CD:4A:D9:81:D7:10:9D:4D:03:43:41:E4:1D:92:AB:C5
+//	Subject Alt Name:   foo.com,www.foo.com
+//	Issuer:             /C=US/ST=CA/L=San Francisco/O=Deis/OU=Engineering/CN=www.foo.com
+//	Subject:            /C=US/ST=CA/L=San Francisco/O=Deis/OU=Engineering/CN=www.foo.com
+//
+//	Connected Domains:  www.foo.com
+//	Owner:              admin
+func ParseInfo(output []byte) model.CertInfo {
+	var info model.CertInfo
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if m := infoHeadingRegexp.FindStringSubmatch(line); m != nil {
+			info.Name = m[1]
+			continue
+		}
+		m := infoLineRegexp.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		value := strings.TrimSpace(m[2])
+		switch m[1] {
+		case "Common Name(s)":
+			info.CommonName = value
+		case "Expires At":
+			info.Expires = parseDate(value)
+		case "Starts At":
+			info.Starts = parseDate(value)
+		case "Fingerprint":
+			info.Fingerprint = value
+		case "Subject Alt Name":
+			info.SANs = splitList(value)
+		case "Issuer":
+			info.Issuer = value
+		case "Subject":
+			info.Subject = value
+		case "Connected Domains":
+			if value != "No connected domains" {
+				info.Domains = splitList(value)
+			}
+		case "Owner":
+			info.Owner = value
+		}
+	}
+	return info
+}
+
+var infoHeadingRegexp = regexp.MustCompile(`^=== (\S+) Certificate$`)
+
+// parseDate parses a date as `deis certs:info` prints it, which is either just the day or the
+// controller's timestamp. It returns the zero time if value is neither, so that comparisons fail.
+func parseDate(value string) time.Time {
+	if t, err := time.Parse("2 Jan 2006", value); err == nil {
+		return t
+	}
+	t, _ := api.ParseTime(value)
+	return t
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
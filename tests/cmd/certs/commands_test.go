@@ -0,0 +1,105 @@
+package certs
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/deis/workflow-e2e/tests/model"
+)
+
+func TestParseInfo(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		output   string
+		expected model.CertInfo
+	}{
+		{
+			"attached to a domain",
+			`=== www-foo-com Certificate
+
+Common Name(s):     www.foo.com
+Expires At:         14 Jan 2017
+Starts At:          15 Jan 2016
+Fingerprint:        35:FA:8F:58:FF:EA:E0:22:79:29:0B:85:58:73:C2:A5:CD:4A:D9:81:D7:10:9D:4D:03:43:41:E4:1D:92:AB:C5
+Subject Alt Name:   foo.com,www.foo.com, *.foo.com
+Issuer:             /C=US/ST=CA/L=San Francisco/O=Deis/OU=Engineering/CN=www.foo.com
+Subject:            /C=US/ST=CA/L=San Francisco/O=Deis/OU=Engineering/CN=www.foo.com
+
+Connected Domains:  www.foo.com,foo.com
+Owner:              admin
+`,
+			model.CertInfo{
+				Name:        "www-foo-com",
+				CommonName:  "www.foo.com",
+				SANs:        []string{"foo.com", "www.foo.com", "*.foo.com"},
+				Starts:      time.Date(2016, 1, 15, 0, 0, 0, 0, time.UTC),
+				Expires:     time.Date(2017, 1, 14, 0, 0, 0, 0, time.UTC),
+				Fingerprint: "35:FA:8F:58:FF:EA:E0:22:79:29:0B:85:58:73:C2:A5:CD:4A:D9:81:D7:10:9D:4D:03:43:41:E4:1D:92:AB:C5",
+				Issuer:      "/C=US/ST=CA/L=San Francisco/O=Deis/OU=Engineering/CN=www.foo.com",
+				Subject:     "/C=US/ST=CA/L=San Francisco/O=Deis/OU=Engineering/CN=www.foo.com",
+				Domains:     []string{"www.foo.com", "foo.com"},
+				Owner:       "admin",
+			},
+		},
+		{
+			"not attached, without SANs and with timestamps",
+			`=== test-cert Certificate
+
+Common Name(s):     test.example.com
+Expires At:         2017-01-14T10:20:30Z
+Starts At:          2016-01-15T10:20:30UTC
+Fingerprint:        35fa8f58
+Subject Alt Name:
+Issuer:             /CN=e2e CA
+Subject:            /CN=test.example.com
+
+Connected Domains:  No connected domains
+Owner:              admin
+`,
+			model.CertInfo{
+				Name:        "test-cert",
+				CommonName:  "test.example.com",
+				Starts:      time.Date(2016, 1, 15, 10, 20, 30, 0, time.UTC),
+				Expires:     time.Date(2017, 1, 14, 10, 20, 30, 0, time.UTC),
+				Fingerprint: "35fa8f58",
+				Issuer:      "/CN=e2e CA",
+				Subject:     "/CN=test.example.com",
+				Owner:       "admin",
+			},
+		},
+	} {
+		got := ParseInfo([]byte(test.output))
+		// Compare times with Equal, which ignores how the location is represented.
+		if !got.Starts.Equal(test.expected.Starts) || !got.Expires.Equal(test.expected.Expires) {
+			t.Errorf("%s: expected to start at %s and expire at %s, got %s and %s",
+				test.name, test.expected.Starts, test.expected.Expires, got.Starts, got.Expires)
+		}
+		got.Starts, got.Expires = test.expected.Starts, test.expected.Expires
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	if got := parseDate("not a date"); !got.IsZero() {
+		t.Errorf("expected the zero time for a value that is not a date, got %s", got)
+	}
+}
+
+func TestNormalizeFingerprint(t *testing.T) {
+	for _, test := range []struct {
+		fingerprint string
+		expected    string
+	}{
+		{"35:FA:8F:58", "35:FA:8F:58"},
+		{"35:fa:8f:58", "35:FA:8F:58"},
+		{"35fa8f58", "35:FA:8F:58"},
+		{"", ""},
+	} {
+		if got := normalizeFingerprint(test.fingerprint); got != test.expected {
+			t.Errorf("normalizeFingerprint(%q): expected %q, got %q", test.fingerprint, test.expected, got)
+		}
+	}
+}
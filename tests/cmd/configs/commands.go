@@ -1,6 +1,13 @@
 package configs
 
 import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+
 	"github.com/deis/workflow-e2e/tests/cmd"
 	"github.com/deis/workflow-e2e/tests/model"
 	"github.com/deis/workflow-e2e/tests/settings"
@@ -22,3 +29,187 @@ func Set(user model.User, app model.App, key string, value string) *Session {
 	Eventually(sess).Should(Exit(0))
 	return sess
 }
+
+// SetMany executes `deis config:set` on the specified app as the specified user to set all of the
+// given environment variables at once, and returns the config listed afterwards. Values are
+// quoted, so they may contain spaces, newlines and quotes.
+func SetMany(user model.User, app model.App, values map[string]string) map[string]string {
+	args := make([]string, 0, len(values))
+	for _, key := range SortedKeys(values) {
+		args = append(args, cmd.Quote(key+"="+values[key]))
+	}
+	sess, err := cmd.Start("deis config:set %s --app=%s", &user, strings.Join(args, " "), app.Name)
+	Expect(err).NotTo(HaveOccurred())
+	Eventually(sess).Should(Say("Creating config"))
+	Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Config", app.Name))
+	Eventually(sess).Should(Exit(0))
+	return Parse(sess.Out.Contents())
+}
+
+// Unset executes `deis config:unset` on the specified app as the specified user to remove the
+// given environment variables, and returns the config listed afterwards.
+func Unset(user model.User, app model.App, keys ...string) map[string]string {
+	sess, err := cmd.Start("deis config:unset %s --app=%s", &user, strings.Join(keys, " "), app.Name)
+	Expect(err).NotTo(HaveOccurred())
+	Eventually(sess).Should(Say("Removing config"))
+	Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Config", app.Name))
+	Eventually(sess).Should(Exit(0))
+	return Parse(sess.Out.Contents())
+}
+
+// List executes `deis config:list` on the specified app as the specified user and returns the
+// environment variables it lists.
+func List(user model.User, app model.App) map[string]string {
+	sess, err := cmd.Start("deis config:list --app=%s", &user, app.Name)
+	Expect(err).NotTo(HaveOccurred())
+	Eventually(sess).Should(Say("=== %s Config", app.Name))
+	Eventually(sess).Should(Exit(0))
+	return Parse(sess.Out.Contents())
+}
+
+// Pull executes `deis config:pull` on the specified app as the specified user and returns the
+// path of the .env file it wrote, in settings.TestRoot. When its output is not a terminal, `deis
+// config:pull` prints the file instead of writing it, so the output is redirected to the file.
+func Pull(user model.User, app model.App) string {
+	f, err := ioutil.TempFile(settings.TestRoot, "pulled.env.")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(f.Close()).To(Succeed())
+	sess, err := cmd.Start("deis config:pull --app=%s > %s", &user, app.Name, cmd.Quote(f.Name()))
+	Expect(err).NotTo(HaveOccurred())
+	Eventually(sess, settings.MaxEventuallyTimeout).Should(Exit(0))
+	return f.Name()
+}
+
+// Push executes `deis config:push` on the specified app as the specified user to set the
+// environment variables in the .env file at path, and returns the config listed afterwards.
+func Push(user model.User, app model.App, path string) map[string]string {
+	sess, err := cmd.Start("deis config:push --path=%s --app=%s", &user, cmd.Quote(path), app.Name)
+	Expect(err).NotTo(HaveOccurred())
+	Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Config", app.Name))
+	Eventually(sess).Should(Exit(0))
+	return Parse(sess.Out.Contents())
+}
+
+// WriteEnvFile writes contents, e.g. as returned by FormatEnv, to a new .env file in
+// settings.TestRoot and returns its path.
+func WriteEnvFile(contents string) string {
+	f, err := ioutil.TempFile(settings.TestRoot, "push.env.")
+	Expect(err).NotTo(HaveOccurred())
+	_, err = f.WriteString(contents)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(f.Close()).To(Succeed())
+	return f.Name()
+}
+
+// ReadEnvFile reads and parses the .env file at path.
+func ReadEnvFile(path string) map[string]string {
+	contents, err := ioutil.ReadFile(path)
+	Expect(err).NotTo(HaveOccurred())
+	return ParseEnv(contents)
+}
+
+// FormatEnv formats environment variables as the lines of a .env file, sorted by name.
+func FormatEnv(values map[string]string) string {
+	var buf bytes.Buffer
+	for _, key := range SortedKeys(values) {
+		fmt.Fprintf(&buf, "%s=%s\n", key, values[key])
+	}
+	return buf.String()
+}
+
+// keyPattern matches the names the controller accepts for environment variables.
+const keyPattern = `[A-Za-z_][A-Za-z0-9_]*`
+
+var envLineRegexp = regexp.MustCompile(`^(` + keyPattern + `)=(.*)$`)
+
+// ParseEnv parses the contents of a .env file, which holds a KEY=value line per variable, or the
+// output of `env`. Line endings may be LF or CRLF, blank lines, comments and anything before the
+// first variable are skipped, and a line that does not start with KEY= continues the value of the
+// variable before it.
+func ParseEnv(contents []byte) map[string]string {
+	values := map[string]string{}
+	var key string
+	for _, line := range strings.Split(string(contents), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if m := envLineRegexp.FindStringSubmatch(line); m != nil {
+			key = m[1]
+			values[key] = m[2]
+			continue
+		}
+		if key != "" && line != "" && !strings.HasPrefix(line, "#") {
+			values[key] += "\n" + line
+		}
+	}
+	return values
+}
+
+var (
+	configHeadingRegexp = regexp.MustCompile(`^=== \S+ Config$`)
+	configLineRegexp    = regexp.MustCompile(`^(` + keyPattern + `) {2,}`)
+)
+
+// Parse parses the environment variables listed in the output of `deis config:list`, which
+// `deis config:set`, `deis config:unset` and `deis config:push` also print:
+//
+//	=== my-app Config
+//	FOO           This is
+//	a
+//	multiline string.
+//	POWERED_BY    the Deis team
+//
+// Names are sorted and padded so that every value starts in the same column, which is how lines
+// that start a variable are told apart from the later lines of a multi-line value. A value may
+// itself start with spaces, so the column is the leftmost one any value starts in. Values are kept
+// byte for byte, down to any carriage returns.
+func Parse(output []byte) map[string]string {
+	var lines []string
+	inConfig := false
+	for _, line := range strings.Split(string(output), "\n") {
+		if configHeadingRegexp.MatchString(line) {
+			inConfig = true
+			continue
+		}
+		if inConfig {
+			lines = append(lines, line)
+		}
+	}
+
+	column := -1
+	var key string
+	for _, line := range lines {
+		if m := configLineRegexp.FindStringSubmatchIndex(line); m != nil && line[m[2]:m[3]] > key {
+			key = line[m[2]:m[3]]
+			if column == -1 || m[1] < column {
+				column = m[1]
+			}
+		}
+	}
+
+	values := map[string]string{}
+	key = ""
+	for _, line := range lines {
+		if m := configLineRegexp.FindStringSubmatchIndex(line); m != nil && m[1] >= column && line[m[2]:m[3]] > key {
+			key = line[m[2]:m[3]]
+			values[key] = line[column:]
+			continue
+		}
+		if key != "" {
+			values[key] += "\n" + line
+		}
+	}
+	// Trailing blank lines belong to the output, not to the last value.
+	if key != "" {
+		values[key] = strings.TrimRight(values[key], "\n")
+	}
+	return values
+}
+
+// SortedKeys returns the names of the variables in values, sorted.
+func SortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,133 @@
+package configs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		output   string
+		expected map[string]string
+	}{
+		{
+			"no config",
+			"Creating config... done\n\n=== my-app Config\n",
+			map[string]string{},
+		},
+		{
+			"single line values",
+			"=== my-app Config\nFOO           bar\nPOWERED_BY    the Deis team\n",
+			map[string]string{"FOO": "bar", "POWERED_BY": "the Deis team"},
+		},
+		{
+			"multi-line value",
+			"=== my-app Config\nFOO           This is\na\nmultiline string.\nPOWERED_BY    the Deis team\n\n",
+			map[string]string{"FOO": "This is\na\nmultiline string.", "POWERED_BY": "the Deis team"},
+		},
+		{
+			"value continuing with a line that looks like a variable",
+			"=== my-app Config\nFOO      first\nBAR  not a variable\nZOO      last\n",
+			map[string]string{"FOO": "first\nBAR  not a variable", "ZOO": "last"},
+		},
+		{
+			"leading spaces",
+			"=== my-app Config\nFOO         bar\nINDENTED      two spaces\nZOO             four spaces\n",
+			map[string]string{"FOO": "bar", "INDENTED": "  two spaces", "ZOO": "    four spaces"},
+		},
+		{
+			"leading spaces on the first variable",
+			"=== my-app Config\nA           indented\nLONGER    plain\n",
+			map[string]string{"A": "  indented", "LONGER": "plain"},
+		},
+		{
+			"value containing =",
+			"=== my-app Config\nDATABASE_URL    postgres://u:p@db/app?sslmode=disable\nEQ              a=b=c\n",
+			map[string]string{"DATABASE_URL": "postgres://u:p@db/app?sslmode=disable", "EQ": "a=b=c"},
+		},
+		{
+			"carriage returns",
+			"=== my-app Config\nWIN    line one\r\nline two\r\nWOO    goo\r\n",
+			map[string]string{"WIN": "line one\r\nline two\r", "WOO": "goo\r"},
+		},
+		{
+			"multibyte values",
+			"=== my-app Config\nGREETING    héllo wörld\nSNOWMAN     ☃  ☃\n",
+			map[string]string{"GREETING": "héllo wörld", "SNOWMAN": "☃  ☃"},
+		},
+	} {
+		if got := Parse([]byte(test.output)); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestParseEnv(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		contents string
+		expected map[string]string
+	}{
+		{
+			"empty",
+			"",
+			map[string]string{},
+		},
+		{
+			"single line values",
+			"BIP=baz\nFOO=bar\n",
+			map[string]string{"BIP": "baz", "FOO": "bar"},
+		},
+		{
+			"blank lines and comments",
+			"# comment\n\nFOO=bar\n\n# another\nWOO=goo",
+			map[string]string{"FOO": "bar", "WOO": "goo"},
+		},
+		{
+			"multi-line value",
+			"FOO=This is\na\nmultiline string.\nWOO=goo\n",
+			map[string]string{"FOO": "This is\na\nmultiline string.", "WOO": "goo"},
+		},
+		{
+			"leading spaces",
+			"FOO=  two spaces\nBAR=\tand a tab\n",
+			map[string]string{"FOO": "  two spaces", "BAR": "\tand a tab"},
+		},
+		{
+			"value containing =",
+			"EQ=a=b=c\nEMPTY=\n",
+			map[string]string{"EQ": "a=b=c", "EMPTY": ""},
+		},
+		{
+			"CRLF line endings",
+			"BIP=baz\r\nFOO=bar\r\nWOO=goo\r\n",
+			map[string]string{"BIP": "baz", "FOO": "bar", "WOO": "goo"},
+		},
+		{
+			"output of env",
+			"Running 'env'...\n\n_=/usr/bin/env\nFOO=This is\na\nmultiline string.\nPORT=5000\n",
+			map[string]string{"_": "/usr/bin/env", "FOO": "This is\na\nmultiline string.", "PORT": "5000"},
+		},
+		{
+			"multibyte values",
+			"GREETING=héllo wörld\nSNOWMAN=☃\n",
+			map[string]string{"GREETING": "héllo wörld", "SNOWMAN": "☃"},
+		},
+	} {
+		if got := ParseEnv([]byte(test.contents)); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestFormatEnv(t *testing.T) {
+	values := map[string]string{"WOO": "goo", "BIP": "baz", "EQ": "a=b"}
+	formatted := FormatEnv(values)
+	if expected := "BIP=baz\nEQ=a=b\nWOO=goo\n"; formatted != expected {
+		t.Errorf("expected %q, got %q", expected, formatted)
+	}
+	if got := ParseEnv([]byte(formatted)); !reflect.DeepEqual(got, values) {
+		t.Errorf("expected %q to parse back to %q, got %q", formatted, values, got)
+	}
+}
@@ -1,11 +1,15 @@
 package domains
 
 import (
+	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/deis/workflow-e2e/tests/cmd"
 	"github.com/deis/workflow-e2e/tests/model"
+	"github.com/deis/workflow-e2e/tests/reaper"
 	"github.com/deis/workflow-e2e/tests/settings"
 
 	. "github.com/onsi/gomega"
@@ -18,6 +22,16 @@ var ErrNoDomainMatch = errors.New("\"No Domain matches the given query.\"")
 // The functions in this file implement SUCCESS CASES for commonly used `deis domains` subcommands.
 // This allows each of these to be re-used easily in multiple contexts.
 
+// List executes `deis domains:list` as the specified user and returns the domains of the specified
+// app, which always include the app's own name.
+func List(user model.User, app model.App) []string {
+	sess, err := cmd.Start("deis domains:list --app=%s", &user, app.Name)
+	Expect(err).NotTo(HaveOccurred())
+	Eventually(sess).Should(Say("=== %s Domains", app.Name))
+	Eventually(sess).Should(Exit(0))
+	return Parse(sess.Out.Contents())
+}
+
 // Add executes `deis domains:add` as the specified user to add the specified domain to the
 // specified app.
 func Add(user model.User, app model.App, domain string) {
@@ -28,6 +42,7 @@ func Add(user model.User, app model.App, domain string) {
 	Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("done"))
 	Expect(err).NotTo(HaveOccurred())
 	Eventually(sess).Should(Exit(0))
+	Expect(reaper.Track(reaper.Resource{Kind: reaper.Domain, Name: domain, App: app.Name})).To(Succeed())
 }
 
 // Remove executes `deis domains:remove` as the specified user to remove the specified domain from
@@ -40,4 +55,26 @@ func Remove(user model.User, app model.App, domain string) {
 	Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("done"))
 	Expect(err).NotTo(HaveOccurred())
 	Eventually(sess).Should(Exit(0))
+	Expect(reaper.Untrack(reaper.Resource{Kind: reaper.Domain, Name: domain, App: app.Name})).To(Succeed())
+}
+
+// Parse parses the domains listed in the output of `deis domains:list`:
+//
+//	=== my-app Domains
+//	my-app
+//	www.foo.com
+func Parse(output []byte) []string {
+	var domains []string
+	inDomains := false
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "=== ") && strings.HasSuffix(line, " Domains"):
+			inDomains = true
+		case inDomains && line != "":
+			domains = append(domains, line)
+		}
+	}
+	return domains
 }
@@ -0,0 +1,39 @@
+package domains
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		output   string
+		expected []string
+	}{
+		{
+			"default domain only",
+			"=== my-app Domains\nmy-app\n",
+			[]string{"my-app"},
+		},
+		{
+			"custom and wildcard domains",
+			"=== my-app Domains\nmy-app\nwww.foo.com\n*.foo.com\n\n",
+			[]string{"my-app", "www.foo.com", "*.foo.com"},
+		},
+		{
+			"output before the heading",
+			"Adding www.foo.com to my-app... done\n=== my-app Domains\n  my-app  \n",
+			[]string{"my-app"},
+		},
+		{
+			"no heading",
+			"my-app\n",
+			nil,
+		},
+	} {
+		if got := Parse([]byte(test.output)); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, got)
+		}
+	}
+}
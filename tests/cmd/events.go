@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/config"
+)
+
+// The functions in this file record every command run through Execute, Start and StartCmd as a
+// structured event, so that slow or flaky commands can be picked out of a run after the fact.
+
+// CmdEvent describes a single execution of a command.
+type CmdEvent struct {
+	CommandLine     string    `json:"command_line"`
+	User            string    `json:"user,omitempty"`
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	ExitCode        int       `json:"exit_code"`
+	StdoutBytes     int       `json:"stdout_bytes"`
+	StderrBytes     int       `json:"stderr_bytes"`
+	Spec            string    `json:"spec,omitempty"`
+	Node            int       `json:"node"`
+}
+
+var (
+	eventLogMu sync.Mutex
+	eventLog   *os.File
+)
+
+var profileRegexp = regexp.MustCompile(`(?:^|\s)DEIS_PROFILE=(\S+)`)
+
+// EnableEventLog starts appending a JSON object per line to the file at the given path for every
+// command that is executed. It is safe to call CloseEventLog while commands are still running;
+// their events are simply dropped.
+func EnableEventLog(path string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	eventLogMu.Lock()
+	defer eventLogMu.Unlock()
+	if eventLog != nil {
+		eventLog.Close()
+	}
+	eventLog = f
+	return nil
+}
+
+// CloseEventLog stops recording command events and closes the event log, if one is open.
+func CloseEventLog() error {
+	eventLogMu.Lock()
+	defer eventLogMu.Unlock()
+	if eventLog == nil {
+		return nil
+	}
+	err := eventLog.Close()
+	eventLog = nil
+	return err
+}
+
+// newEvent starts an event for the given command. It must be called from the goroutine running
+// the spec, so that the spec name can be determined.
+func newEvent(commandLine string, env []string) CmdEvent {
+	event := CmdEvent{
+		CommandLine: commandLine,
+		User:        profileOf(commandLine, env),
+		Start:       time.Now(),
+		Node:        config.GinkgoConfig.ParallelNode,
+	}
+	// Only ask Ginkgo about the current spec if events are being recorded; outside of a running
+	// suite there is no spec to ask about.
+	eventLogMu.Lock()
+	enabled := eventLog != nil
+	eventLogMu.Unlock()
+	if enabled {
+		event.Spec = currentSpec()
+	}
+	return event
+}
+
+// currentSpec returns the full text of the running spec. Ginkgo panics when asked before its
+// suite has started, as it has not when commands run from a plain Go test, so that is recovered
+// from and no spec is returned.
+func currentSpec() (spec string) {
+	defer func() {
+		recover()
+	}()
+	return ginkgo.CurrentGinkgoTestDescription().FullTestText
+}
+
+// finish completes the event and writes it to the event log.
+func (e CmdEvent) finish(exitCode, stdoutBytes, stderrBytes int) {
+	e.End = time.Now()
+	e.DurationSeconds = e.End.Sub(e.Start).Seconds()
+	e.ExitCode = exitCode
+	e.StdoutBytes = stdoutBytes
+	e.StderrBytes = stderrBytes
+
+	eventLogMu.Lock()
+	defer eventLogMu.Unlock()
+	if eventLog == nil {
+		return
+	}
+	enc := json.NewEncoder(eventLog)
+	enc.SetEscapeHTML(false)
+	enc.Encode(e)
+}
+
+// profileOf returns the DEIS_PROFILE a command runs as, preferring one set inline on the command
+// line over one set in its environment.
+func profileOf(commandLine string, env []string) string {
+	if m := profileRegexp.FindStringSubmatch(commandLine); m != nil {
+		return m[1]
+	}
+	profile := ""
+	for _, e := range env {
+		if strings.HasPrefix(e, "DEIS_PROFILE=") {
+			profile = strings.TrimPrefix(e, "DEIS_PROFILE=")
+		}
+	}
+	return profile
+}
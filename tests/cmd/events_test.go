@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/deis/workflow-e2e/tests/model"
+
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+)
+
+// readEvents returns the events recorded in the event log at path.
+func readEvents(t *testing.T, path string) []CmdEvent {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var events []CmdEvent
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event CmdEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("expected a JSON event per line, got %q: %s", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestEventLog(t *testing.T) {
+	RegisterTestingT(t)
+	dir, err := ioutil.TempDir("", "cmd-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "events.json")
+	if err := EnableEventLog(path); err != nil {
+		t.Fatal(err)
+	}
+	defer CloseEventLog()
+
+	before := time.Now()
+	if _, err := Execute("echo %s && echo oops >&2 && exit 3", "hello"); err == nil {
+		t.Error("expected the command to fail")
+	}
+	sess, err := StartCmd(model.Cmd{
+		Env:               append(os.Environ(), "DEIS_PROFILE=admin"),
+		CommandLineString: "sleep 0.1 && printf done",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Eventually(sess, 5*time.Second).Should(gexec.Exit(0))
+	// The event for a started command is written once its session is seen to exit.
+	Eventually(func() int { return len(readEvents(t, path)) }, 5*time.Second).Should(Equal(2))
+	if err := CloseEventLog(); err != nil {
+		t.Fatal(err)
+	}
+	// Commands run after the log is closed are not recorded.
+	Execute("true")
+
+	events := readEvents(t, path)
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	tests := []struct {
+		commandLine string
+		user        string
+		exitCode    int
+		stdoutBytes int
+		stderrBytes int
+		minDuration float64
+	}{
+		{"echo hello && echo oops >&2 && exit 3", "", 3, len("hello\n"), len("oops\n"), 0},
+		{"sleep 0.1 && printf done", "admin", 0, len("done"), 0, 0.1},
+	}
+	for i, test := range tests {
+		event := events[i]
+		if event.CommandLine != test.commandLine {
+			t.Errorf("event %d: expected command line %q, got %q", i, test.commandLine, event.CommandLine)
+		}
+		if event.User != test.user {
+			t.Errorf("%s: expected user %q, got %q", test.commandLine, test.user, event.User)
+		}
+		if event.ExitCode != test.exitCode {
+			t.Errorf("%s: expected exit code %d, got %d", test.commandLine, test.exitCode, event.ExitCode)
+		}
+		if event.StdoutBytes != test.stdoutBytes || event.StderrBytes != test.stderrBytes {
+			t.Errorf("%s: expected %d/%d bytes of stdout/stderr, got %d/%d", test.commandLine,
+				test.stdoutBytes, test.stderrBytes, event.StdoutBytes, event.StderrBytes)
+		}
+		if event.Start.Before(before) || event.End.Before(event.Start) {
+			t.Errorf("%s: expected to start after %s and end after starting, got %s to %s", test.commandLine, before, event.Start, event.End)
+		}
+		// The recorded times lose their monotonic readings, so allow for the wall clock differing.
+		elapsed := event.End.Sub(event.Start).Seconds()
+		if event.DurationSeconds < test.minDuration || math.Abs(event.DurationSeconds-elapsed) > 0.01 {
+			t.Errorf("%s: expected a duration of at least %gs matching start and end, got %gs", test.commandLine, test.minDuration, event.DurationSeconds)
+		}
+		if event.Spec != "" {
+			t.Errorf("%s: expected no spec outside of a suite, got %q", test.commandLine, event.Spec)
+		}
+	}
+}
+
+func TestProfileOf(t *testing.T) {
+	tests := []struct {
+		commandLine string
+		env         []string
+		expected    string
+	}{
+		{"deis apps", nil, ""},
+		{"deis apps", []string{"HOME=/root", "DEIS_PROFILE=admin"}, "admin"},
+		{"DEIS_PROFILE=bob deis apps", []string{"DEIS_PROFILE=admin"}, "bob"},
+		{"echo MY_DEIS_PROFILE=bob", nil, ""},
+	}
+	for _, test := range tests {
+		if got := profileOf(test.commandLine, test.env); got != test.expected {
+			t.Errorf("%s %v: expected %q, got %q", test.commandLine, test.env, test.expected, got)
+		}
+	}
+}
@@ -2,14 +2,14 @@ package git
 
 import (
 	"fmt"
-	"net/http"
 	"os"
-	"strconv"
 	"time"
 
 	"github.com/deis/workflow-e2e/tests/cmd"
+	"github.com/deis/workflow-e2e/tests/http"
 	"github.com/deis/workflow-e2e/tests/model"
 	"github.com/deis/workflow-e2e/tests/settings"
+	"github.com/deis/workflow-e2e/tests/transport"
 
 	. "github.com/onsi/gomega"
 	. "github.com/onsi/gomega/gbytes"
@@ -20,7 +20,7 @@ import (
 // This allows each of these to be re-used easily in multiple contexts.
 
 const (
-	pushCommandLineString = "GIT_SSH=%s GIT_KEY=%s git push deis master"
+	pushCommandLineString = "%s git push deis master"
 )
 
 // Push executes a `git push deis master` from the current directory using the provided key.
@@ -35,14 +35,9 @@ func Push(user model.User, keyPath string, app model.App, banner string) {
 
 // Curl polls an app over HTTP until it returns the expected "Powered by" banner.
 func Curl(app model.App, banner string) {
-	// curl the app's root URL and print just the HTTP response code
-	cmdRetryTimeout := 60
-	curlCmd := model.Cmd{CommandLineString: fmt.Sprintf(
-		`curl -sL -w "%%{http_code}\\n" "%s" -o /dev/null`, app.URL)}
-	Eventually(cmd.Retry(curlCmd, strconv.Itoa(http.StatusOK), cmdRetryTimeout)).Should(BeTrue())
 	// verify that the response contains "Powered by" as all the example apps do
-	curlCmd = model.Cmd{CommandLineString: fmt.Sprintf(`curl -sL "%s"`, app.URL)}
-	Eventually(cmd.Retry(curlCmd, banner, cmdRetryTimeout)).Should(BeTrue())
+	http.Probe{URL: app.URL, FollowRedirects: true}.Until(settings.DefaultEventuallyTimeout,
+		http.RespondWith(200), http.HaveBodyContaining(banner))
 }
 
 // PushWithInterrupt executes a `git push deis master` from the current
@@ -60,13 +55,13 @@ func PushWithInterrupt(user model.User, keyPath string) {
 }
 
 // PushUntilResult executes a `git push deis master` from the current
-// directory using the provided key, until the command result satisfies
-// expectedCmdResult of type model.CmdResult, failing if
-// settings.DefaultEventuallyTimeout is reached first.
-func PushUntilResult(user model.User, keyPath string, expectedCmdResult model.CmdResult) {
+// directory using the provided key, until the command result meets
+// expectedCmdResult, which may be a plain model.CmdResult or any other
+// model.Expectation, failing if settings.MaxEventuallyTimeout is reached first.
+func PushUntilResult(user model.User, keyPath string, expectedCmdResult model.Expectation) {
 	envVars := append(os.Environ(), fmt.Sprintf("DEIS_PROFILE=%s", user.Username))
 	pushCmd := model.Cmd{Env: envVars, CommandLineString: fmt.Sprintf(
-		pushCommandLineString, settings.GitSSH, keyPath)}
+		pushCommandLineString, sshEnv(keyPath))}
 
 	Eventually(cmd.RetryUntilResult(pushCmd, expectedCmdResult, 5*time.Second,
 		settings.MaxEventuallyTimeout)).Should(BeTrue())
@@ -74,7 +69,15 @@ func PushUntilResult(user model.User, keyPath string, expectedCmdResult model.Cm
 
 // StartPush starts a `git push deis master` command and returns the command session.
 func StartPush(user model.User, keyPath string) *Session {
-	sess, err := cmd.Start(pushCommandLineString, &user, settings.GitSSH, keyPath)
+	sess, err := cmd.Start(pushCommandLineString, &user, sshEnv(keyPath))
 	Expect(err).NotTo(HaveOccurred())
 	return sess
 }
+
+// sshEnv returns the GIT_SSH_COMMAND setting, quoted for the command line, that makes git push to
+// the builder with the provided key (see tests/transport).
+func sshEnv(keyPath string) string {
+	command, err := transport.Default.Command(keyPath)
+	Expect(err).NotTo(HaveOccurred())
+	return "GIT_SSH_COMMAND=" + cmd.Quote(command)
+}
@@ -0,0 +1,195 @@
+package git
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deis/workflow-e2e/tests/api"
+	"github.com/deis/workflow-e2e/tests/cmd/keys"
+	"github.com/deis/workflow-e2e/tests/fake"
+	"github.com/deis/workflow-e2e/tests/model"
+	"github.com/deis/workflow-e2e/tests/transport"
+
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gbytes"
+	. "github.com/onsi/gomega/gexec"
+	"golang.org/x/crypto/ssh"
+)
+
+// pushTest is a fake controller with a user who owns an app, a fake builder that pins its host
+// key in transport.Default, and a local git repo to push to the builder, which the test runs in.
+type pushTest struct {
+	t          *testing.T
+	controller *fake.Controller
+	builder    *fake.Builder
+	user       model.User
+	app        model.App
+	keyPath    string
+	dir        string
+	wd         string
+	transport  transport.Transport
+}
+
+func newPushTest(t *testing.T) *pushTest {
+	RegisterTestingT(t)
+	dir, err := ioutil.TempDir("", "git-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &pushTest{
+		t:          t,
+		controller: fake.NewController(),
+		user:       model.User{Username: "admin", Password: "admin", Email: "admin@example.com"},
+		app:        model.App{Name: "my-app"},
+		dir:        dir,
+		transport:  transport.Default,
+	}
+	if _, err := api.NewClient(p.controller.URL).Register(p.user.Username, p.user.Password, p.user.Email); err != nil {
+		t.Fatal(err)
+	}
+	client, err := api.Login(p.controller.URL, p.user.Username, p.user.Password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateApp(p.app.Name); err != nil {
+		t.Fatal(err)
+	}
+	key, err := keys.NewPrivateKey(keys.Ed25519, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.keyPath = filepath.Join(dir, "admin-key")
+	if err := keys.WriteKeyPair(p.keyPath, key, "admin-key"); err != nil {
+		t.Fatal(err)
+	}
+	public, err := ssh.NewPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateKey("admin-key", strings.TrimSpace(string(ssh.MarshalAuthorizedKey(public)))); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.builder, err = fake.NewBuilder(p.controller); err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := transport.Scan(p.builder.Addr(), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	knownHosts := filepath.Join(dir, "known_hosts")
+	if err := transport.WriteKnownHosts(knownHosts, hostKey); err != nil {
+		t.Fatal(err)
+	}
+	transport.Default = transport.Transport{KnownHosts: knownHosts}
+
+	repo := filepath.Join(dir, p.app.Name)
+	p.git("", "init", "--quiet", repo)
+	p.git(repo, "checkout", "--quiet", "-b", "master")
+	p.commit(repo, "initial")
+	p.git(repo, "remote", "add", "deis", p.builder.GitURL(p.app.Name))
+	if p.wd, err = os.Getwd(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(repo); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func (p *pushTest) Close() {
+	os.Chdir(p.wd)
+	transport.Default = p.transport
+	if p.builder != nil {
+		p.builder.Close()
+	}
+	p.controller.Close()
+	os.RemoveAll(p.dir)
+}
+
+func (p *pushTest) git(dir string, args ...string) {
+	command := exec.Command("git", args...)
+	command.Dir = dir
+	if output, err := command.CombinedOutput(); err != nil {
+		p.t.Fatalf("git %s: %s: %s", strings.Join(args, " "), err, output)
+	}
+}
+
+func (p *pushTest) commit(repo, message string) {
+	if err := ioutil.WriteFile(filepath.Join(repo, "README"), []byte(message+"\n"), 0644); err != nil {
+		p.t.Fatal(err)
+	}
+	p.git(repo, "add", "README")
+	p.git(repo, "-c", "user.name=Test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", message)
+}
+
+func TestPush(t *testing.T) {
+	p := newPushTest(t)
+	defer p.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "Powered by Deis")
+	}))
+	defer server.Close()
+	p.app.URL = server.URL
+
+	Push(p.user, p.keyPath, p.app, "Powered by Deis")
+	if got := p.builder.Pushes(p.app.Name); got != 1 {
+		t.Errorf("expected 1 push, got %d", got)
+	}
+}
+
+func TestPushUntilResult(t *testing.T) {
+	p := newPushTest(t)
+	defer p.Close()
+
+	p.builder.Script(p.app.Name, fake.Script{Fail: true})
+	PushUntilResult(p.user, p.keyPath, model.AllOf(model.ExitCodeIn(1), model.ErrContains("pre-receive hook declined")))
+	if got := p.builder.Pushes(p.app.Name); got != 1 {
+		t.Errorf("expected the failing push to be the one attempt, got %d pushes", got)
+	}
+
+	// Once the build is fixed, pushing again deploys the commit.
+	p.builder.Script(p.app.Name, fake.Script{})
+	PushUntilResult(p.user, p.keyPath, model.CmdResult{Err: []byte("Done, my-app:v2 deployed to Workflow"), ExitCode: 0})
+	if got := p.builder.Pushes(p.app.Name); got != 2 {
+		t.Errorf("expected 2 pushes, got %d", got)
+	}
+}
+
+func TestStartPushRejectsConcurrentPushes(t *testing.T) {
+	p := newPushTest(t)
+	defer p.Close()
+
+	p.builder.Script(p.app.Name, fake.Script{Hang: true})
+	first := StartPush(p.user, p.keyPath)
+	Eventually(first.Err, 30*time.Second).Should(Say(fake.BuildStartLine))
+	second := StartPush(p.user, p.keyPath)
+	Eventually(second, 30*time.Second).Should(Exit(128))
+	Expect(second.Err).To(Say("fatal: remote error: " + fake.ConcurrentPushError))
+
+	// The hanging build holds the lock until the builder is stopped, which fails the push.
+	p.builder.Close()
+	p.builder = nil
+	Eventually(first, 30*time.Second).Should(Exit())
+	Expect(first.ExitCode()).NotTo(Equal(0))
+}
+
+func TestPushWithInterrupt(t *testing.T) {
+	p := newPushTest(t)
+	defer p.Close()
+
+	// An interrupted build carries on, so the app stays locked while PushWithInterrupt pushes again.
+	p.builder.Script(p.app.Name, fake.Script{Duration: 5 * time.Second})
+	PushWithInterrupt(p.user, p.keyPath)
+	if got := p.builder.Pushes(p.app.Name); got != 2 {
+		t.Errorf("expected 2 pushes, got %d", got)
+	}
+}
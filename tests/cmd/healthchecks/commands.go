@@ -0,0 +1,313 @@
+package healthchecks
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/deis/workflow-e2e/tests/cmd"
+	"github.com/deis/workflow-e2e/tests/model"
+	"github.com/deis/workflow-e2e/tests/settings"
+
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gbytes"
+	. "github.com/onsi/gomega/gexec"
+)
+
+// The functions in this file implement SUCCESS CASES for commonly used `deis healthchecks`
+// subcommands. This allows each of these to be re-used easily in multiple contexts.
+
+// HealthType is the kind of healthcheck: liveness or readiness.
+type HealthType string
+
+// Kinds of healthcheck.
+const (
+	Liveness  HealthType = "liveness"
+	Readiness HealthType = "readiness"
+)
+
+// Probe is how a healthcheck checks on a process: an ExecProbe, HTTPGetProbe or TCPSocketProbe.
+type Probe interface {
+	// probeType is the name `deis healthchecks:set` knows the probe by.
+	probeType() string
+	// args returns the options and arguments `deis healthchecks:set` needs for the probe.
+	args() string
+}
+
+// ExecProbe runs a command in the container.
+type ExecProbe struct {
+	Command []string
+}
+
+func (p ExecProbe) probeType() string { return "exec" }
+func (p ExecProbe) args() string {
+	args := make([]string, len(p.Command))
+	for i, arg := range p.Command {
+		args[i] = cmd.Quote(arg)
+	}
+	return strings.Join(append([]string{"--"}, args...), " ")
+}
+
+// HTTPGetProbe requests a path from a port of the container.
+type HTTPGetProbe struct {
+	Path    string
+	Port    int
+	Headers []Header
+}
+
+// Header is an HTTP header sent by an HTTPGetProbe.
+type Header struct {
+	Name  string
+	Value string
+}
+
+func (p HTTPGetProbe) probeType() string { return "httpGet" }
+func (p HTTPGetProbe) args() string {
+	var args []string
+	if p.Path != "" {
+		args = append(args, "--path="+cmd.Quote(p.Path))
+	}
+	for _, header := range p.Headers {
+		args = append(args, "--headers="+cmd.Quote(header.Name+":"+header.Value))
+	}
+	return strings.Join(append(args, strconv.Itoa(p.Port)), " ")
+}
+
+// TCPSocketProbe opens a connection to a port of the container.
+type TCPSocketProbe struct {
+	Port int
+}
+
+func (p TCPSocketProbe) probeType() string { return "tcpSocket" }
+func (p TCPSocketProbe) args() string      { return strconv.Itoa(p.Port) }
+
+// Timing holds the timing options of a healthcheck, in seconds or counts. Zero fields are left to
+// the controller's defaults when setting a healthcheck.
+type Timing struct {
+	InitialDelay     int
+	Timeout          int
+	Period           int
+	SuccessThreshold int
+	FailureThreshold int
+}
+
+// DefaultTiming is what the controller uses for any timing option that is not set.
+var DefaultTiming = Timing{InitialDelay: 50, Timeout: 50, Period: 10, SuccessThreshold: 1, FailureThreshold: 3}
+
+// WithDefaults returns the timing with any zero fields replaced by those of DefaultTiming.
+func (t Timing) WithDefaults() Timing {
+	defaults := func(value, def int) int {
+		if value == 0 {
+			return def
+		}
+		return value
+	}
+	return Timing{
+		InitialDelay:     defaults(t.InitialDelay, DefaultTiming.InitialDelay),
+		Timeout:          defaults(t.Timeout, DefaultTiming.Timeout),
+		Period:           defaults(t.Period, DefaultTiming.Period),
+		SuccessThreshold: defaults(t.SuccessThreshold, DefaultTiming.SuccessThreshold),
+		FailureThreshold: defaults(t.FailureThreshold, DefaultTiming.FailureThreshold),
+	}
+}
+
+func (t Timing) args() string {
+	var args []string
+	for _, opt := range []struct {
+		flag  string
+		value int
+	}{
+		{"initial-delay", t.InitialDelay},
+		{"timeout", t.Timeout},
+		{"period", t.Period},
+		{"success-threshold", t.SuccessThreshold},
+		{"failure-threshold", t.FailureThreshold},
+	} {
+		if opt.value != 0 {
+			args = append(args, fmt.Sprintf("--%s=%d", opt.flag, opt.value))
+		}
+	}
+	return strings.Join(args, " ")
+}
+
+// Healthcheck is a healthcheck of an app, as set with `deis healthchecks:set` or listed by
+// `deis healthchecks:list`.
+type Healthcheck struct {
+	Type HealthType
+	// ProcType is the process type the healthcheck applies to. When setting, empty means the
+	// default process type; when listed by a CLI that does not show process types, it is empty.
+	ProcType string
+	Probe    Probe
+	Timing   Timing
+}
+
+// Set executes `deis healthchecks:set` as the specified user to set the healthcheck on the
+// specified app, and returns the healthchecks listed afterwards.
+func Set(user model.User, app model.App, hc Healthcheck) []Healthcheck {
+	cmdLine := fmt.Sprintf("deis healthchecks:set %s %s -a %s", hc.Type, hc.Probe.probeType(), app.Name)
+	if hc.ProcType != "" {
+		cmdLine += " --type=" + hc.ProcType
+	}
+	if timing := hc.Timing.args(); timing != "" {
+		cmdLine += " " + timing
+	}
+	cmdLine += " " + hc.Probe.args()
+
+	sess, err := cmd.Start("%s", &user, cmdLine)
+	Expect(err).NotTo(HaveOccurred())
+	Eventually(sess).Should(Say("Applying %sProbe healthcheck...", hc.Type))
+	Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Healthchecks", app.Name))
+	Eventually(sess).Should(Exit(0))
+	return Parse(sess.Out.Contents())
+}
+
+// Unset executes `deis healthchecks:unset` as the specified user to remove healthchecks of the
+// given types from the specified app, and returns the healthchecks listed afterwards. An empty
+// procType means the default process type.
+func Unset(user model.User, app model.App, procType string, types ...HealthType) []Healthcheck {
+	strs := make([]string, len(types))
+	for i, t := range types {
+		strs[i] = string(t)
+	}
+	cmdLine := fmt.Sprintf("deis healthchecks:unset -a %s", app.Name)
+	if procType != "" {
+		cmdLine += " --type=" + procType
+	}
+	cmdLine += " " + strings.Join(strs, " ")
+
+	sess, err := cmd.Start("%s", &user, cmdLine)
+	Expect(err).NotTo(HaveOccurred())
+	Eventually(sess).Should(Say("Removing healthchecks..."))
+	Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Healthchecks", app.Name))
+	Eventually(sess).Should(Exit(0))
+	return Parse(sess.Out.Contents())
+}
+
+// List executes `deis healthchecks:list` as the specified user on the specified app and returns
+// the healthchecks it lists.
+func List(user model.User, app model.App) []Healthcheck {
+	sess, err := cmd.Start("deis healthchecks:list -a %s", &user, app.Name)
+	Expect(err).NotTo(HaveOccurred())
+	Eventually(sess).Should(Say("=== %s Healthchecks", app.Name))
+	Eventually(sess).Should(Exit(0))
+	return Parse(sess.Out.Contents())
+}
+
+// Find returns the healthcheck of the given type for the given process type. An empty procType
+// matches any process type.
+func Find(hcs []Healthcheck, healthType HealthType, procType string) (Healthcheck, bool) {
+	for _, hc := range hcs {
+		if hc.Type == healthType && (procType == "" || hc.ProcType == "" || hc.ProcType == procType) {
+			return hc, true
+		}
+	}
+	return Healthcheck{}, false
+}
+
+var (
+	procTypeRegexp = regexp.MustCompile(`^([a-z0-9-]+):$`)
+	execRegexp     = regexp.MustCompile(`^Command=\[(.*)\]$`)
+	httpGetRegexp  = regexp.MustCompile(`^Path="(.*)" Port=(\d+) HTTPHeaders=\[(.*)\]$`)
+	tcpRegexp      = regexp.MustCompile(`^Port=(\d+)$`)
+)
+
+// Parse parses the healthchecks listed in the output of `deis healthchecks:list`, which
+// `deis healthchecks:set` and `deis healthchecks:unset` also print. Each healthcheck is listed
+// under a "Liveness" or "Readiness" heading, optionally grouped under a process type:
+//
+//	=== my-app Healthchecks
+//	cmd:
+//	--- Liveness
+//	Initial Delay (seconds): 50
+//	...
+//	HTTP GET Probe: Path="/" Port=1500 HTTPHeaders=[]
+//	TCP Socket Probe: N/A
+func Parse(output []byte) []Healthcheck {
+	var (
+		hcs      []Healthcheck
+		current  *Healthcheck
+		health   HealthType
+		procType string
+	)
+	flush := func() {
+		if current != nil && current.Probe != nil {
+			hcs = append(hcs, *current)
+		}
+		current = nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		heading := HealthType(strings.ToLower(strings.Trim(line, "-: ")))
+		switch {
+		case line == "" || strings.HasPrefix(line, "==="):
+			continue
+		case heading == Liveness || heading == Readiness:
+			flush()
+			health = heading
+			continue
+		case procTypeRegexp.MatchString(line):
+			flush()
+			procType = procTypeRegexp.FindStringSubmatch(line)[1]
+			health = ""
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 || health == "" {
+			continue
+		}
+		key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if current == nil {
+			current = &Healthcheck{Type: health, ProcType: procType}
+		}
+		if value == "N/A" {
+			continue
+		}
+		switch key {
+		case "Initial Delay (seconds)":
+			current.Timing.InitialDelay, _ = strconv.Atoi(value)
+		case "Timeout (seconds)":
+			current.Timing.Timeout, _ = strconv.Atoi(value)
+		case "Period (seconds)":
+			current.Timing.Period, _ = strconv.Atoi(value)
+		case "Success Threshold":
+			current.Timing.SuccessThreshold, _ = strconv.Atoi(value)
+		case "Failure Threshold":
+			current.Timing.FailureThreshold, _ = strconv.Atoi(value)
+		case "Exec Probe":
+			if m := execRegexp.FindStringSubmatch(value); m != nil {
+				current.Probe = ExecProbe{Command: strings.Fields(m[1])}
+			}
+		case "HTTP GET Probe":
+			if m := httpGetRegexp.FindStringSubmatch(value); m != nil {
+				port, _ := strconv.Atoi(m[2])
+				current.Probe = HTTPGetProbe{Path: m[1], Port: port, Headers: parseHeaders(m[3])}
+			}
+		case "TCP Socket Probe":
+			if m := tcpRegexp.FindStringSubmatch(value); m != nil {
+				port, _ := strconv.Atoi(m[1])
+				current.Probe = TCPSocketProbe{Port: port}
+			}
+		}
+	}
+	flush()
+	return hcs
+}
+
+// parseHeaders parses headers listed as "Name=Value" or "Name:Value", separated by spaces.
+func parseHeaders(s string) []Header {
+	var headers []Header
+	for _, field := range strings.Fields(s) {
+		i := strings.IndexAny(field, "=:")
+		if i < 0 {
+			continue
+		}
+		headers = append(headers, Header{Name: field[:i], Value: field[i+1:]})
+	}
+	return headers
+}
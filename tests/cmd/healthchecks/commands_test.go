@@ -0,0 +1,128 @@
+package healthchecks
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		output   string
+		expected []Healthcheck
+	}{
+		{
+			"no healthchecks",
+			"=== my-app Healthchecks\nNo healthchecks configured.\n",
+			nil,
+		},
+		{
+			"without process types",
+			`=== my-app Healthchecks
+--- Liveness
+Initial Delay (seconds): 50
+Timeout (seconds): 50
+Period (seconds): 10
+Success Threshold: 1
+Failure Threshold: 3
+Exec Probe: N/A
+HTTP GET Probe: Path="/healthz" Port=5000 HTTPHeaders=[]
+TCP Socket Probe: N/A
+
+--- Readiness
+Initial Delay (seconds): 5
+Timeout (seconds): 2
+Period (seconds): 20
+Success Threshold: 2
+Failure Threshold: 4
+Exec Probe: N/A
+HTTP GET Probe: N/A
+TCP Socket Probe: Port=5000
+`,
+			[]Healthcheck{
+				{Type: Liveness, Probe: HTTPGetProbe{Path: "/healthz", Port: 5000}, Timing: DefaultTiming},
+				{Type: Readiness, Probe: TCPSocketProbe{Port: 5000}, Timing: Timing{5, 2, 20, 2, 4}},
+			},
+		},
+		{
+			"grouped by process type",
+			`Applying livenessProbe healthcheck...
+
+=== my-app Healthchecks
+
+cmd:
+--- Liveness
+Initial Delay (seconds): 50
+Timeout (seconds): 50
+Period (seconds): 10
+Success Threshold: 1
+Failure Threshold: 3
+Exec Probe: Command=[/bin/cat /tmp/healthy]
+HTTP GET Probe: N/A
+TCP Socket Probe: N/A
+
+--- Readiness
+No readiness probe configured.
+
+worker-2:
+--- Readiness
+Initial Delay (seconds): 50
+Timeout (seconds): 50
+Period (seconds): 10
+Success Threshold: 1
+Failure Threshold: 3
+Exec Probe: N/A
+HTTP GET Probe: Path="/it's ready" Port=8080 HTTPHeaders=[X-Probe=yes Accept:text/plain]
+TCP Socket Probe: N/A
+`,
+			[]Healthcheck{
+				{Type: Liveness, ProcType: "cmd", Probe: ExecProbe{Command: []string{"/bin/cat", "/tmp/healthy"}}, Timing: DefaultTiming},
+				{Type: Readiness, ProcType: "worker-2", Timing: DefaultTiming, Probe: HTTPGetProbe{
+					Path: "/it's ready", Port: 8080,
+					Headers: []Header{{Name: "X-Probe", Value: "yes"}, {Name: "Accept", Value: "text/plain"}},
+				}},
+			},
+		},
+		{
+			"a healthcheck without a recognised probe",
+			"=== my-app Healthchecks\n--- Liveness\nInitial Delay (seconds): 50\nExec Probe: N/A\nHTTP GET Probe: N/A\nTCP Socket Probe: N/A\n",
+			nil,
+		},
+	} {
+		if got := Parse([]byte(test.output)); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestProbeArgs(t *testing.T) {
+	for _, test := range []struct {
+		probe    Probe
+		expected string
+	}{
+		{ExecProbe{Command: []string{"/bin/cat", "/tmp/healthy"}}, `-- '/bin/cat' '/tmp/healthy'`},
+		{ExecProbe{Command: []string{"sh", "-c", "test -f '/tmp/it''s here'"}}, `-- 'sh' '-c' 'test -f '\''/tmp/it'\'''\''s here'\'''`},
+		{HTTPGetProbe{Port: 5000}, "5000"},
+		{HTTPGetProbe{Path: "/it's ready", Port: 5000}, `--path='/it'\''s ready' 5000`},
+		{
+			HTTPGetProbe{Path: "/", Port: 80, Headers: []Header{{Name: "X-Probe", Value: "it's me"}, {Name: "Accept", Value: "*/*"}}},
+			`--path='/' --headers='X-Probe:it'\''s me' --headers='Accept:*/*' 80`,
+		},
+		{TCPSocketProbe{Port: 5000}, "5000"},
+	} {
+		if got := test.probe.args(); got != test.expected {
+			t.Errorf("%+v: expected %s, got %s", test.probe, test.expected, got)
+		}
+	}
+}
+
+func TestTimingWithDefaults(t *testing.T) {
+	timing := Timing{InitialDelay: 5, FailureThreshold: 1}
+	expected := Timing{InitialDelay: 5, Timeout: 50, Period: 10, SuccessThreshold: 1, FailureThreshold: 1}
+	if got := timing.WithDefaults(); got != expected {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+	if got := timing.args(); got != "--initial-delay=5 --failure-threshold=1" {
+		t.Errorf("expected only the set options to be passed, got %q", got)
+	}
+}
@@ -1,18 +1,19 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"strings"
-	"time"
+	"sync"
+	"syscall"
 
 	"github.com/deis/workflow-e2e/tests/model"
 	"github.com/deis/workflow-e2e/tests/settings"
 
 	"github.com/onsi/ginkgo"
-	"github.com/onsi/gomega"
 	"github.com/onsi/gomega/gexec"
 )
 
@@ -27,10 +28,19 @@ func Execute(cmdLine string, args ...interface{}) (string, error) {
 		fmt.Println(shCommand)
 	}
 
+	event := newEvent(shCommand, os.Environ())
 	cmd = exec.Command("/bin/sh", "-c", shCommand)
-	outputBytes, err := cmd.CombinedOutput()
-
-	output := string(outputBytes)
+	// Keep the interleaved output CombinedOutput would have given us, but count each stream.
+	var combined bytes.Buffer
+	var mu sync.Mutex
+	stdout := &countingWriter{mu: &mu, w: &combined}
+	stderr := &countingWriter{mu: &mu, w: &combined}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	event.finish(exitCode(cmd, err), stdout.n, stderr.n)
+
+	output := combined.String()
 
 	if settings.Debug {
 		fmt.Println(output)
@@ -59,61 +69,51 @@ func StartCmd(command model.Cmd) (*gexec.Session, error) {
 	execCmd := exec.Command("/bin/sh", "-c", command.CommandLineString)
 	execCmd.Env = command.Env
 	io.WriteString(ginkgo.GinkgoWriter, fmt.Sprintf("$ %s\n", command.CommandLineString))
-	return gexec.Start(execCmd, ginkgo.GinkgoWriter, ginkgo.GinkgoWriter)
+	event := newEvent(command.CommandLineString, command.Env)
+	sess, err := gexec.Start(execCmd, ginkgo.GinkgoWriter, ginkgo.GinkgoWriter)
+	if err != nil {
+		event.finish(-1, 0, 0)
+		return sess, err
+	}
+	go func() {
+		<-sess.Exited
+		event.finish(sess.ExitCode(), len(sess.Out.Contents()), len(sess.Err.Contents()))
+	}()
+	return sess, nil
 }
 
-// Retry runs the provided <cmd> repeatedly, once a second up to the
-// supplied <timeout> until the <cmd> result contains the <expectedResult>
-// An example use of this utility would be curl-ing a url and waiting
-// until the response code matches the expected response.
-// TODO: https://github.com/deis/workflow-e2e/issues/240
-func Retry(command model.Cmd, expectedResult string, timeout int) bool {
-	var result string
-	fmt.Fprintf(ginkgo.GinkgoWriter, "Waiting up to %d seconds for `%s` to return %s...\n", timeout, command.CommandLineString, expectedResult)
-	for i := 0; i < timeout; i++ {
-		sess, err := StartCmd(command)
-		gomega.Expect(err).NotTo(gomega.HaveOccurred())
-		result = string(sess.Wait().Out.Contents())
-		if strings.Contains(result, expectedResult) {
-			return true
-		}
-		time.Sleep(1 * time.Second)
-	}
-	fmt.Fprintf(ginkgo.GinkgoWriter, "FAIL: '%s' does not match expected result of '%s'\n", result, expectedResult)
-	return false
+// Quote quotes s for /bin/sh, so that it reaches a command as a single argument however many
+// spaces, newlines or quotes it contains.
+func Quote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// countingWriter counts the bytes written through it. Writers sharing an underlying io.Writer
+// should share a mutex.
+type countingWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+	n  int
 }
 
-// RetryUntilResult runs the provided cmd repeatedly, once every period,
-// up to the supplied timeout until the cmd result matches the supplied
-// expectedCmdResult
-func RetryUntilResult(command model.Cmd, expectedCmdResult model.CmdResult, period, timeout time.Duration) bool {
-	var actualCmdResult model.CmdResult
-
-	fmt.Fprintf(ginkgo.GinkgoWriter,
-		"Waiting up to %d seconds for `%s` to return expected cmdResult %s...\n",
-		int(timeout.Seconds()), command.CommandLineString, expectedCmdResult.String())
-
-	tck := time.NewTicker(period)
-	tmr := time.NewTimer(timeout)
-	defer tck.Stop()
-	defer tmr.Stop()
-	for {
-		select {
-		case <-tck.C:
-			sess, err := StartCmd(command)
-			gomega.Expect(err).NotTo(gomega.HaveOccurred())
-			sessWait := sess.Wait()
-			actualCmdResult = model.CmdResult{
-				Out:      sessWait.Out.Contents(),
-				Err:      sessWait.Err.Contents(),
-				ExitCode: sessWait.ExitCode(),
-			}
-			if actualCmdResult.Satisfies(expectedCmdResult) {
-				return true
-			}
-		case <-tmr.C:
-			fmt.Fprintf(ginkgo.GinkgoWriter, "FAIL: Actual cmdResult '%v' does not match expected cmdResult '%v'\n", actualCmdResult, expectedCmdResult)
-			return false
-		}
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, err := c.w.Write(p)
+	c.n += n
+	return n, err
+}
+
+// exitCode returns the exit code of a command that has been run, or -1 if it could not be run.
+func exitCode(cmd *exec.Cmd, err error) int {
+	if cmd.ProcessState == nil {
+		return -1
+	}
+	if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok {
+		return status.ExitStatus()
+	}
+	if err != nil {
+		return -1
 	}
+	return 0
 }
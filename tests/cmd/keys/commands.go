@@ -1,14 +1,16 @@
 package keys
 
 import (
-	"fmt"
-	"math/rand"
-	"os"
-	"path"
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/deis/workflow-e2e/tests/cmd"
 	"github.com/deis/workflow-e2e/tests/model"
+	"github.com/deis/workflow-e2e/tests/reaper"
 	"github.com/deis/workflow-e2e/tests/settings"
 
 	. "github.com/onsi/gomega"
@@ -19,35 +21,120 @@ import (
 // The functions in this file implement SUCCESS CASES for commonly used `deis keys` subcommands.
 // This allows each of these to be re-used easily in multiple contexts.
 
-// Add executes `deis keys:add` as the specified user to add a new key to that user's account.
-func Add(user model.User) (string, string) {
-	keyName, keyPath := createKey()
-	sess, err := cmd.Start("deis keys:add %s.pub", &user, keyPath)
-	Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("Uploading %s.pub to deis... done", keyName))
+// Add generates a new RSA key for the specified user and executes `deis keys:add` as that user to
+// add it to their account.
+func Add(user model.User) model.Key {
+	return AddOfType(user, RSA)
+}
+
+// AddOfType generates a new key of the given type for the specified user and executes `deis
+// keys:add` as that user to add it to their account.
+func AddOfType(user model.User, keyType string) model.Key {
+	key, err := Default.Generate(user, keyType, 0)
+	Expect(err).NotTo(HaveOccurred())
+	Register(user, key)
+	return key
+}
+
+// Register executes `deis keys:add` as the specified user to add an existing key to that user's
+// account.
+func Register(user model.User, key model.Key) {
+	sess, err := cmd.Start("deis keys:add %s", &user, key.PublicPath())
+	Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("Uploading %s.pub to deis... done", key.Name))
 	Expect(err).NotTo(HaveOccurred())
 	Eventually(sess).Should(Exit(0))
+	Expect(reaper.Track(reaper.Resource{Kind: reaper.Key, Name: key.Name})).To(Succeed())
 	time.Sleep(5 * time.Second) // Wait for the key to propagate before continuing
-	return keyName, keyPath
 }
 
 // Remove executes `deis keys:remove` as the specified user to remove the specified key from that
 // user's account.
-func Remove(user model.User, keyName string) {
-	sess, err := cmd.Start("deis keys:remove %s", &user, keyName)
-	Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("Removing %s SSH Key... done", keyName))
+func Remove(user model.User, key model.Key) {
+	sess, err := cmd.Start("deis keys:remove %s", &user, key.Name)
+	Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("Removing %s SSH Key... done", key.Name))
 	Eventually(sess).Should(Exit(0))
 	Expect(err).NotTo(HaveOccurred())
+	Expect(reaper.Untrack(reaper.Resource{Kind: reaper.Key, Name: key.Name})).To(Succeed())
+}
+
+// List executes `deis keys:list` as the specified user and returns the keys it lists.
+func List(user model.User) []Listing {
+	sess, err := cmd.Start("deis keys:list", &user)
+	Expect(err).NotTo(HaveOccurred())
+	Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Keys", user.Username))
+	Eventually(sess).Should(Exit(0))
+	return Parse(sess.Out.Contents())
+}
+
+// Discard deletes the key material of the keys generated for the specified user. It is meant for
+// when the user's account has been cancelled, which removes their keys from the controller too,
+// so the keys are no longer tracked for the reaper either.
+func Discard(user model.User) {
+	discarded, err := Default.Discard(user)
+	Expect(err).NotTo(HaveOccurred())
+	for _, key := range discarded {
+		Expect(reaper.Untrack(reaper.Resource{Kind: reaper.Key, Name: key.Name})).To(Succeed())
+	}
 }
 
-func createKey() (string, string) {
-	keyName := fmt.Sprintf("deiskey-%v", rand.Intn(1000))
-	sshHome := path.Join(settings.TestHome, ".ssh")
-	os.MkdirAll(sshHome, 0777)
-	keyPath := path.Join(sshHome, keyName)
-	if _, err := os.Stat(keyPath); os.IsNotExist(err) {
-		_, err := cmd.Execute("ssh-keygen -q -t rsa -b 4096 -C %s -f %s -N ''", keyName, keyPath)
-		Expect(err).NotTo(HaveOccurred())
+// Listing is a key as `deis keys:list` prints it. Public holds only the start and the end of the
+// public key, with the middle elided, e.g. "ssh-rsa AAAAB3Nz...Vw5jRGQ== ".
+type Listing struct {
+	Name   string
+	Public string
+}
+
+// Type returns the type of the key as written in the public key, e.g. "ssh-rsa".
+func (l Listing) Type() string {
+	return strings.SplitN(l.Public, " ", 2)[0]
+}
+
+// Is returns true if the listing is of the specified key: it has the key's name, and its public
+// key starts and ends the way the key's public key file does.
+func (l Listing) Is(key model.Key) bool {
+	if l.Name != key.Name {
+		return false
+	}
+	public, err := ioutil.ReadFile(key.PublicPath())
+	Expect(err).NotTo(HaveOccurred())
+	parts := strings.SplitN(l.Public, "...", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	line := strings.TrimSpace(string(public))
+	// The controller may or may not have kept the comment after the key itself.
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return false
+	}
+	withoutComment := strings.Join(fields[:2], " ")
+	return strings.HasPrefix(line, parts[0]) &&
+		(strings.HasSuffix(line, parts[1]) || strings.HasSuffix(withoutComment, parts[1]))
+}
+
+var (
+	keysHeadingRegexp = regexp.MustCompile(`^=== \S+ Keys$`)
+	keyLineRegexp     = regexp.MustCompile(`^(\S+) (.+\.\.\..*)$`)
+)
+
+// Parse parses the keys listed in the output of `deis keys:list`:
+//
+//	=== my-user Keys
+//	deiskey-3f2a9c1e-1-4 ssh-rsa AAAAB3Nz...Vw5jRGQ==
+//	deiskey-3f2a9c1e-1-5 ssh-ed25519 AAAA...9jZDHBwZ4b
+func Parse(output []byte) []Listing {
+	var listings []Listing
+	inKeys := false
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if keysHeadingRegexp.MatchString(line) {
+			inKeys = true
+			continue
+		}
+		if m := keyLineRegexp.FindStringSubmatch(line); inKeys && m != nil {
+			listings = append(listings, Listing{Name: m[1], Public: m[2]})
+		}
 	}
-	os.Chmod(keyPath, 0600)
-	return keyName, keyPath
+	return listings
 }
@@ -0,0 +1,91 @@
+package keys
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/deis/workflow-e2e/tests/model"
+
+	"github.com/onsi/gomega"
+)
+
+func TestParse(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		output   string
+		expected []Listing
+	}{
+		{
+			"several keys",
+			`=== my-user Keys
+deiskey-3f2a9c1e-1-4 ssh-rsa AAAAB3Nz...Vw5jRGQ==
+deiskey-3f2a9c1e-1-5 ssh-ed25519 AAAA...9jZDHBwZ4b deiskey-3f2a9c1e-1-5
+`,
+			[]Listing{
+				{Name: "deiskey-3f2a9c1e-1-4", Public: "ssh-rsa AAAAB3Nz...Vw5jRGQ=="},
+				{Name: "deiskey-3f2a9c1e-1-5", Public: "ssh-ed25519 AAAA...9jZDHBwZ4b deiskey-3f2a9c1e-1-5"},
+			},
+		},
+		{
+			"after adding a key",
+			"Uploading deiskey-3f2a9c1e-1-4.pub to deis... done\n=== my-user Keys\ndeiskey-3f2a9c1e-1-4 ssh-rsa AAAAB3Nz...Vw5jRGQ==\n",
+			[]Listing{{Name: "deiskey-3f2a9c1e-1-4", Public: "ssh-rsa AAAAB3Nz...Vw5jRGQ=="}},
+		},
+		{
+			"lines before the heading and without an elided key",
+			"deiskey-1 ssh-rsa AAAAB3Nz...Vw5jRGQ==\n=== my-user Keys\nNo keys found\n",
+			nil,
+		},
+	} {
+		if got := Parse([]byte(test.output)); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, got)
+		}
+	}
+
+	if got := (Listing{Public: "ssh-ed25519 AAAA...9jZDHBwZ4b"}).Type(); got != "ssh-ed25519" {
+		t.Errorf("expected the listing to be of an ssh-ed25519 key, got %s", got)
+	}
+}
+
+func TestListingIs(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	dir, err := ioutil.TempDir("", "keys-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	commented := model.Key{Name: "deiskey-1", Path: filepath.Join(dir, "deiskey-1")}
+	uncommented := model.Key{Name: "deiskey-2", Path: filepath.Join(dir, "deiskey-2")}
+	for key, public := range map[model.Key]string{
+		commented:   "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQDVw5jRGQ== deiskey-1\n",
+		uncommented: "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI9jZDHBwZ4b\n",
+	} {
+		if err := ioutil.WriteFile(key.PublicPath(), []byte(public), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		listing  Listing
+		key      model.Key
+		expected bool
+	}{
+		{Listing{Name: "deiskey-1", Public: "ssh-rsa AAAAB3Nz...Vw5jRGQ== deiskey-1"}, commented, true},
+		// The controller may drop the comment.
+		{Listing{Name: "deiskey-1", Public: "ssh-rsa AAAAB3Nz...Vw5jRGQ=="}, commented, true},
+		{Listing{Name: "deiskey-2", Public: "ssh-ed25519 AAAA...9jZDHBwZ4b"}, uncommented, true},
+		{Listing{Name: "deiskey-2", Public: "ssh-ed25519 AAAA...9jZDHBwZ4b deiskey-2"}, uncommented, false},
+		{Listing{Name: "deiskey-2", Public: "ssh-rsa AAAAB3Nz...Vw5jRGQ=="}, commented, false},
+		{Listing{Name: "deiskey-1", Public: "ssh-rsa AAAAB3Nz...Vw5jRGQ=="}, uncommented, false},
+		{Listing{Name: "deiskey-1", Public: "ssh-rsa AAAAB3Nz...XXXXXXX== deiskey-1"}, commented, false},
+		{Listing{Name: "deiskey-1", Public: "ssh-dss AAAAB3Nz...Vw5jRGQ=="}, commented, false},
+		{Listing{Name: "deiskey-1", Public: "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQDVw5jRGQ=="}, commented, false},
+	} {
+		if got := test.listing.Is(test.key); got != test.expected {
+			t.Errorf("%+v: expected it to be %s: %t, got %t", test.listing, test.key.Name, test.expected, got)
+		}
+	}
+}
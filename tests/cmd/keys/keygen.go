@@ -0,0 +1,123 @@
+package keys
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+)
+
+// Keys are generated in-process rather than with ssh-keygen, which is not in every image the suite
+// runs in. Generating an RSA key still takes a while, so keys of each type and size that has been
+// asked for are generated ahead of time in the background, and handed out one at a time; no key
+// is ever handed out twice.
+
+// DefaultRSABits is the size of RSA keys when no size is asked for.
+const DefaultRSABits = 2048
+
+// cacheSize is how many keys of each type and size are kept ready.
+const cacheSize = 4
+
+type keySpec struct {
+	keyType string
+	bits    int
+}
+
+type generated struct {
+	key crypto.Signer
+	err error
+}
+
+var cache = struct {
+	sync.Mutex
+	pools map[keySpec]chan generated
+}{pools: map[keySpec]chan generated{}}
+
+// NewPrivateKey returns a new private key of the given type. Bits is the size of an RSA key, and
+// defaults to DefaultRSABits if it is 0; it is ignored for ed25519 keys.
+func NewPrivateKey(keyType string, bits int) (crypto.Signer, error) {
+	spec, err := newKeySpec(keyType, bits)
+	if err != nil {
+		return nil, err
+	}
+	g := <-pool(spec)
+	return g.key, g.err
+}
+
+// Pregenerate starts generating keys of the given type and size in the background, so that they
+// are ready by the time NewPrivateKey is first asked for one.
+func Pregenerate(keyType string, bits int) error {
+	spec, err := newKeySpec(keyType, bits)
+	if err != nil {
+		return err
+	}
+	pool(spec)
+	return nil
+}
+
+func newKeySpec(keyType string, bits int) (keySpec, error) {
+	switch keyType {
+	case RSA:
+		if bits == 0 {
+			bits = DefaultRSABits
+		}
+		return keySpec{keyType: keyType, bits: bits}, nil
+	case Ed25519:
+		return keySpec{keyType: keyType}, nil
+	}
+	return keySpec{}, fmt.Errorf("unknown key type %q", keyType)
+}
+
+// pool returns the keys being generated to the spec, starting to generate them the first time the
+// spec is asked for.
+func pool(spec keySpec) chan generated {
+	cache.Lock()
+	defer cache.Unlock()
+	p, ok := cache.pools[spec]
+	if !ok {
+		p = make(chan generated, cacheSize)
+		cache.pools[spec] = p
+		go func() {
+			for {
+				key, err := generate(spec)
+				p <- generated{key: key, err: err}
+			}
+		}()
+	}
+	return p
+}
+
+func generate(spec keySpec) (crypto.Signer, error) {
+	switch spec.keyType {
+	case RSA:
+		return rsa.GenerateKey(rand.Reader, spec.bits)
+	default:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	}
+}
+
+// WriteKeyPair writes a private key to path and its public key to path.pub, both in the format
+// ssh-keygen writes them in, with the comment at the end of the public key.
+func WriteKeyPair(path string, key crypto.Signer, comment string) error {
+	block, err := MarshalPrivateKey(key, comment)
+	if err != nil {
+		return err
+	}
+	public, err := ssh.NewPublicKey(key.Public())
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		return err
+	}
+	line := bytes.TrimSuffix(ssh.MarshalAuthorizedKey(public), []byte("\n"))
+	return ioutil.WriteFile(path+".pub", []byte(fmt.Sprintf("%s %s\n", line, comment)), 0644)
+}
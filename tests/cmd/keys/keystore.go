@@ -0,0 +1,107 @@
+package keys
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/deis/workflow-e2e/tests/model"
+	"github.com/deis/workflow-e2e/tests/naming"
+	"github.com/deis/workflow-e2e/tests/settings"
+)
+
+// Types of key that can be generated.
+const (
+	RSA     = "rsa"
+	Ed25519 = "ed25519"
+)
+
+// Keystore generates SSH keys for users and keeps track of which user owns which key. Each user's
+// keys are kept in a directory of their own and every key gets a new name, so no two users ever
+// share key material, even when they are created by different Ginkgo nodes.
+type Keystore struct {
+	// Dir is the directory that holds a directory of keys per user. If it is empty,
+	// settings.TestHome/.ssh/keys is used, which is only known once the suite has started.
+	Dir string
+
+	mu   sync.Mutex
+	keys map[string]model.Key
+}
+
+// Default is the keystore the helpers in this package use.
+var Default = &Keystore{}
+
+// Generate generates a new key of the given type for the owner. Bits is the size of an RSA key,
+// and defaults to DefaultRSABits if it is 0.
+func (s *Keystore) Generate(owner model.User, keyType string, bits int) (model.Key, error) {
+	private, err := NewPrivateKey(keyType, bits)
+	if err != nil {
+		return model.Key{}, err
+	}
+	dir := s.userDir(owner)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return model.Key{}, err
+	}
+	key := model.Key{Name: naming.New("deiskey"), Type: keyType, Owner: owner.Username}
+	key.Path = filepath.Join(dir, key.Name)
+	if err := WriteKeyPair(key.Path, private, key.Name); err != nil {
+		return model.Key{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.keys == nil {
+		s.keys = map[string]model.Key{}
+	}
+	s.keys[key.Name] = key
+	return key, nil
+}
+
+// Get returns the key with the given name, if this keystore generated it.
+func (s *Keystore) Get(name string) (model.Key, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.keys[name]
+	return key, ok
+}
+
+// Owned returns the keys generated for the owner, sorted by name.
+func (s *Keystore) Owned(owner model.User) []model.Key {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var owned []model.Key
+	for _, key := range s.keys {
+		if key.Owner == owner.Username {
+			owned = append(owned, key)
+		}
+	}
+	sort.Sort(byName(owned))
+	return owned
+}
+
+// Discard deletes the key material of every key generated for the owner and forgets the keys. It
+// returns the keys it discarded.
+func (s *Keystore) Discard(owner model.User) ([]model.Key, error) {
+	owned := s.Owned(owner)
+	s.mu.Lock()
+	for _, key := range owned {
+		delete(s.keys, key.Name)
+	}
+	s.mu.Unlock()
+	return owned, os.RemoveAll(s.userDir(owner))
+}
+
+func (s *Keystore) userDir(owner model.User) string {
+	dir := s.Dir
+	if dir == "" {
+		dir = filepath.Join(settings.TestHome, ".ssh", "keys")
+	}
+	return filepath.Join(dir, owner.Username)
+}
+
+type byName []model.Key
+
+func (k byName) Len() int           { return len(k) }
+func (k byName) Less(i, j int) bool { return k[i].Name < k[j].Name }
+func (k byName) Swap(i, j int)      { k[i], k[j] = k[j], k[i] }
@@ -0,0 +1,85 @@
+package keys
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/deis/workflow-e2e/tests/model"
+	"github.com/deis/workflow-e2e/tests/naming"
+)
+
+func TestKeystore(t *testing.T) {
+	defer func(runID string) { naming.RunID = runID }(naming.RunID)
+	naming.RunID = "abc"
+	dir, err := ioutil.TempDir("", "keys-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := &Keystore{Dir: dir}
+	alice, bob := model.User{Username: "alice"}, model.User{Username: "bob"}
+
+	var generated []model.Key
+	for _, owner := range []model.User{alice, bob, alice} {
+		key, err := store.Generate(owner, Ed25519, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key.Owner != owner.Username || key.Type != Ed25519 || key.Path != filepath.Join(dir, owner.Username, key.Name) {
+			t.Errorf("expected an ed25519 key of %s in its own directory, got %+v", owner.Username, key)
+		}
+		for _, path := range []string{key.Path, key.PublicPath()} {
+			if _, err := os.Stat(path); err != nil {
+				t.Errorf("expected %s to be written: %s", path, err)
+			}
+		}
+		generated = append(generated, key)
+	}
+	if generated[0].Name == generated[2].Name {
+		t.Errorf("expected each key to get a new name, got %s twice", generated[0].Name)
+	}
+	if _, err := store.Generate(alice, "dsa", 0); err == nil {
+		t.Error("expected generating a key of an unknown type to fail")
+	}
+
+	if key, ok := store.Get(generated[1].Name); !ok || key != generated[1] {
+		t.Errorf("expected to get %+v, got %+v (%t)", generated[1], key, ok)
+	}
+	if _, ok := store.Get("deiskey-xyz-1-1"); ok {
+		t.Error("expected not to get a key the keystore did not generate")
+	}
+	owned := store.Owned(alice)
+	if len(owned) != 2 || owned[0].Name > owned[1].Name || owned[0].Owner != "alice" || owned[1].Owner != "alice" {
+		t.Errorf("expected alice's two keys sorted by name, got %+v", owned)
+	}
+
+	// Discarding alice's keys forgets them and deletes their files, and leaves bob's alone.
+	discarded, err := store.Discard(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(discarded) != 2 || discarded[0] != owned[0] || discarded[1] != owned[1] {
+		t.Errorf("expected alice's keys %+v to be discarded, got %+v", owned, discarded)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "alice")); !os.IsNotExist(err) {
+		t.Errorf("expected alice's keys to be deleted, got %v", err)
+	}
+	if keys := store.Owned(alice); len(keys) != 0 {
+		t.Errorf("expected alice to own no keys, got %+v", keys)
+	}
+	if _, ok := store.Get(generated[0].Name); ok {
+		t.Errorf("expected %s to be forgotten", generated[0].Name)
+	}
+	if keys := store.Owned(bob); len(keys) != 1 || keys[0] != generated[1] {
+		t.Errorf("expected bob to own %+v, got %+v", generated[1], keys)
+	}
+	if _, err := os.Stat(generated[1].Path); err != nil {
+		t.Errorf("expected bob's key to be kept: %s", err)
+	}
+
+	if discarded, err := store.Discard(model.User{Username: "carol"}); err != nil || len(discarded) != 0 {
+		t.Errorf("expected discarding the keys of a user without any to do nothing, got %+v (%v)", discarded, err)
+	}
+}
@@ -0,0 +1,108 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+)
+
+// The x/crypto revision in glide.lock has no way to write OpenSSH private keys, so this file
+// writes them itself. The format is described in PROTOCOL.key in the OpenSSH sources; keys are
+// written unencrypted, as `ssh-keygen -N ""` writes them.
+
+const opensshMagic = "openssh-key-v1\x00"
+
+type opensshKey struct {
+	CipherName   string
+	KdfName      string
+	KdfOpts      string
+	NumKeys      uint32
+	PubKey       []byte
+	PrivKeyBlock []byte
+}
+
+type opensshPrivateKeys struct {
+	Check1  uint32
+	Check2  uint32
+	Keytype string
+	Rest    []byte `ssh:"rest"`
+}
+
+type opensshRSAKey struct {
+	N       *big.Int
+	E       *big.Int
+	D       *big.Int
+	Iqmp    *big.Int
+	P       *big.Int
+	Q       *big.Int
+	Comment string
+}
+
+type opensshEd25519Key struct {
+	Pub     []byte
+	Priv    []byte
+	Comment string
+}
+
+// MarshalPrivateKey returns a PEM block holding an RSA or ed25519 private key in the format
+// ssh-keygen writes new keys in, with the comment.
+func MarshalPrivateKey(key crypto.Signer, comment string) (*pem.Block, error) {
+	public, err := ssh.NewPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	var fields []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if len(k.Primes) != 2 {
+			return nil, fmt.Errorf("RSA keys with %d primes are not supported", len(k.Primes))
+		}
+		k.Precompute()
+		fields = ssh.Marshal(opensshRSAKey{
+			N:       k.N,
+			E:       big.NewInt(int64(k.E)),
+			D:       k.D,
+			Iqmp:    k.Precomputed.Qinv,
+			P:       k.Primes[0],
+			Q:       k.Primes[1],
+			Comment: comment,
+		})
+	case ed25519.PrivateKey:
+		fields = ssh.Marshal(opensshEd25519Key{
+			Pub:     []byte(k[ed25519.PrivateKeySize-ed25519.PublicKeySize:]),
+			Priv:    []byte(k),
+			Comment: comment,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+
+	var check [4]byte
+	if _, err := rand.Read(check[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(check[:])
+	block := ssh.Marshal(opensshPrivateKeys{Check1: n, Check2: n, Keytype: public.Type(), Rest: fields})
+	// Unencrypted keys are padded to a multiple of 8 bytes with 1, 2, 3...
+	for i := byte(1); len(block)%8 != 0; i++ {
+		block = append(block, i)
+	}
+
+	return &pem.Block{
+		Type: "OPENSSH PRIVATE KEY",
+		Bytes: append([]byte(opensshMagic), ssh.Marshal(opensshKey{
+			CipherName:   "none",
+			KdfName:      "none",
+			NumKeys:      1,
+			PubKey:       public.Marshal(),
+			PrivKeyBlock: block,
+		})...),
+	}, nil
+}
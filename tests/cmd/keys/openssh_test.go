@@ -0,0 +1,67 @@
+package keys
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestWriteKeyPair(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keys-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, spec := range []keySpec{{keyType: RSA, bits: 1024}, {keyType: Ed25519}} {
+		key, err := generate(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, spec.keyType)
+		if err := WriteKeyPair(path, key, "deis@example.com"); err != nil {
+			t.Fatalf("%s: %s", spec.keyType, err)
+		}
+
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signer, err := ssh.ParsePrivateKey(contents)
+		if err != nil {
+			t.Fatalf("%s: %s", spec.keyType, err)
+		}
+		public, err := ssh.NewPublicKey(key.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(signer.PublicKey().Marshal(), public.Marshal()) {
+			t.Errorf("%s: expected to read back the key that was written", spec.keyType)
+		}
+
+		line, err := ioutil.ReadFile(path + ".pub")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, comment, _, _, err := ssh.ParseAuthorizedKey(line)
+		if err != nil || comment != "deis@example.com" {
+			t.Errorf("%s: expected a public key with a comment, got %q (%v)", spec.keyType, line, err)
+		}
+
+		// ssh-keygen reads the key the way ssh will, if it is here to ask.
+		if _, err := exec.LookPath("ssh-keygen"); err != nil {
+			continue
+		}
+		derived, err := exec.Command("ssh-keygen", "-y", "-f", path).CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s: ssh-keygen could not read the key: %s\n%s", spec.keyType, err, derived)
+		}
+		if expected := bytes.Fields(line)[1]; !bytes.Equal(bytes.Fields(derived)[1], expected) {
+			t.Errorf("%s: expected ssh-keygen to derive %s, got %s", spec.keyType, expected, derived)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package limits
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/deis/workflow-e2e/tests/cmd"
+	"github.com/deis/workflow-e2e/tests/k8s"
+	"github.com/deis/workflow-e2e/tests/model"
+	"github.com/deis/workflow-e2e/tests/settings"
+
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gbytes"
+	. "github.com/onsi/gomega/gexec"
+)
+
+// The functions in this file implement SUCCESS CASES for commonly used `deis limits` subcommands.
+// This allows each of these to be re-used easily in multiple contexts.
+
+// Resource is a kind of resource that can be limited: memory or CPU.
+type Resource string
+
+// Kinds of resource.
+const (
+	Memory Resource = "memory"
+	CPU    Resource = "cpu"
+)
+
+// Limit is the request and limit for a resource, in the units `deis limits:set` takes, e.g. "64M"
+// or "500m". An empty Request means the same as the Limit.
+type Limit struct {
+	Request string
+	Limit   string
+}
+
+// String returns the limit as `deis limits:set` takes it and `deis limits:list` prints it.
+func (l Limit) String() string {
+	if l.Request == "" {
+		return l.Limit
+	}
+	return l.Request + "/" + l.Limit
+}
+
+// Requirements returns the resource requirements Kubernetes should give a container with this
+// limit. The controller treats memory units as powers of 1024, so "64M" becomes "64Mi".
+func (l Limit) Requirements(resource Resource) k8s.ResourceRequirements {
+	request := l.Request
+	if request == "" {
+		request = l.Limit
+	}
+	return k8s.ResourceRequirements{
+		Requests: map[string]string{string(resource): quantity(resource, request)},
+		Limits:   map[string]string{string(resource): quantity(resource, l.Limit)},
+	}
+}
+
+var memoryUnitRegexp = regexp.MustCompile(`^(\d+)([KMG])B?$`)
+
+func quantity(resource Resource, value string) string {
+	if resource == Memory {
+		if m := memoryUnitRegexp.FindStringSubmatch(strings.ToUpper(value)); m != nil {
+			return m[1] + m[2] + "i"
+		}
+	}
+	return value
+}
+
+// Limits holds the limits of an app, by process type.
+type Limits struct {
+	Memory map[string]Limit
+	CPU    map[string]Limit
+}
+
+// Set executes `deis limits:set` as the specified user to limit the resource for a process type
+// of the specified app, and returns the limits listed afterwards.
+func Set(user model.User, app model.App, resource Resource, procType string, limit Limit) Limits {
+	sess, err := cmd.Start("deis limits:set --%s %s=%s -a %s", &user, resource, procType, limit, app.Name)
+	Expect(err).NotTo(HaveOccurred())
+	Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Limits", app.Name))
+	Eventually(sess).Should(Exit(0))
+	return Parse(sess.Out.Contents())
+}
+
+// Unset executes `deis limits:unset` as the specified user to remove the limit on the resource for
+// a process type of the specified app, and returns the limits listed afterwards.
+func Unset(user model.User, app model.App, resource Resource, procType string) Limits {
+	sess, err := cmd.Start("deis limits:unset --%s %s -a %s", &user, resource, procType, app.Name)
+	Expect(err).NotTo(HaveOccurred())
+	Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Limits", app.Name))
+	Eventually(sess).Should(Exit(0))
+	return Parse(sess.Out.Contents())
+}
+
+// List executes `deis limits:list` as the specified user on the specified app and returns the
+// limits it lists.
+func List(user model.User, app model.App) Limits {
+	sess, err := cmd.Start("deis limits:list -a %s", &user, app.Name)
+	Expect(err).NotTo(HaveOccurred())
+	Eventually(sess).Should(Say("=== %s Limits", app.Name))
+	Eventually(sess).Should(Exit(0))
+	return Parse(sess.Out.Contents())
+}
+
+var limitRegexp = regexp.MustCompile(`^(\S+)\s+(\S+)$`)
+
+// Parse parses the limits listed in the output of `deis limits:list`, which `deis limits:set`
+// and `deis limits:unset` also print:
+//
+//	=== my-app Limits
+//
+//	--- Memory
+//	cmd     50M/100M
+//
+//	--- CPU
+//	Unlimited
+func Parse(output []byte) Limits {
+	limits := Limits{Memory: map[string]Limit{}, CPU: map[string]Limit{}}
+	var section map[string]Limit
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch line {
+		case "--- Memory":
+			section = limits.Memory
+			continue
+		case "--- CPU":
+			section = limits.CPU
+			continue
+		}
+		m := limitRegexp.FindStringSubmatch(line)
+		if section == nil || m == nil {
+			continue
+		}
+		limit := Limit{Limit: m[2]}
+		if parts := strings.SplitN(m[2], "/", 2); len(parts) == 2 {
+			limit = Limit{Request: parts[0], Limit: parts[1]}
+		}
+		section[m[1]] = limit
+	}
+	return limits
+}
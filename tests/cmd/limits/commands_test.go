@@ -0,0 +1,92 @@
+package limits
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/deis/workflow-e2e/tests/k8s"
+)
+
+func TestParse(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		output   string
+		expected Limits
+	}{
+		{
+			"unlimited",
+			"=== my-app Limits\n\n--- Memory\nUnlimited\n\n--- CPU\nUnlimited\n",
+			Limits{Memory: map[string]Limit{}, CPU: map[string]Limit{}},
+		},
+		{
+			"memory only",
+			"Applying limits... done\n\n=== my-app Limits\n\n--- Memory\ncmd     64M\n\n--- CPU\nUnlimited\n",
+			Limits{Memory: map[string]Limit{"cmd": {Limit: "64M"}}, CPU: map[string]Limit{}},
+		},
+		{
+			"memory and CPU with requests",
+			"=== my-app Limits\n\n--- Memory\ncmd       50M/100M\nworker    1G\n\n--- CPU\ncmd       250m/500m\nworker    2\n",
+			Limits{
+				Memory: map[string]Limit{"cmd": {Request: "50M", Limit: "100M"}, "worker": {Limit: "1G"}},
+				CPU:    map[string]Limit{"cmd": {Request: "250m", Limit: "500m"}, "worker": {Limit: "2"}},
+			},
+		},
+		{
+			"lines outside a section",
+			"cmd    64M\n=== my-app Limits\n--- CPU\nweb    1000m\n",
+			Limits{Memory: map[string]Limit{}, CPU: map[string]Limit{"web": {Limit: "1000m"}}},
+		},
+	} {
+		if got := Parse([]byte(test.output)); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestLimitString(t *testing.T) {
+	for _, test := range []struct {
+		limit    Limit
+		expected string
+	}{
+		{Limit{Limit: "64M"}, "64M"},
+		{Limit{Request: "50M", Limit: "100M"}, "50M/100M"},
+	} {
+		if got := test.limit.String(); got != test.expected {
+			t.Errorf("%+v: expected %s, got %s", test.limit, test.expected, got)
+		}
+	}
+}
+
+func TestRequirements(t *testing.T) {
+	requirements := func(resource Resource, request, limit string) k8s.ResourceRequirements {
+		return k8s.ResourceRequirements{
+			Requests: map[string]string{string(resource): request},
+			Limits:   map[string]string{string(resource): limit},
+		}
+	}
+	for _, test := range []struct {
+		resource Resource
+		limit    Limit
+		expected k8s.ResourceRequirements
+	}{
+		// Memory units are powers of 1024, however they are written.
+		{Memory, Limit{Limit: "64M"}, requirements(Memory, "64Mi", "64Mi")},
+		{Memory, Limit{Request: "50M", Limit: "100M"}, requirements(Memory, "50Mi", "100Mi")},
+		{Memory, Limit{Limit: "512K"}, requirements(Memory, "512Ki", "512Ki")},
+		{Memory, Limit{Limit: "1G"}, requirements(Memory, "1Gi", "1Gi")},
+		{Memory, Limit{Limit: "64m"}, requirements(Memory, "64Mi", "64Mi")},
+		{Memory, Limit{Limit: "64MB"}, requirements(Memory, "64Mi", "64Mi")},
+		{Memory, Limit{Request: "64mb", Limit: "1gb"}, requirements(Memory, "64Mi", "1Gi")},
+		// Quantities Kubernetes already understands are left alone.
+		{Memory, Limit{Limit: "64Mi"}, requirements(Memory, "64Mi", "64Mi")},
+		{Memory, Limit{Limit: "1048576"}, requirements(Memory, "1048576", "1048576")},
+		// CPU units are not converted; "m" means millicores.
+		{CPU, Limit{Limit: "500m"}, requirements(CPU, "500m", "500m")},
+		{CPU, Limit{Request: "250m", Limit: "1"}, requirements(CPU, "250m", "1")},
+		{CPU, Limit{Limit: "2"}, requirements(CPU, "2", "2")},
+	} {
+		if got := test.limit.Requirements(test.resource); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s %s: expected %+v, got %+v", test.resource, test.limit, test.expected, got)
+		}
+	}
+}
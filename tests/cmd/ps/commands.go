@@ -0,0 +1,158 @@
+package ps
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deis/workflow-e2e/tests/api"
+	"github.com/deis/workflow-e2e/tests/cmd"
+	"github.com/deis/workflow-e2e/tests/model"
+	"github.com/deis/workflow-e2e/tests/settings"
+
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gbytes"
+	. "github.com/onsi/gomega/gexec"
+)
+
+// The functions in this file implement SUCCESS CASES for commonly used `deis ps` subcommands.
+// This allows each of these to be re-used easily in multiple contexts.
+
+// pollInterval is how often WaitForState lists an app's processes.
+var pollInterval = 5 * time.Second
+
+// Scale executes `deis ps:scale` as the specified user to scale each process type of the
+// specified app to the given count, and returns the processes listed afterwards.
+func Scale(user model.User, app model.App, counts map[string]int) []model.Process {
+	types := make([]string, 0, len(counts))
+	for procType := range counts {
+		types = append(types, procType)
+	}
+	sort.Strings(types)
+	args := make([]string, len(types))
+	for i, procType := range types {
+		args[i] = fmt.Sprintf("%s=%d", procType, counts[procType])
+	}
+
+	sess, err := cmd.Start("deis ps:scale %s --app=%s", &user, strings.Join(args, " "), app.Name)
+	Expect(err).NotTo(HaveOccurred())
+	Eventually(sess).Should(Say("Scaling processes... but first,"))
+	Eventually(sess, settings.MaxEventuallyTimeout).Should(Say(`done in \d+s`))
+	Eventually(sess).Should(Say("=== %s Processes", app.Name))
+	Eventually(sess).Should(Exit(0))
+	return Parse(sess.Out.Contents())
+}
+
+// Restart executes `deis ps:restart` as the specified user to restart processes of the specified
+// app. The target is a process type, the name of a single process, or "" for every process. It
+// returns the processes that were restarted, which is none if the target matched nothing.
+func Restart(user model.User, app model.App, target string) []model.Process {
+	sess, err := cmd.Start("deis ps:restart %s --app=%s", &user, target, app.Name)
+	Expect(err).NotTo(HaveOccurred())
+	Eventually(sess).Should(Say("Restarting processes... but first,"))
+	Eventually(sess, settings.MaxEventuallyTimeout).Should(Exit(0))
+	if bytes.Contains(sess.Out.Contents(), []byte("Could not find any processes to restart")) {
+		return nil
+	}
+	Expect(sess).To(Say(`done in \d+s`))
+	return Parse(sess.Out.Contents())
+}
+
+// List executes `deis ps:list` as the specified user on the specified app and returns the
+// processes it lists. The CLI does not print when processes started, so Started is filled in from
+// the controller's API.
+func List(user model.User, app model.App) []model.Process {
+	sess, err := cmd.Start("deis ps:list --app=%s", &user, app.Name)
+	Expect(err).NotTo(HaveOccurred())
+	Eventually(sess).Should(Say("=== %s Processes", app.Name))
+	Eventually(sess).Should(Exit(0))
+	procs := Parse(sess.Out.Contents())
+
+	client, err := api.LoadProfile(user.Username)
+	Expect(err).NotTo(HaveOccurred())
+	pods, err := client.Pods(app.Name)
+	Expect(err).NotTo(HaveOccurred())
+	started := map[string]time.Time{}
+	for _, pod := range pods {
+		if t, err := api.ParseTime(pod.Started); err == nil {
+			started[pod.Name] = t
+		}
+	}
+	for i := range procs {
+		procs[i].Started = started[procs[i].Name]
+	}
+	return procs
+}
+
+// WaitForState lists the processes of the specified app until exactly count processes of the
+// given type are up and none of that type are in any other state, and returns them.
+func WaitForState(user model.User, app model.App, procType string, count int) []model.Process {
+	var procs []model.Process
+	Eventually(func() []model.Process {
+		procs = OfType(List(user, app), procType)
+		return procs
+	}, settings.MaxEventuallyTimeout, pollInterval).Should(SatisfyAll(
+		HaveLen(count),
+		Not(ContainElement(WithTransform(func(p model.Process) string { return p.State }, Not(Equal("up"))))),
+	), "waiting for %d %s processes of %s to be up", count, procType, app.Name)
+	return procs
+}
+
+// OfType returns the processes of the given type.
+func OfType(procs []model.Process, procType string) []model.Process {
+	var matching []model.Process
+	for _, p := range procs {
+		if p.Type == procType {
+			matching = append(matching, p)
+		}
+	}
+	return matching
+}
+
+// Names returns the sorted names of the processes.
+func Names(procs []model.Process) []string {
+	names := make([]string, len(procs))
+	for i, p := range procs {
+		names[i] = p.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+var (
+	typeRegexp    = regexp.MustCompile(`^--- (\S+):$`)
+	processRegexp = regexp.MustCompile(`^(\S+) (\S+) \(v(\d+)\)$`)
+)
+
+// Parse parses the processes listed in the output of `deis ps:list`, which `deis ps:scale` and
+// `deis ps:restart` also print:
+//
+//	=== my-app Processes
+//	--- web:
+//	my-app-web-3378863473-8lbk6 up (v3)
+//	--- worker:
+//	my-app-worker-1620279125-uvgqb starting (v3)
+func Parse(output []byte) []model.Process {
+	var procs []model.Process
+	var procType string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if m := typeRegexp.FindStringSubmatch(line); m != nil {
+			procType = m[1]
+			continue
+		}
+		m := processRegexp.FindStringSubmatch(line)
+		if procType == "" || m == nil {
+			continue
+		}
+		release, _ := strconv.Atoi(m[3])
+		procs = append(procs, model.Process{Name: m[1], Type: procType, State: m[2], Release: release})
+	}
+	return procs
+}
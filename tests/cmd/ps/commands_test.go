@@ -0,0 +1,77 @@
+package ps
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/deis/workflow-e2e/tests/model"
+)
+
+func TestParse(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		output   string
+		expected []model.Process
+	}{
+		{
+			"a single process",
+			"=== my-app Processes\n--- cmd:\nmy-app-cmd-3378863473-8lbk6 up (v2)\n",
+			[]model.Process{{Name: "my-app-cmd-3378863473-8lbk6", Type: "cmd", State: "up", Release: 2}},
+		},
+		{
+			"several types in several states",
+			`Scaling processes... but first, coffee!
+done in 34s
+=== my-app Processes
+--- web:
+my-app-web-3378863473-8lbk6 up (v3)
+my-app-web-3378863473-x2m4q terminating (v3)
+--- worker:
+my-app-worker-1620279125-uvgqb starting (v12)
+my-app-worker-1620279125-0ahz1 crashed (v11)
+--- run-2:
+my-app-run-2-7438596512-d9c7w down (v3)
+`,
+			[]model.Process{
+				{Name: "my-app-web-3378863473-8lbk6", Type: "web", State: "up", Release: 3},
+				{Name: "my-app-web-3378863473-x2m4q", Type: "web", State: "terminating", Release: 3},
+				{Name: "my-app-worker-1620279125-uvgqb", Type: "worker", State: "starting", Release: 12},
+				{Name: "my-app-worker-1620279125-0ahz1", Type: "worker", State: "crashed", Release: 11},
+				{Name: "my-app-run-2-7438596512-d9c7w", Type: "run-2", State: "down", Release: 3},
+			},
+		},
+		{
+			"no processes",
+			"=== my-app Processes\n",
+			nil,
+		},
+		{
+			"nothing to restart",
+			"Restarting processes... but first, coffee!\nCould not find any processes to restart\n",
+			nil,
+		},
+		{
+			"processes before a type heading",
+			"=== my-app Processes\nmy-app-cmd-3378863473-8lbk6 up (v2)\n",
+			nil,
+		},
+	} {
+		if got := Parse([]byte(test.output)); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestOfTypeAndNames(t *testing.T) {
+	procs := []model.Process{
+		{Name: "my-app-web-2", Type: "web"},
+		{Name: "my-app-worker-1", Type: "worker"},
+		{Name: "my-app-web-1", Type: "web"},
+	}
+	if got := Names(OfType(procs, "web")); !reflect.DeepEqual(got, []string{"my-app-web-1", "my-app-web-2"}) {
+		t.Errorf("expected the web processes by name, got %q", got)
+	}
+	if got := OfType(procs, "cmd"); got != nil {
+		t.Errorf("expected no cmd processes, got %+v", got)
+	}
+}
@@ -0,0 +1,207 @@
+package releases
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/deis/workflow-e2e/tests/api"
+	"github.com/deis/workflow-e2e/tests/cmd"
+	"github.com/deis/workflow-e2e/tests/model"
+	"github.com/deis/workflow-e2e/tests/settings"
+
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gbytes"
+	. "github.com/onsi/gomega/gexec"
+)
+
+// The functions in this file implement SUCCESS CASES for commonly used `deis releases`
+// subcommands. This allows each of these to be re-used easily in multiple contexts.
+
+// List executes `deis releases:list` as the specified user on the specified app and returns the
+// releases it lists, newest first. `deis releases:list` does not print owners, build or config, so
+// only the Version, Summary and Created fields are set.
+func List(user model.User, app model.App) []model.Release {
+	sess, err := cmd.Start("deis releases:list -a %s", &user, app.Name)
+	Expect(err).NotTo(HaveOccurred())
+	Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Releases", app.Name))
+	Eventually(sess).Should(Exit(0))
+	return ParseList(sess.Out.Contents())
+}
+
+// Info executes `deis releases:info` as the specified user to describe a release of the specified
+// app.
+func Info(user model.User, app model.App, version int) model.Release {
+	sess, err := cmd.Start("deis releases:info v%d -a %s", &user, version, app.Name)
+	Expect(err).NotTo(HaveOccurred())
+	Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Release v%d", app.Name, version))
+	Eventually(sess).Should(Exit(0))
+	release, err := ParseInfo(sess.Out.Contents())
+	Expect(err).NotTo(HaveOccurred())
+	return release
+}
+
+// Latest returns the newest release of the specified app, as described by `deis releases:info`.
+func Latest(user model.User, app model.App) model.Release {
+	releases := List(user, app)
+	Expect(releases).NotTo(BeEmpty(), "%s has no releases", app.Name)
+	return Info(user, app, releases[0].Version)
+}
+
+// Rollback executes `deis releases:rollback` as the specified user to roll the specified app back
+// to a release, and returns the release the rollback created.
+func Rollback(user model.User, app model.App, version int) model.Release {
+	sess, err := cmd.Start("deis releases:rollback v%d -a %s", &user, version, app.Name)
+	Expect(err).NotTo(HaveOccurred())
+	Eventually(sess).Should(Say("Rolling back to"))
+	Eventually(sess, settings.MaxEventuallyTimeout).Should(Say(`...done`))
+	Eventually(sess).Should(Exit(0))
+	return Latest(user, app)
+}
+
+// Snapshot is what a release deploys: the release itself, the config it runs with and the image
+// of its build. A rollback to the release should deploy exactly the same again.
+type Snapshot struct {
+	Release model.Release
+	Config  api.Config
+	Image   string
+}
+
+// Take records what the latest release of the specified app deploys, so that a later rollback to
+// it can be checked with ExpectRollback. The controller only serves the current config, so this
+// must be called while the release is the latest one.
+func Take(user model.User, app model.App) Snapshot {
+	client, err := api.LoadProfile(user.Username)
+	Expect(err).NotTo(HaveOccurred())
+	release := Latest(user, app)
+	config, err := client.Config(app.Name)
+	Expect(err).NotTo(HaveOccurred())
+	return Snapshot{Release: release, Config: config, Image: image(client, app, release)}
+}
+
+// ExpectRollback rolls the specified app back to the release in the snapshot as the specified
+// user, and asserts that the rollback created the next version and that it deploys the same
+// build, image and config as the snapshotted release did. It returns the release the rollback
+// created.
+func ExpectRollback(user model.User, app model.App, target Snapshot) model.Release {
+	before := Latest(user, app)
+	release := Rollback(user, app, target.Release.Version)
+
+	Expect(release.Version).To(Equal(before.Version+1),
+		"rolling %s back to %s did not create the next version", app.Name, target.Release)
+	Expect(release.Summary).To(ContainSubstring("rolled back to %s", target.Release))
+	Expect(release.Owner).To(Equal(user.Username))
+	Expect(release.Build).To(Equal(target.Release.Build), "%s does not deploy the build of %s", release, target.Release)
+	Expect(release.Config).To(Equal(target.Release.Config), "%s does not deploy the config of %s", release, target.Release)
+
+	client, err := api.LoadProfile(user.Username)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(image(client, app, release)).To(Equal(target.Image), "%s does not deploy the image of %s", release, target.Release)
+	config, err := client.Config(app.Name)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(config.UUID).To(Equal(target.Config.UUID))
+	Expect(config.Values).To(Equal(target.Config.Values), "%s does not run with the config values of %s", release, target.Release)
+	Expect(config.Memory).To(Equal(target.Config.Memory))
+	Expect(config.CPU).To(Equal(target.Config.CPU))
+	Expect(config.Tags).To(Equal(target.Config.Tags))
+	return release
+}
+
+// image returns the image of the build a release deploys, or "" if it deploys none.
+func image(client *api.Client, app model.App, release model.Release) string {
+	if release.Build == "" {
+		return ""
+	}
+	builds, err := client.Builds(app.Name)
+	Expect(err).NotTo(HaveOccurred())
+	var img string
+	found := false
+	for _, build := range builds {
+		if build.UUID == release.Build {
+			img, found = build.Image, true
+		}
+	}
+	Expect(found).To(BeTrue(), "%s of %s deploys build %s, which the controller does not list", release, app.Name, release.Build)
+	return img
+}
+
+var listRegexp = regexp.MustCompile(`^v(\d+)\s+(\S+)\s+(.*)$`)
+
+// ParseList parses the output of `deis releases:list`:
+//
+//	=== my-app Releases
+//	v3      2016-08-01T17:40:33UTC    admin rolled back to v1
+//	v2      2016-08-01T17:38:02UTC    admin deployed deis/example-dockerfile-http
+//	v1      2016-08-01T17:37:45UTC    admin created initial release
+func ParseList(output []byte) []model.Release {
+	var releases []model.Release
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		m := listRegexp.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if m == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(m[1])
+		created, _ := api.ParseTime(m[2])
+		releases = append(releases, model.Release{Version: version, Created: created, Summary: strings.TrimSpace(m[3])})
+	}
+	return releases
+}
+
+var infoHeadingRegexp = regexp.MustCompile(`^=== \S+ Release v(\d+)$`)
+
+// ParseInfo parses the output of `deis releases:info`:
+//
+//	=== my-app Release v2
+//	build:   3d26ee25-8f8b-4e5b-a8d8-86a4d7bf5d5b
+//	config:  5b4e5f36-3ea0-4fc1-b0b4-d7cb0c3e7a8e
+//	created: 2016-08-01T17:38:02UTC
+//	owner:   admin
+//	summary: admin deployed deis/example-dockerfile-http
+//	updated: 2016-08-01T17:38:02UTC
+//	uuid:    0c4b7e38-4a5e-4ff5-86d4-b6e0e9ed9e31
+func ParseInfo(output []byte) (model.Release, error) {
+	var release model.Release
+	var found bool
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if m := infoHeadingRegexp.FindStringSubmatch(line); m != nil {
+			release.Version, _ = strconv.Atoi(m[1])
+			found = true
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if !found || len(parts) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(parts[1])
+		var err error
+		switch parts[0] {
+		case "build":
+			release.Build = value
+		case "config":
+			release.Config = value
+		case "owner":
+			release.Owner = value
+		case "summary":
+			release.Summary = value
+		case "uuid":
+			release.UUID = value
+		case "created":
+			release.Created, err = api.ParseTime(value)
+		case "updated":
+			release.Updated, err = api.ParseTime(value)
+		}
+		if err != nil {
+			return release, err
+		}
+	}
+	if !found {
+		return release, fmt.Errorf("no release in %q", output)
+	}
+	return release, nil
+}
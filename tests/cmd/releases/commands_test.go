@@ -0,0 +1,160 @@
+package releases
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deis/workflow-e2e/tests/model"
+)
+
+func TestParseList(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		output   string
+		expected []model.Release
+	}{
+		{
+			"initial release only",
+			"=== my-app Releases\nv1      2016-08-01T17:37:45UTC    admin created initial release\n",
+			[]model.Release{
+				{Version: 1, Created: time.Date(2016, 8, 1, 17, 37, 45, 0, time.UTC), Summary: "admin created initial release"},
+			},
+		},
+		{
+			"a rollback, newest first",
+			`=== my-app Releases
+v3      2016-08-01T17:40:33UTC    admin rolled back to v1
+v2      2016-08-01T17:38:02UTC    admin deployed deis/example-dockerfile-http
+v1      2016-08-01T17:37:45UTC    admin created initial release
+`,
+			[]model.Release{
+				{Version: 3, Created: time.Date(2016, 8, 1, 17, 40, 33, 0, time.UTC), Summary: "admin rolled back to v1"},
+				{Version: 2, Created: time.Date(2016, 8, 1, 17, 38, 2, 0, time.UTC), Summary: "admin deployed deis/example-dockerfile-http"},
+				{Version: 1, Created: time.Date(2016, 8, 1, 17, 37, 45, 0, time.UTC), Summary: "admin created initial release"},
+			},
+		},
+		{
+			"RFC 3339 timestamps",
+			"=== my-app Releases\nv12     2016-08-01T17:38:02.123456Z    bob added FOO, BAR   \n",
+			[]model.Release{
+				{Version: 12, Created: time.Date(2016, 8, 1, 17, 38, 2, 123456000, time.UTC), Summary: "bob added FOO, BAR"},
+			},
+		},
+		{
+			"an unrecognized timestamp",
+			"=== my-app Releases\nv1      yesterday    admin created initial release\n",
+			[]model.Release{{Version: 1, Summary: "admin created initial release"}},
+		},
+		{
+			"no releases",
+			"=== my-app Releases\n\nNo releases found.\n",
+			nil,
+		},
+	} {
+		if got := ParseList([]byte(test.output)); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestParseInfo(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		output   string
+		expected model.Release
+		// err is part of the error expected, if any.
+		err string
+	}{
+		{
+			"a deploy",
+			`=== my-app Release v2
+build:   3d26ee25-8f8b-4e5b-a8d8-86a4d7bf5d5b
+config:  5b4e5f36-3ea0-4fc1-b0b4-d7cb0c3e7a8e
+created: 2016-08-01T17:38:02UTC
+owner:   admin
+summary: admin deployed deis/example-dockerfile-http
+updated: 2016-08-01T17:38:03UTC
+uuid:    0c4b7e38-4a5e-4ff5-86d4-b6e0e9ed9e31
+`,
+			model.Release{
+				Version: 2,
+				Build:   "3d26ee25-8f8b-4e5b-a8d8-86a4d7bf5d5b",
+				Config:  "5b4e5f36-3ea0-4fc1-b0b4-d7cb0c3e7a8e",
+				Created: time.Date(2016, 8, 1, 17, 38, 2, 0, time.UTC),
+				Owner:   "admin",
+				Summary: "admin deployed deis/example-dockerfile-http",
+				Updated: time.Date(2016, 8, 1, 17, 38, 3, 0, time.UTC),
+				UUID:    "0c4b7e38-4a5e-4ff5-86d4-b6e0e9ed9e31",
+			},
+			"",
+		},
+		{
+			"a rollback with RFC 3339 timestamps",
+			`Rolling back to v1... done
+
+=== my-app Release v3
+build:
+config:  4f6bc0b0-e1b4-4f9d-bc39-d1ee1e3c9b6f
+created: 2016-08-01T17:40:33.5Z
+owner:   bob
+summary: bob rolled back to v1
+updated: 2016-08-01T17:40:33.5+00:00
+uuid:    9a8f9f4e-0f52-4bd6-8d1e-c29f6b7f6e0d
+`,
+			model.Release{
+				Version: 3,
+				Config:  "4f6bc0b0-e1b4-4f9d-bc39-d1ee1e3c9b6f",
+				Created: time.Date(2016, 8, 1, 17, 40, 33, 500000000, time.UTC),
+				Owner:   "bob",
+				Summary: "bob rolled back to v1",
+				Updated: time.Date(2016, 8, 1, 17, 40, 33, 500000000, time.UTC),
+				UUID:    "9a8f9f4e-0f52-4bd6-8d1e-c29f6b7f6e0d",
+			},
+			"",
+		},
+		{
+			"a summary holding colons",
+			"=== my-app Release v4\nsummary: admin changed limits for cmd: 64M\n",
+			model.Release{Version: 4, Summary: "admin changed limits for cmd: 64M"},
+			"",
+		},
+		{
+			"fields before the heading",
+			"owner: mallory\n=== my-app Release v1\nowner: admin\n",
+			model.Release{Version: 1, Owner: "admin"},
+			"",
+		},
+		{
+			"an unrecognized timestamp",
+			"=== my-app Release v1\ncreated: yesterday\n",
+			model.Release{},
+			`unrecognized time "yesterday"`,
+		},
+		{
+			"no release",
+			"Error: Not found.\n",
+			model.Release{},
+			"no release in",
+		},
+	} {
+		got, err := ParseInfo([]byte(test.output))
+		switch {
+		case test.err != "":
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: expected an error containing %q, got %v", test.name, test.err, err)
+			}
+		case err != nil:
+			t.Errorf("%s: %s", test.name, err)
+		case !got.Created.Equal(test.expected.Created) || !got.Updated.Equal(test.expected.Updated):
+			t.Errorf("%s: expected to be created %s and updated %s, got %s and %s", test.name,
+				test.expected.Created, test.expected.Updated, got.Created, got.Updated)
+		default:
+			got.Created, got.Updated = test.expected.Created, test.expected.Updated
+			if got != test.expected {
+				t.Errorf("%s: expected %+v, got %+v", test.name, test.expected, got)
+			}
+		}
+	}
+}
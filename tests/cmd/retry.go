@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/deis/workflow-e2e/tests/model"
+	"github.com/deis/workflow-e2e/tests/retry"
+)
+
+// The functions in this file run a command repeatedly until its result satisfies a Predicate,
+// using the engine in the retry package.
+
+// Predicate decides whether the result of a command is the one being waited for.
+type Predicate interface {
+	Satisfied(result model.CmdResult) bool
+	String() string
+}
+
+type predicateFunc struct {
+	description string
+	f           func(model.CmdResult) bool
+}
+
+func (p predicateFunc) Satisfied(result model.CmdResult) bool { return p.f(result) }
+func (p predicateFunc) String() string                        { return p.description }
+
+// PredicateFunc returns a Predicate that calls f, described by description.
+func PredicateFunc(description string, f func(model.CmdResult) bool) Predicate {
+	return predicateFunc{description: description, f: f}
+}
+
+// OutputContains is satisfied when the command's standard output contains substr.
+func OutputContains(substr string) Predicate {
+	return PredicateFunc(fmt.Sprintf("output containing %q", substr), func(result model.CmdResult) bool {
+		return bytes.Contains(result.Out, []byte(substr))
+	})
+}
+
+// OutputMatches is satisfied when the command's standard output matches re.
+func OutputMatches(re *regexp.Regexp) Predicate {
+	return PredicateFunc(fmt.Sprintf("output matching %q", re), func(result model.CmdResult) bool {
+		return re.Match(result.Out)
+	})
+}
+
+// Meets is satisfied when the command's result meets the expectation, which may be as simple as
+// a model.CmdResult.
+func Meets(expected model.Expectation) Predicate {
+	return PredicateFunc(expected.String(), func(result model.CmdResult) bool {
+		return expected.Check(result) == nil
+	})
+}
+
+// RetryCmd runs the provided command repeatedly until its result satisfies pred, the context is
+// done or opts.Timeout elapses. A command that fails to start counts as a failed attempt rather
+// than failing the spec. It returns nil on success and a *retry.Error otherwise.
+func RetryCmd(ctx context.Context, command model.Cmd, pred Predicate, opts retry.Options) error {
+	if opts.Description == "" {
+		opts.Description = fmt.Sprintf("`%s` to return %s", command.CommandLineString, pred)
+	}
+	return retry.Until(ctx, opts, func(ctx context.Context) (string, bool, error) {
+		result, err := runCmd(ctx, command)
+		if err != nil {
+			return "", false, err
+		}
+		return summarize(result), pred.Satisfied(result), nil
+	})
+}
+
+// Retry runs the provided <cmd> repeatedly, backing off between attempts, up to the supplied
+// <timeout> in seconds until the <cmd> output contains the <expectedResult>. An example use of
+// this utility would be curl-ing a url and waiting until the response code matches the expected
+// response. A <timeout> of zero or less makes no attempts at all.
+func Retry(command model.Cmd, expectedResult string, timeout int) bool {
+	if timeout <= 0 {
+		return false
+	}
+	err := RetryCmd(context.Background(), command, OutputContains(expectedResult),
+		retry.Options{Timeout: time.Duration(timeout) * time.Second})
+	return err == nil
+}
+
+// RetryUntilResult runs the provided cmd repeatedly, backing off from every period, up to the
+// supplied timeout until the cmd result meets the supplied expectedCmdResult
+func RetryUntilResult(command model.Cmd, expectedCmdResult model.Expectation, period, timeout time.Duration) bool {
+	backoff := retry.DefaultBackoff
+	backoff.Initial = period
+	if backoff.Max < period {
+		backoff.Max = period
+	}
+	err := RetryCmd(context.Background(), command, Meets(expectedCmdResult),
+		retry.Options{Timeout: timeout, Backoff: backoff})
+	return err == nil
+}
+
+// runCmd runs a command to completion, killing it if the context is done first.
+func runCmd(ctx context.Context, command model.Cmd) (model.CmdResult, error) {
+	sess, err := StartCmd(command)
+	if err != nil {
+		return model.CmdResult{}, err
+	}
+	select {
+	case <-sess.Exited:
+	case <-ctx.Done():
+		// Don't wait for it to exit: the shell's children may hold its output open.
+		sess.Kill()
+		return model.CmdResult{}, ctx.Err()
+	}
+	return model.CmdResult{
+		Out:      sess.Out.Contents(),
+		Err:      sess.Err.Contents(),
+		ExitCode: sess.ExitCode(),
+	}, nil
+}
+
+// summarize describes a command result in a line for the retry log.
+func summarize(result model.CmdResult) string {
+	const max = 200
+	out := strings.TrimSpace(string(result.Out))
+	if errOut := strings.TrimSpace(string(result.Err)); errOut != "" {
+		out = strings.TrimSpace(out + " " + errOut)
+	}
+	out = strings.Replace(out, "\n", `\n`, -1)
+	if len(out) > max {
+		out = out[:max] + "..."
+	}
+	return fmt.Sprintf("exit %d: %s", result.ExitCode, out)
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/deis/workflow-e2e/tests/model"
+	"github.com/deis/workflow-e2e/tests/retry"
+)
+
+func TestRetry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cmd-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	marker := filepath.Join(dir, "ran")
+	command := model.Cmd{CommandLineString: "touch " + Quote(marker) + " && echo ready"}
+
+	// A timeout of zero or less makes no attempts, as it always has.
+	for _, timeout := range []int{0, -1} {
+		if Retry(command, "ready", timeout) {
+			t.Errorf("expected a timeout of %d to fail", timeout)
+		}
+		if _, err := os.Stat(marker); err == nil {
+			t.Fatalf("expected a timeout of %d to run nothing", timeout)
+		}
+	}
+
+	if !Retry(command, "ready", 5) {
+		t.Error("expected the command to return ready")
+	}
+}
+
+// TestRetryCmdKillsSession checks that a command still running when the timeout elapses is killed
+// rather than left behind.
+func TestRetryCmdKillsSession(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cmd-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pidFile := filepath.Join(dir, "pid")
+	command := model.Cmd{CommandLineString: "echo $$ > " + Quote(pidFile) + " && exec sleep 60"}
+
+	start := time.Now()
+	err = RetryCmd(context.Background(), command, OutputContains("never"),
+		retry.Options{Timeout: time.Second, Log: &bytes.Buffer{}})
+	if err == nil {
+		t.Fatal("expected the retry to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 30*time.Second {
+		t.Fatalf("expected to give up when the timeout elapsed, took %s", elapsed)
+	}
+	if retryErr, ok := err.(*retry.Error); !ok || retryErr.Cause != context.DeadlineExceeded {
+		t.Errorf("expected to give up on the deadline, got %#v", err)
+	}
+
+	contents, err := ioutil.ReadFile(pidFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(contents)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); syscall.Kill(pid, 0) == nil; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatalf("expected process %d to be killed", pid)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	for _, test := range []struct {
+		result   model.CmdResult
+		expected string
+	}{
+		{model.CmdResult{Out: []byte("my-app\n"), ExitCode: 0}, "exit 0: my-app"},
+		{model.CmdResult{Out: []byte("a\nb\n"), Err: []byte("Error: nope\n"), ExitCode: 1}, `exit 1: a\nb Error: nope`},
+		{model.CmdResult{Out: []byte(strings.Repeat("x", 250)), ExitCode: 0}, "exit 0: " + strings.Repeat("x", 200) + "..."},
+	} {
+		if got := summarize(test.result); got != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package run
+
+import (
+	"regexp"
+
+	"github.com/deis/workflow-e2e/tests/cmd"
+	"github.com/deis/workflow-e2e/tests/cmd/configs"
+	"github.com/deis/workflow-e2e/tests/model"
+	"github.com/deis/workflow-e2e/tests/settings"
+
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
+)
+
+// The functions in this file implement SUCCESS CASES for `deis run`. This allows each of these to
+// be re-used easily in multiple contexts.
+
+// PlatformEnv matches the names of environment variables that every process gets from the
+// platform, Kubernetes or the shell rather than from the app's config. ExpectConfigEnv ignores
+// them unless they are also set in the config. The controller runs commands with bash, which
+// exports $_ to them.
+var PlatformEnv = regexp.MustCompile(`^(` +
+	`PORT|DEIS_APP|DEIS_DOMAIN|SOURCE_VERSION|` +
+	`WORKFLOW_RELEASE|WORKFLOW_RELEASE_SUMMARY|WORKFLOW_RELEASE_CREATED_AT|` +
+	`HOME|HOSTNAME|PATH|PWD|SHLVL|TERM|_|` +
+	`KUBERNETES_\w+|\w+_SERVICE_HOST|\w+_SERVICE_PORT(_\w+)?|\w+_PORT(_\d+_(TCP|UDP)(_\w+)?)?` +
+	`)$`)
+
+// Command executes `deis run` as the specified user to run a command in a one-off process of the
+// specified app, and returns what the command printed. The command is passed to the process's
+// shell as it is, so it may use pipes and variables.
+func Command(user model.User, app model.App, command string) []byte {
+	sess, err := cmd.Start("deis run %s --app=%s", &user, cmd.Quote(command), app.Name)
+	Expect(err).NotTo(HaveOccurred())
+	Eventually(sess, settings.MaxEventuallyTimeout).Should(Exit(0))
+	return sess.Out.Contents()
+}
+
+// Env executes `deis run env` as the specified user and returns the environment of a one-off
+// process of the specified app.
+func Env(user model.User, app model.App) map[string]string {
+	return configs.ParseEnv(Command(user, app, "env"))
+}
+
+// ExpectConfigEnv asserts that the environment of a one-off process of the specified app holds
+// exactly the config listed by `deis config:list`: every variable in the config, with the same
+// value, and nothing else besides the variables PlatformEnv matches and those the app's image sets
+// itself. imageEnv holds the latter, e.g. as returned by Env before any config was set; the config
+// overrides them, but otherwise they must keep their values.
+func ExpectConfigEnv(user model.User, app model.App, imageEnv map[string]string) {
+	config := configs.List(user, app)
+	env := Env(user, app)
+
+	for _, key := range configs.SortedKeys(config) {
+		Expect(env).To(HaveKeyWithValue(key, config[key]), "the environment of %s does not match its config", app.Name)
+	}
+	var unexpected []string
+	for _, key := range configs.SortedKeys(env) {
+		if _, ok := config[key]; ok || PlatformEnv.MatchString(key) {
+			continue
+		}
+		if value, ok := imageEnv[key]; ok && value == env[key] {
+			continue
+		}
+		unexpected = append(unexpected, key+"="+env[key])
+	}
+	Expect(unexpected).To(BeEmpty(), "the environment of %s has variables that are not in its config", app.Name)
+}
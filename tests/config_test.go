@@ -1,14 +1,15 @@
 package tests
 
 import (
-	"io/ioutil"
+	"fmt"
 
 	"github.com/deis/workflow-e2e/tests/cmd"
 	"github.com/deis/workflow-e2e/tests/cmd/apps"
 	"github.com/deis/workflow-e2e/tests/cmd/auth"
 	"github.com/deis/workflow-e2e/tests/cmd/builds"
+	"github.com/deis/workflow-e2e/tests/cmd/configs"
+	"github.com/deis/workflow-e2e/tests/cmd/run"
 	"github.com/deis/workflow-e2e/tests/model"
-	"github.com/deis/workflow-e2e/tests/settings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
@@ -34,10 +35,13 @@ var _ = Describe("deis config", func() {
 		Context("who owns an existing app that has already been deployed", func() {
 
 			var app model.App
+			// imageEnv is the environment the app's processes get before any config is set.
+			var imageEnv map[string]string
 
 			BeforeEach(func() {
 				app = apps.Create(user, "--no-remote")
 				builds.Create(user, app)
+				imageEnv = run.Env(user, app)
 			})
 
 			AfterEach(func() {
@@ -45,76 +49,33 @@ var _ = Describe("deis config", func() {
 			})
 
 			Specify("that user can list environment variables on that app", func() {
-				sess, err := cmd.Start("deis config:list -a %s", &user, app.Name)
-				Eventually(sess).Should(Say("=== %s Config", app.Name))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
+				Expect(configs.List(user, app)).NotTo(HaveKey("POWERED_BY"))
 			})
 
 			Specify("that user can set environment variables on that app", func() {
-				sess, err := cmd.Start("deis config:set -a %s POWERED_BY=midi-chlorians", &user, app.Name)
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Say("Creating config"))
-				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Config", app.Name))
-				Eventually(sess).Should(Say(`POWERED_BY\s+midi-chlorians`))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
-
-				sess, err = cmd.Start("deis config:list -a %s", &user, app.Name)
-				Eventually(sess).Should(Say("=== %s Config", app.Name))
-				Eventually(sess).Should(Say(`POWERED_BY\s+midi-chlorians`))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
-
-				sess, err = cmd.Start("deis run env -a %s", &user, app.Name)
-				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("POWERED_BY=midi-chlorians"))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
+				Expect(configs.SetMany(user, app, map[string]string{"POWERED_BY": "midi-chlorians"})).To(HaveKeyWithValue("POWERED_BY", "midi-chlorians"))
+				Expect(configs.List(user, app)).To(HaveKeyWithValue("POWERED_BY", "midi-chlorians"))
+
+				run.ExpectConfigEnv(user, app, imageEnv)
 			})
 
 			Specify("that user can set multiple environment variables at once on that app", func() {
-				sess, err := cmd.Start("deis config:set FOO=null BAR=nil -a %s", &user, app.Name)
-				Eventually(sess).Should(Say("Creating config"))
-				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Config", app.Name))
-				output := string(sess.Out.Contents())
-				Expect(output).To(MatchRegexp(`FOO\s+null`))
-				Expect(output).To(MatchRegexp(`BAR\s+nil`))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
-
-				sess, err = cmd.Start("deis config:list -a %s", &user, app.Name)
-				Eventually(sess).Should(Say("=== %s Config", app.Name))
-				output = string(sess.Out.Contents())
-				Expect(output).To(MatchRegexp(`FOO\s+null`))
-				Expect(output).To(MatchRegexp(`BAR\s+nil`))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
-
-				sess, err = cmd.Start("deis run env -a %s", &user, app.Name)
-				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("FOO=null"))
-				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("BAR=nil"))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
+				values := configs.SetMany(user, app, map[string]string{"FOO": "null", "BAR": "nil"})
+				Expect(values).To(HaveKeyWithValue("FOO", "null"))
+				Expect(values).To(HaveKeyWithValue("BAR", "nil"))
+
+				values = configs.List(user, app)
+				Expect(values).To(HaveKeyWithValue("FOO", "null"))
+				Expect(values).To(HaveKeyWithValue("BAR", "nil"))
+
+				run.ExpectConfigEnv(user, app, imageEnv)
 			})
 
 			Specify("that user can set an environment variable containing spaces on that app", func() {
-				sess, err := cmd.Start(`deis config:set -a %s POWERED_BY=the\ Deis\ team`, &user, app.Name)
-				Eventually(sess).Should(Say("Creating config"))
-				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Config", app.Name))
-				Eventually(sess).Should(Say(`POWERED_BY\s+the Deis team`))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
-
-				sess, err = cmd.Start("deis config:list -a %s", &user, app.Name)
-				Eventually(sess).Should(Say("=== %s Config", app.Name))
-				Eventually(sess).Should(Say(`POWERED_BY\s+the Deis team`))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
-
-				sess, err = cmd.Start("deis run -a %s env", &user, app.Name)
-				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("POWERED_BY=the Deis team"))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
+				Expect(configs.SetMany(user, app, map[string]string{"POWERED_BY": "the Deis team"})).To(HaveKeyWithValue("POWERED_BY", "the Deis team"))
+				Expect(configs.List(user, app)).To(HaveKeyWithValue("POWERED_BY", "the Deis team"))
+
+				run.ExpectConfigEnv(user, app, imageEnv)
 			})
 
 			It("that user can set a multi-line environment variable on that app", func() {
@@ -122,146 +83,103 @@ var _ = Describe("deis config", func() {
 a
 multiline string.`
 
-				sess, err := cmd.Start(`deis config:set -a %s FOO='%s'`, &user, app.Name, value)
-				Eventually(sess).Should(Say("Creating config"))
-				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Config", app.Name))
-				Eventually(sess).Should(Say(`FOO\s+%s`, value))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
-
-				sess, err = cmd.Start("deis config:list -a %s", &user, app.Name)
-				Eventually(sess).Should(Say("=== %s Config", app.Name))
-				Eventually(sess).Should(Say(`FOO\s+%s`, value))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
-
-				sess, err = cmd.Start("deis run -a %s env", &user, app.Name)
-				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("FOO=%s", value))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
+				Expect(configs.SetMany(user, app, map[string]string{"FOO": value})).To(HaveKeyWithValue("FOO", value))
+				Expect(configs.List(user, app)).To(HaveKeyWithValue("FOO", value))
+
+				run.ExpectConfigEnv(user, app, imageEnv)
 			})
 
 			Specify("that user can set an environment variable with non-ASCII and multibyte chars on that app", func() {
-				sess, err := cmd.Start("deis config:set FOO=讲台 BAR=Þorbjörnsson BAZ=ноль -a %s", &user, app.Name)
-				Eventually(sess).Should(Say("Creating config"))
-				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Config", app.Name))
-				output := string(sess.Out.Contents())
-				Expect(output).To(MatchRegexp(`FOO\s+讲台`))
-				Expect(output).To(MatchRegexp(`BAR\s+Þorbjörnsson`))
-				Expect(output).To(MatchRegexp(`BAZ\s+ноль`))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
-
-				sess, err = cmd.Start("deis config:list -a %s", &user, app.Name)
-				Eventually(sess).Should(Say("=== %s Config", app.Name))
-				output = string(sess.Out.Contents())
-				Expect(output).To(MatchRegexp(`FOO\s+讲台`))
-				Expect(output).To(MatchRegexp(`BAR\s+Þorbjörnsson`))
-				Expect(output).To(MatchRegexp(`BAZ\s+ноль`))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
-
-				sess, err = cmd.Start("deis run -a %s env", &user, app.Name)
-				Eventually(sess, settings.MaxEventuallyTimeout).Should(Exit(0))
-				output = string(sess.Out.Contents())
-				Expect(output).To(ContainSubstring("FOO=讲台"))
-				Expect(output).To(ContainSubstring("BAR=Þorbjörnsson"))
-				Expect(output).To(ContainSubstring("BAZ=ноль"))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
+				expected := map[string]string{"FOO": "讲台", "BAR": "Þorbjörnsson", "BAZ": "ноль"}
+				values := configs.SetMany(user, app, expected)
+				for key, value := range expected {
+					Expect(values).To(HaveKeyWithValue(key, value))
+				}
+
+				values = configs.List(user, app)
+				for key, value := range expected {
+					Expect(values).To(HaveKeyWithValue(key, value))
+				}
+
+				run.ExpectConfigEnv(user, app, imageEnv)
+			})
+
+			Specify("that user can round-trip many environment variables through an .env file", func() {
+				expected := map[string]string{}
+				samples := []string{"plain", "with spaces", "讲台", "Þorbjörnsson", "a=b=c", `"quoted"`, "it's", "trailing-dash-"}
+				for i := 0; i < 32; i++ {
+					expected[fmt.Sprintf("VAR_%02d", i)] = fmt.Sprintf("%s %d", samples[i%len(samples)], i)
+				}
+
+				Expect(configs.SetMany(user, app, expected)).To(Equal(expected))
+				Expect(configs.List(user, app)).To(Equal(expected))
+
+				path := configs.Pull(user, app)
+				Expect(configs.ReadEnvFile(path)).To(Equal(expected))
+
+				keys := make([]string, 0, len(expected))
+				for key := range expected {
+					keys = append(keys, key)
+				}
+				Expect(configs.Unset(user, app, keys...)).To(BeEmpty())
+
+				Expect(configs.Push(user, app, path)).To(Equal(expected))
+				Expect(configs.List(user, app)).To(Equal(expected))
+				run.ExpectConfigEnv(user, app, imageEnv)
 			})
 
 			Context("and has already has an environment variable set", func() {
 
 				BeforeEach(func() {
-					sess, err := cmd.Start(`deis config:set -a %s FOO=xyzzy`, &user, app.Name)
-					Eventually(sess).Should(Say("Creating config"))
-					Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Config", app.Name))
-					Eventually(sess).Should(Say(`FOO\s+xyzzy`))
-					Expect(err).NotTo(HaveOccurred())
-					Eventually(sess).Should(Exit(0))
+					Expect(configs.SetMany(user, app, map[string]string{"FOO": "xyzzy"})).To(HaveKeyWithValue("FOO", "xyzzy"))
 				})
 
 				Specify("that user can unset that environment variable", func() {
-					sess, err := cmd.Start("deis config:unset -a %s FOO", &user, app.Name)
-					Eventually(sess).Should(Say("Removing config"))
-					Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Config", app.Name))
-					Eventually(sess).ShouldNot(Say(`FOO\s+xyzzy`))
-					Expect(err).NotTo(HaveOccurred())
-					Eventually(sess).Should(Exit(0))
-
-					sess, err = cmd.Start("deis config:list -a %s", &user, app.Name)
-					Eventually(sess).Should(Say("=== %s Config", app.Name))
-					Eventually(sess).ShouldNot(Say(`FOO\s+xyzzy`))
-					Expect(err).NotTo(HaveOccurred())
-					Eventually(sess).Should(Exit(0))
-
-					sess, err = cmd.Start("deis run -a %s env", &user, app.Name)
-					Eventually(sess, settings.MaxEventuallyTimeout).ShouldNot(Say("FOO=xyzzy"))
-					Expect(err).NotTo(HaveOccurred())
-					Eventually(sess).Should(Exit(0))
+					Expect(configs.Unset(user, app, "FOO")).NotTo(HaveKey("FOO"))
+					Expect(configs.List(user, app)).NotTo(HaveKey("FOO"))
+
+					run.ExpectConfigEnv(user, app, imageEnv)
 				})
 
 				Specify("that user can pull the configuration to an .env file", func() {
-					sess, err := cmd.Start("deis config:pull -a %s", &user, app.Name)
-					// TODO: ginkgo seems to redirect deis' file output here, so just examine
-					// the output stream rather than reading in the .env file. Bug?
-					Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("FOO=xyzzy"))
-					Expect(err).NotTo(HaveOccurred())
-					Eventually(sess).Should(Exit(0))
+					path := configs.Pull(user, app)
+					Expect(configs.ReadEnvFile(path)).To(HaveKeyWithValue("FOO", "xyzzy"))
 				})
 
 			})
 
 			Specify("that user can push configuration from an .env file", func() {
-				contents := []byte(`BIP=baz
+				path := configs.WriteEnvFile(`BIP=baz
 FOO=bar`)
-				err := ioutil.WriteFile(".env", contents, 0644)
-				Expect(err).NotTo(HaveOccurred())
 
-				sess, err := cmd.Start("deis config:push -a %s", &user, app.Name)
-				Eventually(sess, settings.MaxEventuallyTimeout).Should(Exit(0))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
+				values := configs.Push(user, app, path)
+				Expect(values).To(HaveKeyWithValue("BIP", "baz"))
+				Expect(values).To(HaveKeyWithValue("FOO", "bar"))
 
 				// Config should appear in config:list.
-				sess, err = cmd.Start("deis config:list -a %s", &user, app.Name)
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
-
-				Eventually(sess).Should(Say("=== %s Config", app.Name))
-				Eventually(sess).Should(Say(`BIP\s+baz`))
-				Eventually(sess).Should(Say(`FOO\s+bar`))
+				values = configs.List(user, app)
+				Expect(values).To(HaveKeyWithValue("BIP", "baz"))
+				Expect(values).To(HaveKeyWithValue("FOO", "bar"))
 
 				// Config should be found within the app env vars (without any line endings).
-				sess, err = cmd.Start("deis run -a %s 'printf %%q $BIP'", &user, app.Name)
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
-				Eventually(sess).Should(Say("baz"))
+				run.ExpectConfigEnv(user, app, imageEnv)
+				Expect(string(run.Command(user, app, `printf '%q\n' "$WOO"`))).To(MatchRegexp(`(?m)^goo$`),
+					"$WOO should not end with a carriage return")
 			})
 
 			Specify("that user can push configuration from an .env file with CRLF line endings", func() {
-				contents := []byte("BIP=baz\r\nFOO=bar\r\nWOO=goo\r\n")
-				err := ioutil.WriteFile(".env", contents, 0644)
-				Expect(err).NotTo(HaveOccurred())
-
-				sess, err := cmd.Start("deis config:push -a %s", &user, app.Name)
-				Eventually(sess, settings.MaxEventuallyTimeout).Should(Exit(0))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
+				path := configs.WriteEnvFile("BIP=baz\r\nFOO=bar\r\nWOO=goo\r\n")
 
 				// Config should appear in the config:list
-				Eventually(sess).Should(Say("=== %s Config", app.Name))
-				Eventually(sess).Should(Say(`BIP\s+baz`))
-				Eventually(sess).Should(Say(`FOO\s+bar`))
-				Eventually(sess).Should(Say(`WOO\s+goo`))
-				Eventually(sess).Should(Exit(0))
+				values := configs.Push(user, app, path)
+				Expect(values).To(HaveKeyWithValue("BIP", "baz"))
+				Expect(values).To(HaveKeyWithValue("FOO", "bar"))
+				Expect(values).To(HaveKeyWithValue("WOO", "goo"))
 
 				// Config should be found within the app env vars (without any line endings).
-				sess, err = cmd.Start("deis run -a %s 'printf %%q $WOO'", &user, app.Name)
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
-				Eventually(sess).Should(Say("goo"))
+				run.ExpectConfigEnv(user, app, imageEnv)
+				Expect(string(run.Command(user, app, `printf '%q\n' "$WOO"`))).To(MatchRegexp(`(?m)^goo$`),
+					"$WOO should not end with a carriage return")
 			})
 
 		})
@@ -10,6 +10,8 @@ import (
 	"github.com/deis/workflow-e2e/tests/cmd/auth"
 	"github.com/deis/workflow-e2e/tests/cmd/git"
 	"github.com/deis/workflow-e2e/tests/cmd/keys"
+	"github.com/deis/workflow-e2e/tests/cmd/ps"
+	"github.com/deis/workflow-e2e/tests/fixtures"
 	"github.com/deis/workflow-e2e/tests/model"
 
 	. "github.com/onsi/ginkgo"
@@ -35,9 +37,25 @@ var _ = Describe("all dockerfile apps", func() {
 		Context("who has added their public key", func() {
 
 			BeforeEach(func() {
-				_, keyPath = keys.Add(user)
+				keyPath = keys.Add(user).Path
 			})
 
+			DescribeTable("can deploy a sample dockerfile app",
+				func(kind fixtures.Kind, proctype string) {
+					dir, banner := fixtures.Repo(kind)
+					os.Chdir(dir)
+					app := apps.Create(user)
+					defer apps.Destroy(user, app)
+					git.Push(user, keyPath, app, banner)
+					if proctype != "" {
+						Expect(ps.OfType(ps.List(user, app), proctype)).NotTo(BeEmpty())
+					}
+				},
+
+				Entry("HTTP", fixtures.Dockerfile, ""),
+				Entry("HTTP-Web", fixtures.DockerfileProcfile, "web"),
+			)
+
 			DescribeTable("can deploy an example dockerfile app",
 				func(url, buildpack, banner, proctype string) {
 
@@ -57,15 +75,19 @@ var _ = Describe("all dockerfile apps", func() {
 					app = apps.Create(user, args...)
 					defer apps.Destroy(user, app)
 					git.Push(user, keyPath, app, banner)
-					_ = listProcs(user, app, proctype)
+					procs := ps.List(user, app)
+					if proctype != "" {
+						Expect(ps.OfType(procs, proctype)).NotTo(BeEmpty())
+					}
 
 				},
 
-				Entry("HTTP", "https://github.com/deis/example-dockerfile-http.git", "",
+				// These are cloned from GitHub, so they only run with E2E_ONLINE=true.
+				onlineEntry("HTTP", "https://github.com/deis/example-dockerfile-http.git", "",
 					"Powered by Deis", ""),
-				Entry("Python", "https://github.com/deis/example-dockerfile-python.git", "",
+				onlineEntry("Python", "https://github.com/deis/example-dockerfile-python.git", "",
 					"Powered by Deis", ""),
-				Entry("HTTP-Web", "https://github.com/deis/example-dockerfile-procfile-http.git", "",
+				onlineEntry("HTTP-Web", "https://github.com/deis/example-dockerfile-procfile-http.git", "",
 					"Powered by Deis", "web"),
 			)
 
@@ -2,16 +2,15 @@ package tests
 
 import (
 	"fmt"
-	"math/rand"
-	"net/http"
-	"strconv"
 
 	"github.com/deis/workflow-e2e/tests/cmd"
 	"github.com/deis/workflow-e2e/tests/cmd/apps"
 	"github.com/deis/workflow-e2e/tests/cmd/auth"
 	"github.com/deis/workflow-e2e/tests/cmd/builds"
 	"github.com/deis/workflow-e2e/tests/cmd/domains"
+	"github.com/deis/workflow-e2e/tests/http"
 	"github.com/deis/workflow-e2e/tests/model"
+	"github.com/deis/workflow-e2e/tests/naming"
 	"github.com/deis/workflow-e2e/tests/settings"
 	"github.com/deis/workflow-e2e/tests/util"
 
@@ -48,11 +47,7 @@ var _ = Describe("deis domains", func() {
 			})
 
 			Specify("that user can list that app's domains", func() {
-				sess, err := cmd.Start("deis domains:list --app=%s", &user, app.Name)
-				Eventually(sess).Should(Say("=== %s Domains", app.Name))
-				Eventually(sess).Should(Say("%s", app.Name))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
+				Expect(domains.List(user, app)).To(ConsistOf(app.Name))
 			})
 
 			Specify("that user can add domains to that app", func() {
@@ -62,6 +57,8 @@ var _ = Describe("deis domains", func() {
 				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("done"))
 				Expect(err).NotTo(HaveOccurred())
 				Eventually(sess).Should(Exit(0))
+
+				Expect(domains.List(user, app)).To(ConsistOf(app.Name, domain))
 			})
 
 			Specify("that user cannot remove a non-existent domain from that app", func() {
@@ -86,6 +83,8 @@ var _ = Describe("deis domains", func() {
 					Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("done"))
 					Expect(err).NotTo(HaveOccurred())
 					Eventually(sess).Should(Exit(0))
+
+					Expect(domains.List(user, app)).To(ConsistOf(app.Name))
 				})
 
 			})
@@ -107,8 +106,6 @@ var _ = Describe("deis domains", func() {
 
 			Context("with a domain added to it", func() {
 
-				cmdRetryTimeout := 60
-
 				var domain string
 
 				BeforeEach(func() {
@@ -119,18 +116,18 @@ var _ = Describe("deis domains", func() {
 				AfterEach(func() {
 					domains.Remove(user, app, domain)
 					// App can no longer be accessed at the previously associated domain
-					curlCmd := model.Cmd{CommandLineString: fmt.Sprintf(`curl -sL -H "Host: %s" -w "%%{http_code}\\n" "%s" -o /dev/null`, domain, app.URL)}
-					Eventually(cmd.Retry(curlCmd, strconv.Itoa(http.StatusNotFound), cmdRetryTimeout)).Should(BeTrue())
+					http.Probe{URL: app.URL, Host: domain, FollowRedirects: true}.Until(
+						settings.DefaultEventuallyTimeout, http.RespondWith(404))
 				})
 
 				Specify("that app can be accessed at its usual address", func() {
-					curlCmd := model.Cmd{CommandLineString: fmt.Sprintf(`curl -sL -w "%%{http_code}\\n" "%s" -o /dev/null`, app.URL)}
-					Eventually(cmd.Retry(curlCmd, strconv.Itoa(http.StatusOK), cmdRetryTimeout)).Should(BeTrue())
+					http.Probe{URL: app.URL, FollowRedirects: true}.Until(
+						settings.DefaultEventuallyTimeout, http.RespondWith(200))
 				})
 
 				Specify("that app can be accessed at the associated domain", func() {
-					curlCmd := model.Cmd{CommandLineString: fmt.Sprintf(`curl -sL -H "Host: %s" -w "%%{http_code}\\n" "%s" -o /dev/null`, domain, app.URL)}
-					Eventually(cmd.Retry(curlCmd, strconv.Itoa(http.StatusOK), cmdRetryTimeout)).Should(BeTrue())
+					http.Probe{URL: app.URL, Host: domain, FollowRedirects: true}.Until(
+						settings.DefaultEventuallyTimeout, http.RespondWith(200))
 				})
 
 			})
@@ -142,5 +139,5 @@ var _ = Describe("deis domains", func() {
 })
 
 func getRandDomain() string {
-	return fmt.Sprintf("my-custom-%d.domain.com", rand.Intn(999999999))
+	return fmt.Sprintf("%s.domain.com", naming.New("my-custom"))
 }
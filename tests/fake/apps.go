@@ -0,0 +1,584 @@
+package fake
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var appIDRegexp = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+type app struct {
+	UUID      string         `json:"uuid"`
+	ID        string         `json:"id"`
+	Owner     string         `json:"owner"`
+	Structure map[string]int `json:"structure"`
+	URL       string         `json:"url"`
+	Created   string         `json:"created"`
+	Updated   string         `json:"updated"`
+
+	configs  []*config
+	builds   []*build
+	releases []*release
+	domains  []*domain
+	perms    []string
+	logs     []string
+}
+
+type config struct {
+	UUID        string                 `json:"uuid"`
+	Owner       string                 `json:"owner"`
+	App         string                 `json:"app"`
+	Values      map[string]interface{} `json:"values"`
+	Memory      map[string]interface{} `json:"memory"`
+	CPU         map[string]interface{} `json:"cpu"`
+	Tags        map[string]interface{} `json:"tags"`
+	Registry    map[string]interface{} `json:"registry"`
+	Healthcheck map[string]interface{} `json:"healthcheck"`
+	Created     string                 `json:"created"`
+	Updated     string                 `json:"updated"`
+}
+
+type build struct {
+	UUID       string            `json:"uuid"`
+	Owner      string            `json:"owner"`
+	App        string            `json:"app"`
+	Image      string            `json:"image"`
+	Sha        string            `json:"sha"`
+	Procfile   map[string]string `json:"procfile"`
+	Dockerfile string            `json:"dockerfile"`
+	Created    string            `json:"created"`
+	Updated    string            `json:"updated"`
+}
+
+type release struct {
+	UUID    string `json:"uuid"`
+	Owner   string `json:"owner"`
+	App     string `json:"app"`
+	Version int    `json:"version"`
+	Build   string `json:"build"`
+	Config  string `json:"config"`
+	Summary string `json:"summary"`
+	Created string `json:"created"`
+	Updated string `json:"updated"`
+}
+
+type domain struct {
+	Owner   string `json:"owner"`
+	App     string `json:"app"`
+	Domain  string `json:"domain"`
+	Created string `json:"created"`
+	Updated string `json:"updated"`
+}
+
+type pod struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	State   string `json:"state"`
+	Release string `json:"release"`
+	Started string `json:"started"`
+}
+
+func (c *Controller) serveApps(req *request) {
+	if pathIs(req.path, "apps") {
+		switch req.Method {
+		case "GET":
+			var results []interface{}
+			for _, id := range sortedKeys(c.apps) {
+				if a := c.apps[id]; c.canAccess(req.user, a) {
+					results = append(results, a)
+				}
+			}
+			writeList(req, results)
+		case "POST":
+			c.createApp(req)
+		default:
+			notFound(req.w, "Not found.")
+		}
+		return
+	}
+
+	a, ok := c.apps[req.path[1]]
+	if !ok || !c.canAccess(req.user, a) {
+		if ok {
+			forbidden(req.w)
+			return
+		}
+		notFound(req.w, "No App matches the given query.")
+		return
+	}
+
+	if len(req.path) == 2 {
+		switch req.Method {
+		case "GET":
+			writeJSON(req.w, http.StatusOK, a)
+		case "POST":
+			c.transferApp(req, a)
+		case "DELETE":
+			if a.Owner != req.user.Username && !req.user.IsSuperuser {
+				forbidden(req.w)
+				return
+			}
+			for name, crt := range c.certs {
+				crt.Domains = removeDomains(crt.Domains, a.domains)
+				c.certs[name] = crt
+			}
+			delete(c.apps, a.ID)
+			req.w.WriteHeader(http.StatusNoContent)
+		default:
+			notFound(req.w, "Not found.")
+		}
+		return
+	}
+
+	switch req.path[2] {
+	case "config":
+		c.serveConfig(req, a)
+	case "builds":
+		c.serveBuilds(req, a)
+	case "releases":
+		c.serveReleases(req, a)
+	case "domains":
+		c.serveDomains(req, a)
+	case "perms":
+		c.servePerms(req, a)
+	case "pods":
+		c.servePods(req, a)
+	case "scale":
+		c.scale(req, a)
+	case "logs":
+		req.w.Header().Set("Content-Type", "text/plain")
+		req.w.WriteHeader(http.StatusOK)
+		fmt.Fprint(req.w, strings.Join(a.logs, "\n"))
+	default:
+		notFound(req.w, "Not found.")
+	}
+}
+
+func (c *Controller) createApp(req *request) {
+	var body struct {
+		ID string `json:"id"`
+	}
+	if !decode(req, &body) {
+		return
+	}
+	if body.ID == "" {
+		body.ID = "app-" + randomHex(4)
+	}
+	if !appIDRegexp.MatchString(body.ID) {
+		writeFieldError(req.w, "id", "App name can only contain a-z (lowercase), 0-9 and hyphens")
+		return
+	}
+	if _, ok := c.apps[body.ID]; ok {
+		writeFieldError(req.w, "id", "Application with this id already exists.")
+		return
+	}
+	a := &app{
+		UUID:      newUUID(),
+		ID:        body.ID,
+		Owner:     req.user.Username,
+		Structure: map[string]int{},
+		URL:       appURL(body.ID),
+		Created:   now(),
+		Updated:   now(),
+	}
+	c.apps[a.ID] = a
+	cfg := &config{
+		UUID:        newUUID(),
+		Owner:       a.Owner,
+		App:         a.ID,
+		Values:      map[string]interface{}{},
+		Memory:      map[string]interface{}{},
+		CPU:         map[string]interface{}{},
+		Tags:        map[string]interface{}{},
+		Registry:    map[string]interface{}{},
+		Healthcheck: map[string]interface{}{},
+		Created:     now(),
+		Updated:     now(),
+	}
+	a.configs = append(a.configs, cfg)
+	a.log("config %s updated", a.ID)
+	c.newRelease(a, req.user, "", cfg.UUID, fmt.Sprintf("%s created initial release", req.user.Username))
+	a.log("appsettings %s updated", a.ID)
+	a.domains = append(a.domains, &domain{Owner: a.Owner, App: a.ID, Domain: a.ID, Created: now(), Updated: now()})
+	a.log("domain %s added", a.ID)
+	writeJSON(req.w, http.StatusCreated, a)
+}
+
+func (c *Controller) transferApp(req *request, a *app) {
+	var body struct {
+		Owner string `json:"owner"`
+	}
+	if !decode(req, &body) {
+		return
+	}
+	if a.Owner != req.user.Username && !req.user.IsSuperuser {
+		forbidden(req.w)
+		return
+	}
+	if _, ok := c.users[body.Owner]; !ok {
+		notFound(req.w, "No User matches the given query.")
+		return
+	}
+	a.Owner = body.Owner
+	a.Updated = now()
+	req.w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *Controller) serveConfig(req *request, a *app) {
+	if len(req.path) != 3 {
+		notFound(req.w, "Not found.")
+		return
+	}
+	current := a.configs[len(a.configs)-1]
+	switch req.Method {
+	case "GET":
+		writeJSON(req.w, http.StatusOK, current)
+	case "POST":
+		var body map[string]map[string]interface{}
+		if !decode(req, &body) {
+			return
+		}
+		next := &config{
+			UUID:        newUUID(),
+			Owner:       req.user.Username,
+			App:         a.ID,
+			Values:      merge(current.Values, body["values"]),
+			Memory:      merge(current.Memory, body["memory"]),
+			CPU:         merge(current.CPU, body["cpu"]),
+			Tags:        merge(current.Tags, body["tags"]),
+			Registry:    merge(current.Registry, body["registry"]),
+			Healthcheck: merge(current.Healthcheck, body["healthcheck"]),
+			Created:     now(),
+			Updated:     now(),
+		}
+		a.configs = append(a.configs, next)
+		a.log("config %s updated", a.ID)
+		var changed []string
+		for section := range body {
+			changed = append(changed, section)
+		}
+		sort.Strings(changed)
+		latest := a.releases[len(a.releases)-1]
+		c.newRelease(a, req.user, latest.Build, next.UUID, fmt.Sprintf("%s changed %s", req.user.Username, strings.Join(changed, ", ")))
+		writeJSON(req.w, http.StatusCreated, next)
+	default:
+		notFound(req.w, "Not found.")
+	}
+}
+
+func (c *Controller) serveBuilds(req *request, a *app) {
+	if len(req.path) != 3 {
+		notFound(req.w, "Not found.")
+		return
+	}
+	switch req.Method {
+	case "GET":
+		var results []interface{}
+		for i := len(a.builds) - 1; i >= 0; i-- {
+			results = append(results, a.builds[i])
+		}
+		writeList(req, results)
+	case "POST":
+		var body struct {
+			Image      string            `json:"image"`
+			Procfile   map[string]string `json:"procfile"`
+			Dockerfile string            `json:"dockerfile"`
+		}
+		if !decode(req, &body) {
+			return
+		}
+		if body.Image == "" {
+			writeFieldError(req.w, "image", "This field may not be blank.")
+			return
+		}
+		b := &build{
+			Image:      body.Image,
+			Procfile:   body.Procfile,
+			Dockerfile: body.Dockerfile,
+		}
+		c.deploy(a, req.user, b, b.Image)
+		writeJSON(req.w, http.StatusCreated, b)
+	default:
+		notFound(req.w, "Not found.")
+	}
+}
+
+func (c *Controller) serveReleases(req *request, a *app) {
+	switch {
+	case req.Method == "GET" && len(req.path) == 3:
+		var results []interface{}
+		for i := len(a.releases) - 1; i >= 0; i-- {
+			results = append(results, a.releases[i])
+		}
+		writeList(req, results)
+	case req.Method == "POST" && len(req.path) == 4 && req.path[3] == "rollback":
+		var body struct {
+			Version int `json:"version"`
+		}
+		if !decode(req, &body) {
+			return
+		}
+		if body.Version == 0 {
+			body.Version = len(a.releases) - 1
+		}
+		if body.Version < 1 || body.Version > len(a.releases) {
+			notFound(req.w, "No Release matches the given query.")
+			return
+		}
+		target := a.releases[body.Version-1]
+		r := c.newRelease(a, req.user, target.Build, target.Config, fmt.Sprintf("%s rolled back to v%d", req.user.Username, target.Version))
+		writeJSON(req.w, http.StatusCreated, map[string]int{"version": r.Version})
+	case req.Method == "GET" && len(req.path) == 4:
+		version, err := strconv.Atoi(strings.TrimPrefix(req.path[3], "v"))
+		if err != nil || version < 1 || version > len(a.releases) {
+			notFound(req.w, "No Release matches the given query.")
+			return
+		}
+		writeJSON(req.w, http.StatusOK, a.releases[version-1])
+	default:
+		notFound(req.w, "Not found.")
+	}
+}
+
+func (c *Controller) serveDomains(req *request, a *app) {
+	switch {
+	case req.Method == "GET" && len(req.path) == 3:
+		var results []interface{}
+		for _, d := range a.domains {
+			results = append(results, d)
+		}
+		writeList(req, results)
+	case req.Method == "POST" && len(req.path) == 3:
+		var body struct {
+			Domain string `json:"domain"`
+		}
+		if !decode(req, &body) {
+			return
+		}
+		if c.findDomain(body.Domain) != nil {
+			writeFieldError(req.w, "domain", "Domain is already in use by another application")
+			return
+		}
+		d := &domain{Owner: req.user.Username, App: a.ID, Domain: body.Domain, Created: now(), Updated: now()}
+		a.domains = append(a.domains, d)
+		a.log("domain %s added", body.Domain)
+		writeJSON(req.w, http.StatusCreated, d)
+	case req.Method == "DELETE" && len(req.path) == 4:
+		for i, d := range a.domains {
+			if d.Domain == req.path[3] {
+				a.domains = append(a.domains[:i], a.domains[i+1:]...)
+				for name, crt := range c.certs {
+					crt.Domains = removeDomains(crt.Domains, []*domain{d})
+					c.certs[name] = crt
+				}
+				a.log("domain %s removed", d.Domain)
+				req.w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		notFound(req.w, "No Domain matches the given query.")
+	default:
+		notFound(req.w, "Not found.")
+	}
+}
+
+func (c *Controller) servePerms(req *request, a *app) {
+	switch {
+	case req.Method == "GET" && len(req.path) == 3:
+		users := append([]string{}, a.perms...)
+		writeJSON(req.w, http.StatusOK, map[string][]string{"users": users})
+	case req.Method == "POST" && len(req.path) == 3:
+		var body struct {
+			Username string `json:"username"`
+		}
+		if !decode(req, &body) {
+			return
+		}
+		if a.Owner != req.user.Username && !req.user.IsSuperuser {
+			forbidden(req.w)
+			return
+		}
+		if _, ok := c.users[body.Username]; !ok {
+			notFound(req.w, "No User matches the given query.")
+			return
+		}
+		a.perms = append(a.perms, body.Username)
+		req.w.WriteHeader(http.StatusCreated)
+	case req.Method == "DELETE" && len(req.path) == 4:
+		if a.Owner != req.user.Username && !req.user.IsSuperuser && req.user.Username != req.path[3] {
+			forbidden(req.w)
+			return
+		}
+		for i, username := range a.perms {
+			if username == req.path[3] {
+				a.perms = append(a.perms[:i], a.perms[i+1:]...)
+				req.w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		notFound(req.w, "No User matches the given query.")
+	default:
+		notFound(req.w, "Not found.")
+	}
+}
+
+func (c *Controller) servePods(req *request, a *app) {
+	if req.Method != "GET" {
+		notFound(req.w, "Not found.")
+		return
+	}
+	var results []interface{}
+	version := fmt.Sprintf("v%d", len(a.releases))
+	for _, procType := range sortedTypes(a.Structure) {
+		for i := 0; i < a.Structure[procType]; i++ {
+			if len(req.path) > 3 && req.path[3] != procType {
+				continue
+			}
+			results = append(results, pod{
+				Name:    fmt.Sprintf("%s-%s-%d-%s", a.ID, procType, len(a.releases), strconv.Itoa(i)),
+				Type:    procType,
+				State:   "up",
+				Release: version,
+				Started: a.Updated,
+			})
+		}
+	}
+	writeList(req, results)
+}
+
+func (c *Controller) scale(req *request, a *app) {
+	if req.Method != "POST" {
+		writeError(req.w, http.StatusMethodNotAllowed, fmt.Sprintf("Method \"%s\" not allowed.", req.Method))
+		return
+	}
+	var body map[string]int
+	if !decode(req, &body) {
+		return
+	}
+	if len(a.builds) == 0 {
+		writeFieldError(req.w, "detail", "No build associated with this release")
+		return
+	}
+	for procType, count := range body {
+		a.Structure[procType] = count
+	}
+	a.Updated = now()
+	req.w.WriteHeader(http.StatusNoContent)
+}
+
+// deploy records the build as the newest of the app and releases it. The summary of the release
+// says that the user deployed what.
+func (c *Controller) deploy(a *app, u *user, b *build, what string) *release {
+	b.UUID = newUUID()
+	b.Owner = u.Username
+	b.App = a.ID
+	b.Created = now()
+	b.Updated = b.Created
+	a.builds = append(a.builds, b)
+	a.log("build %s created", a.ID)
+	current := a.configs[len(a.configs)-1]
+	r := c.newRelease(a, u, b.UUID, current.UUID, fmt.Sprintf("%s deployed %s", u.Username, what))
+	// Like the real controller, the first deploy scales the default process type to one.
+	if len(a.builds) == 1 {
+		procType := "cmd"
+		if _, ok := b.Procfile["web"]; ok {
+			procType = "web"
+		}
+		a.Structure[procType] = 1
+	}
+	return r
+}
+
+// newRelease records a new release of the app with the given build and config.
+func (c *Controller) newRelease(a *app, u *user, buildUUID, configUUID, summary string) *release {
+	r := &release{
+		UUID:    newUUID(),
+		Owner:   u.Username,
+		App:     a.ID,
+		Version: len(a.releases) + 1,
+		Build:   buildUUID,
+		Config:  configUUID,
+		Summary: summary,
+		Created: now(),
+		Updated: now(),
+	}
+	a.releases = append(a.releases, r)
+	a.Updated = now()
+	if r.Version == 1 {
+		a.log("%s created initial release", a.ID)
+	}
+	return r
+}
+
+func (c *Controller) canAccess(u *user, a *app) bool {
+	if u.IsSuperuser || a.Owner == u.Username {
+		return true
+	}
+	for _, username := range a.perms {
+		if username == u.Username {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Controller) findDomain(name string) *domain {
+	for _, a := range c.apps {
+		for _, d := range a.domains {
+			if d.Domain == name {
+				return d
+			}
+		}
+	}
+	return nil
+}
+
+// log appends a line in the controller's log format to the app's logs.
+func (a *app) log(format string, args ...interface{}) {
+	a.logs = append(a.logs, fmt.Sprintf("%s deis[controller]: INFO %s", now(), fmt.Sprintf(format, args...)))
+}
+
+// merge returns a copy of current with the given changes applied. A nil value unsets a key.
+func merge(current, changes map[string]interface{}) map[string]interface{} {
+	merged := map[string]interface{}{}
+	for k, v := range current {
+		merged[k] = v
+	}
+	for k, v := range changes {
+		if v == nil {
+			delete(merged, k)
+			continue
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+func removeDomains(names []string, domains []*domain) []string {
+	kept := []string{}
+	for _, name := range names {
+		keep := true
+		for _, d := range domains {
+			if d.Domain == name {
+				keep = false
+			}
+		}
+		if keep {
+			kept = append(kept, name)
+		}
+	}
+	return kept
+}
+
+func sortedTypes(structure map[string]int) []string {
+	var types []string
+	for t := range structure {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
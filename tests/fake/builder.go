@@ -0,0 +1,536 @@
+package fake
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/deis/workflow-e2e/tests/api"
+	"github.com/deis/workflow-e2e/tests/cmd"
+
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+)
+
+// The code in this file implements an in-process stand-in for deis-builder. It accepts `git push`
+// over SSH from users whose keys are registered with a controller, stores what was pushed in a
+// bare repository per app, and prints the progress a build would, so that the git helpers in
+// tests/cmd/git can be exercised without a cluster. Nothing is ever built; what each push does is
+// scripted per app.
+
+// BuildStartLine is the first line every build prints.
+const BuildStartLine = "Starting build... but first, coffee!"
+
+// ConcurrentPushError is the error a push is rejected with while another push to the same app is
+// in progress. Git prints it as "fatal: remote error: Another git push is ongoing".
+const ConcurrentPushError = "Another git push is ongoing"
+
+// Script describes how the builder handles pushes to an app. The zero value describes a build that
+// succeeds straight away.
+type Script struct {
+	// Output is printed by the build after BuildStartLine. It defaults to the progress of a
+	// successful build.
+	Output []string
+	// Duration is how long the build takes once it has printed its output. As with the real
+	// builder, the build carries on if the push is interrupted, and other pushes to the app are
+	// rejected until it is done.
+	Duration time.Duration
+	// Fail makes the build fail, so that the push is rejected.
+	Fail bool
+	// Hang makes the build never finish. The push only ends when it is interrupted, which stops the
+	// build.
+	Hang bool
+	// AllowConcurrent lets pushes to the app overlap. The real builder never does.
+	AllowConcurrent bool
+}
+
+// successOutput is what a successful build prints before it is released.
+var successOutput = []string{"...", "Build complete.", "Launching App...", "..."}
+
+// KeySource lists SSH keys registered with a controller. The fake controller lists the keys of
+// every user; an api.Client lists those of its own user, and several can be combined as KeySources.
+type KeySource interface {
+	Keys() ([]api.Key, error)
+}
+
+// KeySources lists the keys of every one of its sources.
+type KeySources []KeySource
+
+// Keys implements KeySource.
+func (s KeySources) Keys() ([]api.Key, error) {
+	var all []api.Key
+	for _, source := range s {
+		keys, err := source.Keys()
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, keys...)
+	}
+	return all, nil
+}
+
+// appBackend is implemented by key sources that also know about apps, as the fake controller
+// does. Pushes are only checked against, and deployed to, such a source; otherwise every push by a
+// registered user is accepted and nothing is deployed.
+type appBackend interface {
+	canPush(username, appID string) error
+	deployPush(username, appID, sha string) (int, error)
+}
+
+// Builder is a fake deis-builder listening for SSH connections on a local address.
+type Builder struct {
+	// Host and Port are where the builder listens.
+	Host string
+	Port int
+	// HostKey is the public half of the builder's host key, which is new every time.
+	HostKey ssh.PublicKey
+
+	keys     KeySource
+	listener net.Listener
+	config   *ssh.ServerConfig
+	dir      string
+
+	mu      sync.Mutex
+	scripts map[string]Script
+	pushing map[string]int
+	pushes  map[string]int
+	done    chan struct{}
+}
+
+// NewBuilder starts a fake builder that accepts pushes from the users whose keys are listed by
+// the source, and returns it. Callers should Close it when done.
+func NewBuilder(keys KeySource) (*Builder, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := ioutil.TempDir("", "fake-builder")
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	b := &Builder{
+		Host:     "127.0.0.1",
+		Port:     listener.Addr().(*net.TCPAddr).Port,
+		HostKey:  signer.PublicKey(),
+		keys:     keys,
+		listener: listener,
+		dir:      dir,
+		scripts:  map[string]Script{},
+		pushing:  map[string]int{},
+		pushes:   map[string]int{},
+		done:     make(chan struct{}),
+	}
+	b.config = &ssh.ServerConfig{PublicKeyCallback: b.authenticate}
+	b.config.AddHostKey(signer)
+	go b.serve()
+	return b, nil
+}
+
+// Close stops the builder, interrupting any pushes in progress, and deletes what was pushed.
+func (b *Builder) Close() error {
+	close(b.done)
+	err := b.listener.Close()
+	os.RemoveAll(b.dir)
+	return err
+}
+
+// Addr returns the address the builder listens on.
+func (b *Builder) Addr() string {
+	return net.JoinHostPort(b.Host, strconv.Itoa(b.Port))
+}
+
+// GitURL returns the URL to push the app to, as the `deis` CLI sets it up for the deis remote.
+func (b *Builder) GitURL(appID string) string {
+	return fmt.Sprintf("ssh://git@%s/%s.git", b.Addr(), appID)
+}
+
+// Script sets how pushes to the app are handled from now on.
+func (b *Builder) Script(appID string, script Script) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.scripts[appID] = script
+}
+
+// Pushes returns how many pushes to the app have been received, including rejected ones.
+func (b *Builder) Pushes(appID string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.pushes[appID]
+}
+
+// authenticate accepts keys that are registered with the controller, and records whose they are.
+func (b *Builder) authenticate(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	registered, err := b.keys.Keys()
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range registered {
+		public, _, _, _, err := ssh.ParseAuthorizedKey([]byte(k.Public))
+		if err == nil && bytes.Equal(public.Marshal(), key.Marshal()) {
+			return &ssh.Permissions{Extensions: map[string]string{"user": k.Owner, "key": k.ID}}, nil
+		}
+	}
+	return nil, fmt.Errorf("key %s is not registered", ssh.FingerprintSHA256(key))
+}
+
+func (b *Builder) serve() {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		go b.handle(conn)
+	}
+}
+
+// handle serves a single SSH connection, which is done with once its first session is.
+func (b *Builder) handle(nc net.Conn) {
+	conn, channels, requests, err := ssh.NewServerConn(nc, b.config)
+	if err != nil {
+		nc.Close()
+		return
+	}
+	defer conn.Close()
+	go ssh.DiscardRequests(requests)
+
+	// Anything started for the connection is stopped when the client goes away, e.g. because the
+	// push was interrupted.
+	gone := make(chan struct{})
+	go func() {
+		conn.Wait()
+		close(gone)
+	}()
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		b.session(conn.Permissions.Extensions["user"], channel, requests, gone)
+		// The client hangs up once it has the exit status; hanging up first makes ssh fail.
+		select {
+		case <-gone:
+		case <-b.done:
+		}
+		return
+	}
+}
+
+// session serves the exec request of a session, ignoring anything the client asks for before.
+func (b *Builder) session(username string, channel ssh.Channel, requests <-chan *ssh.Request, gone chan struct{}) {
+	defer channel.Close()
+	for req := range requests {
+		if req.Type != "exec" {
+			// git sets GIT_PROTOCOL with an env request, which is fine to ignore.
+			req.Reply(req.Type == "env", nil)
+			continue
+		}
+		var payload struct{ Command string }
+		if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+			req.Reply(false, nil)
+			return
+		}
+		req.Reply(true, nil)
+		status := b.receive(username, payload.Command, channel, gone)
+		channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+		return
+	}
+}
+
+var receivePackRegexp = regexp.MustCompile(`^git-receive-pack '/?([a-z0-9]+(?:-[a-z0-9]+)*)\.git'$`)
+
+// receive handles a push by the user, and returns the exit status of the command.
+func (b *Builder) receive(username, command string, channel ssh.Channel, gone chan struct{}) uint32 {
+	stderr := channel.Stderr()
+	m := receivePackRegexp.FindStringSubmatch(command)
+	if m == nil {
+		fmt.Fprintf(stderr, "Unknown command: %s\n", command)
+		return 1
+	}
+	appID := m[1]
+	script := b.start(appID)
+	if backend, ok := b.keys.(appBackend); ok {
+		if err := backend.canPush(username, appID); err != nil {
+			writePktLine(channel, "ERR "+err.Error())
+			return 1
+		}
+	}
+	if !script.AllowConcurrent {
+		if !b.lock(appID) {
+			writePktLine(channel, "ERR "+ConcurrentPushError)
+			return 1
+		}
+		defer b.unlock(appID)
+	}
+
+	repo, err := b.repo(appID, script)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	// The post-receive hook records the refs the push updated in a file of its own, since
+	// concurrent pushes share the repository.
+	updated, err := ioutil.TempFile(b.dir, "updated-")
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	updated.Close()
+	defer os.Remove(updated.Name())
+	receivePack := exec.Command("git", "receive-pack", repo)
+	receivePack.Env = append(os.Environ(), updatedRefsEnv+"="+updated.Name())
+	// Once the client has gone away, what the build prints is thrown away rather than stopping it.
+	receivePack.Stdout, receivePack.Stderr = discardErrors{channel}, discardErrors{stderr}
+	// The client may never close its end of the channel, so nothing waits for stdin to be copied.
+	stdin, err := receivePack.StdinPipe()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	go func() {
+		io.Copy(stdin, channel)
+		stdin.Close()
+	}()
+	// The build runs in the hook, which receive-pack starts in the same process group, so that
+	// the whole group can be stopped when the push is interrupted.
+	receivePack.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := receivePack.Start(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- receivePack.Wait() }()
+	kill := func() { syscall.Kill(-receivePack.Process.Pid, syscall.SIGKILL) }
+	select {
+	case err = <-exited:
+	case <-gone:
+		// Like the real builder, a build carries on when the push is interrupted, and so does the
+		// lock on the app; only a build that would never finish is stopped.
+		if script.Hang {
+			kill()
+		}
+		select {
+		case err = <-exited:
+		case <-b.done:
+			kill()
+			err = <-exited
+		}
+	case <-b.done:
+		kill()
+		err = <-exited
+	}
+	// receive-pack may fail to report back to a client that has gone away after master was updated,
+	// in which case the build is deployed all the same. Pushing nothing new runs no build.
+	sha := updatedMaster(updated.Name())
+	if sha == "" {
+		if err != nil {
+			return 1
+		}
+		return 0
+	}
+	version := 0
+	if backend, ok := b.keys.(appBackend); ok {
+		if version, err = backend.deployPush(username, appID, sha); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+	if version > 0 {
+		fmt.Fprintf(stderr, "Done, %s:v%d deployed to Workflow\n\n", appID, version)
+	} else {
+		fmt.Fprintf(stderr, "Done, %s deployed to Workflow\n\n", appID)
+	}
+	fmt.Fprintf(stderr, "Use 'deis open' to view this application in your browser\n\n")
+	fmt.Fprintf(stderr, "To learn more, use 'deis help' or visit https://deis.com/\n\n")
+	return 0
+}
+
+// start counts a push to the app and returns the script for it.
+func (b *Builder) start(appID string) Script {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pushes[appID]++
+	return b.scripts[appID]
+}
+
+// lock marks a push to the app as in progress, unless one already is.
+func (b *Builder) lock(appID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pushing[appID] > 0 {
+		return false
+	}
+	b.pushing[appID]++
+	return true
+}
+
+// unlock marks the push to the app that was in progress as done.
+func (b *Builder) unlock(appID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pushing[appID]--
+}
+
+// repo returns the bare repository pushes to the app are received in, creating it for the first
+// push, with a pre-receive hook that runs the scripted build.
+func (b *Builder) repo(appID string, script Script) (string, error) {
+	repo := filepath.Join(b.dir, appID+".git")
+	if _, err := os.Stat(repo); os.IsNotExist(err) {
+		if output, err := exec.Command("git", "init", "--quiet", "--bare", repo).CombinedOutput(); err != nil {
+			return "", fmt.Errorf("creating %s: %s: %s", repo, err, output)
+		}
+	}
+	// Concurrent pushes may be running the hooks, so they are replaced rather than rewritten.
+	hooks := filepath.Join(repo, "hooks")
+	if err := replaceFile(filepath.Join(hooks, "post-receive"), []byte(postReceiveHook), 0755); err != nil {
+		return "", err
+	}
+	return repo, replaceFile(filepath.Join(hooks, "pre-receive"), []byte(hookScript(script)), 0755)
+}
+
+// replaceFile writes data to a new file and renames it over the file at path, so that anyone
+// reading the file sees either its old or its new contents in full.
+func replaceFile(path string, data []byte, perm os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(perm)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// updatedRefsEnv names the file the post-receive hook records the refs a push updated in.
+const updatedRefsEnv = "FAKE_BUILDER_UPDATED_REFS"
+
+// postReceiveHook is run by git once a push has updated refs, with a line per ref on stdin.
+const postReceiveHook = "#!/bin/sh\ncat > \"$" + updatedRefsEnv + "\"\n"
+
+// updatedMaster returns the commit master was updated to by a push, given the refs the
+// post-receive hook recorded, or "" if the push did not update master.
+func updatedMaster(path string) string {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(contents), "\n") {
+		// Each line is "<old sha> <new sha> <ref>".
+		fields := strings.Fields(line)
+		if len(fields) == 3 && fields[2] == "refs/heads/master" && strings.Trim(fields[1], "0") != "" {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// hookScript returns a pre-receive hook that prints the progress of the scripted build. Git shows
+// what it prints prefixed with "remote: ", just as it does for the real builder's hook.
+func hookScript(script Script) string {
+	output := script.Output
+	if output == nil && !script.Fail && !script.Hang {
+		output = successOutput
+	}
+	lines := []string{"#!/bin/sh", "cat > /dev/null", "echo " + cmd.Quote(BuildStartLine)}
+	for _, line := range output {
+		lines = append(lines, "echo "+cmd.Quote(line))
+	}
+	if script.Duration > 0 {
+		lines = append(lines, fmt.Sprintf("sleep %.3f", script.Duration.Seconds()))
+	}
+	switch {
+	case script.Hang:
+		lines = append(lines, "while :; do sleep 1; done")
+	case script.Fail:
+		lines = append(lines, "exit 1")
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// writePktLine writes a line in git's pkt-line format, as a server does to reject a push before
+// advertising its refs.
+func writePktLine(w io.Writer, line string) {
+	line += "\n"
+	fmt.Fprintf(w, "%04x%s", len(line)+4, line)
+}
+
+// discardErrors is a writer that reports every write as successful, whether or not it was.
+type discardErrors struct {
+	w io.Writer
+}
+
+func (d discardErrors) Write(p []byte) (int, error) {
+	d.w.Write(p)
+	return len(p), nil
+}
+
+// Keys lists the keys of every user, so that the fake controller can be a builder's KeySource.
+func (c *Controller) Keys() ([]api.Key, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make([]api.Key, 0, len(c.keys))
+	for _, id := range sortedKeys(c.keys) {
+		k := c.keys[id]
+		keys = append(keys, api.Key{UUID: k.UUID, ID: k.ID, Owner: k.Owner, Public: k.Public, Created: k.Created, Updated: k.Updated})
+	}
+	return keys, nil
+}
+
+func (c *Controller) canPush(username, appID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	a, ok := c.apps[appID]
+	u := c.users[username]
+	if !ok || u == nil || !c.canAccess(u, a) {
+		return errors.New("No app matches the given query.")
+	}
+	return nil
+}
+
+func (c *Controller) deployPush(username, appID, sha string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	a, ok := c.apps[appID]
+	u := c.users[username]
+	if !ok || u == nil {
+		return 0, errors.New("No app matches the given query.")
+	}
+	b := &build{Image: fmt.Sprintf("%s:git-%s", appID, sha[:7]), Sha: sha, Procfile: map[string]string{}}
+	return c.deploy(a, u, b, sha[:7]).Version, nil
+}
@@ -0,0 +1,394 @@
+package fake
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/deis/workflow-e2e/tests/api"
+	"github.com/deis/workflow-e2e/tests/cmd/keys"
+	"github.com/deis/workflow-e2e/tests/transport"
+
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+)
+
+// pushTest is a fake controller with a user who owns an app, a fake builder, and a local git repo
+// to push to the builder as that user.
+type pushTest struct {
+	t          *testing.T
+	controller *Controller
+	builder    *Builder
+	client     *api.Client
+	dir        string
+	repo       string
+	keyPath    string
+}
+
+func newPushTest(t *testing.T) *pushTest {
+	dir, err := ioutil.TempDir("", "builder-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &pushTest{t: t, controller: NewController(), dir: dir, repo: filepath.Join(dir, "my-app")}
+	if _, err := api.NewClient(p.controller.URL).Register("admin", "admin", "admin@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if p.client, err = api.Login(p.controller.URL, "admin", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.client.CreateApp("my-app"); err != nil {
+		t.Fatal(err)
+	}
+	p.keyPath = p.newKey(p.client, "admin-key")
+	if p.builder, err = NewBuilder(p.controller); err != nil {
+		t.Fatal(err)
+	}
+
+	p.git("", "init", "--quiet", p.repo)
+	p.git(p.repo, "checkout", "--quiet", "-b", "master")
+	p.commit("initial")
+	p.git(p.repo, "remote", "add", "deis", p.builder.GitURL("my-app"))
+	return p
+}
+
+func (p *pushTest) Close() {
+	p.builder.Close()
+	p.controller.Close()
+	os.RemoveAll(p.dir)
+}
+
+// newKey generates a key, writes its private half to a file and, if client is not nil, registers
+// it for the client's user. It returns the path of the private key.
+func (p *pushTest) newKey(client *api.Client, id string) string {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	block, err := keys.MarshalPrivateKey(private, id)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	path := filepath.Join(p.dir, id)
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		p.t.Fatal(err)
+	}
+	if client != nil {
+		sshPublic, err := ssh.NewPublicKey(public)
+		if err != nil {
+			p.t.Fatal(err)
+		}
+		if _, err := client.CreateKey(id, strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPublic)))); err != nil {
+			p.t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func (p *pushTest) git(dir string, args ...string) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		p.t.Fatalf("git %s: %s: %s", strings.Join(args, " "), err, output)
+	}
+}
+
+func (p *pushTest) commit(message string) {
+	if err := ioutil.WriteFile(filepath.Join(p.repo, "README"), []byte(message+"\n"), 0644); err != nil {
+		p.t.Fatal(err)
+	}
+	p.git(p.repo, "add", "README")
+	p.git(p.repo, "-c", "user.name=Test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", message)
+}
+
+// push is a `git push deis master` in progress.
+type push struct {
+	cmd    *exec.Cmd
+	mu     sync.Mutex
+	output bytes.Buffer
+	err    chan error
+}
+
+func (p *push) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.output.Write(b)
+}
+
+func (p *push) Output() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.output.String()
+}
+
+// startPush starts pushing the repo with the given key.
+func (p *pushTest) startPush(keyPath string) *push {
+	env, err := transport.Transport{}.Env(keyPath)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	cmd := exec.Command("git", "push", "deis", "master")
+	cmd.Dir = p.repo
+	cmd.Env = append(os.Environ(), env)
+	// Like a terminal would, an interrupt goes to git and to the ssh it started.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	ps := &push{cmd: cmd, err: make(chan error, 1)}
+	cmd.Stdout, cmd.Stderr = ps, ps
+	if err := cmd.Start(); err != nil {
+		p.t.Fatal(err)
+	}
+	go func() { ps.err <- cmd.Wait() }()
+	return ps
+}
+
+// wait waits for the push to exit and returns its exit status.
+func (ps *push) wait(t *testing.T) int {
+	select {
+	case err := <-ps.err:
+		if err == nil {
+			return 0
+		}
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.Sys().(syscall.WaitStatus).ExitStatus()
+		}
+		t.Fatal(err)
+	case <-time.After(30 * time.Second):
+		t.Fatalf("push did not exit:\n%s", ps.Output())
+	}
+	return -1
+}
+
+// waitFor waits until the push has printed s.
+func (ps *push) waitFor(t *testing.T, s string) {
+	for deadline := time.Now().Add(30 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if strings.Contains(ps.Output(), s) {
+			return
+		}
+	}
+	t.Fatalf("push did not print %q:\n%s", s, ps.Output())
+}
+
+func (p *pushTest) expectPush(keyPath string, status int, outputs ...string) string {
+	ps := p.startPush(keyPath)
+	if got := ps.wait(p.t); got != status {
+		p.t.Errorf("expected the push to exit with %d, got %d:\n%s", status, got, ps.Output())
+	}
+	for _, s := range outputs {
+		if !strings.Contains(ps.Output(), s) {
+			p.t.Errorf("expected the push to print %q:\n%s", s, ps.Output())
+		}
+	}
+	return ps.Output()
+}
+
+func TestBuilderPush(t *testing.T) {
+	p := newPushTest(t)
+	defer p.Close()
+
+	p.expectPush(p.keyPath, 0, "remote: "+BuildStartLine, "Done, my-app:v2 deployed to Workflow")
+	builds, err := p.client.Builds("my-app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(builds) != 1 {
+		t.Fatalf("expected the push to create a build, got %+v", builds)
+	}
+
+	output := p.expectPush(p.keyPath, 0, "Everything up-to-date")
+	if strings.Contains(output, BuildStartLine) {
+		t.Errorf("expected pushing nothing new not to start a build:\n%s", output)
+	}
+
+	p.commit("second")
+	p.expectPush(p.keyPath, 0, "Done, my-app:v3 deployed to Workflow")
+	if got := p.builder.Pushes("my-app"); got != 3 {
+		t.Errorf("expected 3 pushes, got %d", got)
+	}
+}
+
+func TestBuilderRejectsUnregisteredKeys(t *testing.T) {
+	p := newPushTest(t)
+	defer p.Close()
+
+	p.expectPush(p.newKey(nil, "unregistered"), 128, "Permission denied")
+	if got := p.builder.Pushes("my-app"); got != 0 {
+		t.Errorf("expected no push to get as far as the builder, got %d", got)
+	}
+}
+
+func TestBuilderRejectsPushesToOtherUsersApps(t *testing.T) {
+	p := newPushTest(t)
+	defer p.Close()
+
+	if _, err := p.client.Register("other", "other", "other@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	other, err := api.Login(p.controller.URL, "other", "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.expectPush(p.newKey(other, "other-key"), 128, "fatal: remote error: No app matches the given query.")
+}
+
+func TestBuilderFailingBuild(t *testing.T) {
+	p := newPushTest(t)
+	defer p.Close()
+
+	p.builder.Script("my-app", Script{Fail: true, Output: []string{"-----> Fetching custom buildpack", "exited with code 1, stopping build"}})
+	p.expectPush(p.keyPath, 1, BuildStartLine, "exited with code 1, stopping build", "pre-receive hook declined")
+	builds, err := p.client.Builds("my-app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(builds) != 0 {
+		t.Errorf("expected a failed build not to be deployed, got %+v", builds)
+	}
+}
+
+func TestBuilderRejectsConcurrentPushes(t *testing.T) {
+	p := newPushTest(t)
+	defer p.Close()
+
+	p.builder.Script("my-app", Script{Duration: 2 * time.Second})
+	first := p.startPush(p.keyPath)
+	first.waitFor(t, BuildStartLine)
+	p.expectPush(p.keyPath, 128, "fatal: remote error: "+ConcurrentPushError)
+	if got := first.wait(t); got != 0 {
+		t.Errorf("expected the first push to succeed, got %d:\n%s", got, first.Output())
+	}
+	// Once the first push is done, the app is unlocked again.
+	p.expectPush(p.keyPath, 0, "Everything up-to-date")
+
+	p.builder.Script("my-app", Script{Duration: 2 * time.Second, AllowConcurrent: true})
+	p.commit("second")
+	first = p.startPush(p.keyPath)
+	first.waitFor(t, BuildStartLine)
+	// Both pushes build, but only the first gets to update master.
+	second := p.startPush(p.keyPath)
+	second.wait(t)
+	if output := second.Output(); !strings.Contains(output, BuildStartLine) || strings.Contains(output, ConcurrentPushError) {
+		t.Errorf("expected the second push to build alongside the first:\n%s", output)
+	}
+	if got := first.wait(t); got != 0 {
+		t.Errorf("expected the first push to succeed, got %d:\n%s", got, first.Output())
+	}
+}
+
+func TestBuilderInterruptedPush(t *testing.T) {
+	p := newPushTest(t)
+	defer p.Close()
+
+	// An interrupted build carries on, so the app stays locked until it is done.
+	p.builder.Script("my-app", Script{Duration: 2 * time.Second})
+	ps := p.startPush(p.keyPath)
+	ps.waitFor(t, BuildStartLine)
+	syscall.Kill(-ps.cmd.Process.Pid, syscall.SIGINT)
+	ps.wait(t)
+	p.expectPush(p.keyPath, 128, ConcurrentPushError)
+	time.Sleep(3 * time.Second)
+	p.expectPush(p.keyPath, 0, "Everything up-to-date")
+
+	// A build that hangs is stopped instead.
+	p.builder.Script("my-app", Script{Hang: true})
+	p.commit("second")
+	ps = p.startPush(p.keyPath)
+	ps.waitFor(t, BuildStartLine)
+	syscall.Kill(-ps.cmd.Process.Pid, syscall.SIGINT)
+	ps.wait(t)
+	p.builder.Script("my-app", Script{})
+	for i := 0; ; i++ {
+		ps = p.startPush(p.keyPath)
+		if status := ps.wait(t); status == 0 {
+			break
+		}
+		if i == 20 || !strings.Contains(ps.Output(), ConcurrentPushError) {
+			t.Fatalf("expected the push to succeed once the hanging build was stopped:\n%s", ps.Output())
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+	if !strings.Contains(ps.Output(), "Done, my-app:v3 deployed to Workflow") {
+		t.Errorf("expected the push to deploy what the interrupted push did not:\n%s", ps.Output())
+	}
+}
+
+func TestRepoReplacesHooks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "builder-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	b := &Builder{dir: dir}
+	scripts := []Script{{}, {Output: []string{strings.Repeat("x", 64*1024)}, Duration: time.Second}}
+	repo, err := b.repo("my-app", scripts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook := filepath.Join(repo, "hooks", "pre-receive")
+
+	// Pushes rewriting the hook never leave it partly written for a push that is running it.
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if _, err := b.repo("my-app", scripts[i%2]); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 5000; i++ {
+		contents, err := ioutil.ReadFile(hook)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(contents) != hookScript(scripts[0]) && string(contents) != hookScript(scripts[1]) {
+			t.Fatalf("expected the hook to be written in full, got %d bytes", len(contents))
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	if info, err := os.Stat(hook); err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("expected the hook to be executable, got %v (%v)", info.Mode(), err)
+	}
+	entries, err := ioutil.ReadDir(filepath.Dir(hook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			t.Errorf("expected no temporary files to be left, got %s", entry.Name())
+		}
+	}
+}
+
+func TestHookScript(t *testing.T) {
+	for _, test := range []struct {
+		script   Script
+		expected string
+	}{
+		{Script{}, "#!/bin/sh\ncat > /dev/null\necho 'Starting build... but first, coffee!'\necho '...'\necho 'Build complete.'\necho 'Launching App...'\necho '...'\n"},
+		{Script{Output: []string{"it's"}, Duration: 1500 * time.Millisecond, Fail: true}, "#!/bin/sh\ncat > /dev/null\necho 'Starting build... but first, coffee!'\necho 'it'\\''s'\nsleep 1.500\nexit 1\n"},
+		{Script{Hang: true}, "#!/bin/sh\ncat > /dev/null\necho 'Starting build... but first, coffee!'\nwhile :; do sleep 1; done\n"},
+	} {
+		if got := hookScript(test.script); got != test.expected {
+			t.Errorf("hookScript(%+v):\n%s\nexpected:\n%s", test.script, got, test.expected)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package fake
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var certNameRegexp = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+var attributeNames = map[string]string{
+	"2.5.4.3":              "CN",
+	"2.5.4.6":              "C",
+	"2.5.4.7":              "L",
+	"2.5.4.8":              "ST",
+	"2.5.4.10":             "O",
+	"2.5.4.11":             "OU",
+	"1.2.840.113549.1.9.1": "emailAddress",
+}
+
+type cert struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Owner       string   `json:"owner"`
+	CommonName  string   `json:"common_name"`
+	SubjectAlt  []string `json:"san"`
+	Fingerprint string   `json:"fingerprint"`
+	Issuer      string   `json:"issuer"`
+	Subject     string   `json:"subject"`
+	Starts      string   `json:"starts"`
+	Expires     string   `json:"expires"`
+	Domains     []string `json:"domains"`
+	Created     string   `json:"created"`
+	Updated     string   `json:"updated"`
+
+	certificate string
+	key         string
+}
+
+func (c *Controller) serveCerts(req *request) {
+	switch {
+	case req.Method == "GET" && pathIs(req.path, "certs"):
+		var results []interface{}
+		for _, name := range sortedKeys(c.certs) {
+			if crt := c.certs[name]; crt.Owner == req.user.Username || req.user.IsSuperuser {
+				results = append(results, crt)
+			}
+		}
+		writeList(req, results)
+	case req.Method == "POST" && pathIs(req.path, "certs"):
+		c.addCert(req)
+	case len(req.path) >= 2:
+		crt, ok := c.certs[req.path[1]]
+		if !ok || (crt.Owner != req.user.Username && !req.user.IsSuperuser) {
+			notFound(req.w, "No Certificate matches the given query.")
+			return
+		}
+		c.serveCert(req, crt)
+	default:
+		notFound(req.w, "Not found.")
+	}
+}
+
+func (c *Controller) serveCert(req *request, crt *cert) {
+	switch {
+	case req.Method == "GET" && len(req.path) == 2:
+		writeJSON(req.w, http.StatusOK, crt)
+	case req.Method == "DELETE" && len(req.path) == 2:
+		delete(c.certs, crt.Name)
+		req.w.WriteHeader(http.StatusNoContent)
+	case req.Method == "POST" && len(req.path) == 3 && req.path[2] == "domain":
+		var body struct {
+			Domain string `json:"domain"`
+		}
+		if !decode(req, &body) {
+			return
+		}
+		if c.findDomain(body.Domain) == nil {
+			notFound(req.w, "No Domain matches the given query.")
+			return
+		}
+		crt.Domains = append(removeDomains(crt.Domains, []*domain{{Domain: body.Domain}}), body.Domain)
+		crt.Updated = now()
+		req.w.WriteHeader(http.StatusCreated)
+	case req.Method == "DELETE" && len(req.path) == 4 && req.path[2] == "domain":
+		if c.findDomain(req.path[3]) == nil {
+			notFound(req.w, "No Domain matches the given query.")
+			return
+		}
+		crt.Domains = removeDomains(crt.Domains, []*domain{{Domain: req.path[3]}})
+		crt.Updated = now()
+		req.w.WriteHeader(http.StatusNoContent)
+	default:
+		notFound(req.w, "Not found.")
+	}
+}
+
+func (c *Controller) addCert(req *request) {
+	var body struct {
+		Name        string `json:"name"`
+		Certificate string `json:"certificate"`
+		Key         string `json:"key"`
+	}
+	if !decode(req, &body) {
+		return
+	}
+	if !certNameRegexp.MatchString(body.Name) {
+		writeFieldError(req.w, "name", "Can only contain a-z (lowercase), 0-9 and hyphens")
+		return
+	}
+	if _, ok := c.certs[body.Name]; ok {
+		writeFieldError(req.w, "name", "Certificate with this name already exists.")
+		return
+	}
+	block, _ := pem.Decode([]byte(body.Certificate))
+	if block == nil || block.Type != "CERTIFICATE" {
+		writeFieldError(req.w, "certificate", "Could not load certificate")
+		return
+	}
+	parsed, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		writeFieldError(req.w, "certificate", fmt.Sprintf("Could not load certificate: %s", err))
+		return
+	}
+	if time.Now().After(parsed.NotAfter) {
+		writeFieldError(req.w, "certificate", "Certificate has expired")
+		return
+	}
+	if keyBlock, _ := pem.Decode([]byte(body.Key)); keyBlock == nil || !strings.Contains(keyBlock.Type, "PRIVATE KEY") {
+		writeFieldError(req.w, "key", "Could not load private key")
+		return
+	}
+	sum := sha256.Sum256(parsed.Raw)
+	fingerprint := make([]string, len(sum))
+	for i, b := range sum {
+		fingerprint[i] = fmt.Sprintf("%02X", b)
+	}
+	crt := &cert{
+		ID:          newUUID(),
+		Name:        body.Name,
+		Owner:       req.user.Username,
+		CommonName:  parsed.Subject.CommonName,
+		SubjectAlt:  append([]string{}, parsed.DNSNames...),
+		Fingerprint: strings.Join(fingerprint, ":"),
+		Issuer:      distinguishedName(parsed.Issuer),
+		Subject:     distinguishedName(parsed.Subject),
+		Starts:      parsed.NotBefore.UTC().Format(timeFormat),
+		Expires:     parsed.NotAfter.UTC().Format(timeFormat),
+		Domains:     []string{},
+		Created:     now(),
+		Updated:     now(),
+		certificate: body.Certificate,
+		key:         body.Key,
+	}
+	c.certs[crt.Name] = crt
+	writeJSON(req.w, http.StatusCreated, crt)
+}
+
+// distinguishedName renders a name in the slash-separated form used by the controller, e.g.
+// "/C=US/ST=CA/O=Deis/CN=www.foo.com".
+func distinguishedName(name pkix.Name) string {
+	var dn string
+	for _, atv := range name.Names {
+		attr, ok := attributeNames[atv.Type.String()]
+		if !ok {
+			attr = atv.Type.String()
+		}
+		dn += fmt.Sprintf("/%s=%v", attr, atv.Value)
+	}
+	return dn
+}
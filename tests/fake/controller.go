@@ -0,0 +1,496 @@
+package fake
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deis/workflow-e2e/tests/settings"
+)
+
+// The code in this package implements an in-process stand-in for the Workflow controller. It
+// speaks enough of the v2 REST API for the `deis` CLI to register and log in users and to manage
+// apps, config, builds, releases, domains, certs, perms and keys, so that the helpers in tests/cmd
+// can be exercised without a cluster. Nothing is ever scheduled; state lives in memory only.
+
+const (
+	// APIVersion is the value of the DEIS_API_VERSION header returned by the fake controller.
+	APIVersion = "2.3"
+	// PlatformVersion is the value of the DEIS_PLATFORM_VERSION header returned by the fake
+	// controller.
+	PlatformVersion = "fake"
+
+	timeFormat = "2006-01-02T15:04:05Z"
+)
+
+// Controller is a fake Workflow controller listening on a local address.
+type Controller struct {
+	// URL is the base URL of the fake controller, suitable for `deis auth:login`.
+	URL string
+
+	server *httptest.Server
+	mu     sync.Mutex
+
+	users  map[string]*user
+	tokens map[string]string
+	apps   map[string]*app
+	certs  map[string]*cert
+	keys   map[string]*key
+}
+
+// NewController starts a fake controller and returns it. Callers should Close it when done.
+func NewController() *Controller {
+	c := &Controller{
+		users:  map[string]*user{},
+		tokens: map[string]string{},
+		apps:   map[string]*app{},
+		certs:  map[string]*cert{},
+		keys:   map[string]*key{},
+	}
+	c.server = httptest.NewServer(http.HandlerFunc(c.serveHTTP))
+	c.URL = c.server.URL
+	return c
+}
+
+// Close shuts down the fake controller.
+func (c *Controller) Close() {
+	c.server.Close()
+}
+
+type user struct {
+	Username    string `json:"username"`
+	Email       string `json:"email"`
+	FirstName   string `json:"first_name"`
+	LastName    string `json:"last_name"`
+	LastLogin   string `json:"last_login"`
+	IsSuperuser bool   `json:"is_superuser"`
+	IsStaff     bool   `json:"is_staff"`
+	IsActive    bool   `json:"is_active"`
+	DateJoined  string `json:"date_joined"`
+
+	password string
+}
+
+type key struct {
+	UUID    string `json:"uuid"`
+	ID      string `json:"id"`
+	Owner   string `json:"owner"`
+	Public  string `json:"public"`
+	Created string `json:"created"`
+	Updated string `json:"updated"`
+}
+
+// request carries the state of a single API call through the handlers.
+type request struct {
+	*http.Request
+	w    http.ResponseWriter
+	user *user
+	path []string
+}
+
+func (c *Controller) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("DEIS_API_VERSION", APIVersion)
+	w.Header().Set("DEIS_PLATFORM_VERSION", PlatformVersion)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path := strings.Trim(r.URL.Path, "/")
+	if path != "v2" && !strings.HasPrefix(path, "v2/") {
+		notFound(w, "Not found.")
+		return
+	}
+	req := &request{Request: r, w: w, path: strings.Split(strings.TrimPrefix(path, "v2"), "/")[1:]}
+	if fields := strings.Fields(r.Header.Get("Authorization")); len(fields) == 2 && fields[0] == "token" {
+		if username, ok := c.tokens[fields[1]]; ok {
+			req.user = c.users[username]
+		}
+	}
+
+	if len(req.path) == 0 {
+		// The SDK probes /v2/ and expects an unauthenticated request to be rejected.
+		if req.user == nil {
+			writeError(w, http.StatusUnauthorized, "Authentication credentials were not provided.")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{})
+		return
+	}
+
+	switch req.path[0] {
+	case "auth":
+		c.serveAuth(req)
+		return
+	}
+
+	if req.user == nil {
+		writeError(w, http.StatusUnauthorized, "Invalid token.")
+		return
+	}
+	switch req.path[0] {
+	case "apps":
+		c.serveApps(req)
+	case "certs":
+		c.serveCerts(req)
+	case "keys":
+		c.serveKeys(req)
+	case "users":
+		c.serveUsers(req)
+	default:
+		notFound(w, "Not found.")
+	}
+}
+
+func (c *Controller) serveAuth(req *request) {
+	switch {
+	case req.Method == "POST" && pathIs(req.path, "auth", "register"):
+		c.register(req)
+	case req.Method == "POST" && pathIs(req.path, "auth", "login"):
+		c.login(req)
+	case req.user == nil:
+		writeError(req.w, http.StatusUnauthorized, "Invalid token.")
+	case req.Method == "GET" && pathIs(req.path, "auth", "whoami"):
+		writeJSON(req.w, http.StatusOK, req.user)
+	case req.Method == "POST" && pathIs(req.path, "auth", "tokens"):
+		c.regenerate(req)
+	case req.Method == "DELETE" && pathIs(req.path, "auth", "cancel"):
+		c.cancel(req)
+	default:
+		notFound(req.w, "Not found.")
+	}
+}
+
+func (c *Controller) register(req *request) {
+	var body struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Email    string `json:"email"`
+	}
+	if !decode(req, &body) {
+		return
+	}
+	// The first user to register becomes the admin; after that registration is 'admin_only'.
+	first := len(c.users) == 0
+	if !first && (req.user == nil || !req.user.IsSuperuser) {
+		forbidden(req.w)
+		return
+	}
+	if body.Username == "" || body.Password == "" {
+		writeFieldError(req.w, "username", "This field may not be blank.")
+		return
+	}
+	if _, ok := c.users[body.Username]; ok {
+		writeFieldError(req.w, "username", "A user with that username already exists.")
+		return
+	}
+	u := &user{
+		Username:    body.Username,
+		Email:       body.Email,
+		IsSuperuser: first,
+		IsStaff:     first,
+		IsActive:    true,
+		DateJoined:  now(),
+		password:    body.Password,
+	}
+	c.users[u.Username] = u
+	writeJSON(req.w, http.StatusCreated, u)
+}
+
+func (c *Controller) login(req *request) {
+	var body struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if !decode(req, &body) {
+		return
+	}
+	u, ok := c.users[body.Username]
+	if !ok || u.password != body.Password {
+		writeFieldError(req.w, "non_field_errors", "Unable to log in with provided credentials.")
+		return
+	}
+	u.LastLogin = now()
+	writeJSON(req.w, http.StatusOK, map[string]string{"token": c.tokenFor(u.Username)})
+}
+
+func (c *Controller) regenerate(req *request) {
+	var body struct {
+		Username string `json:"username"`
+		All      bool   `json:"all"`
+	}
+	if !decode(req, &body) {
+		return
+	}
+	target := req.user
+	if body.Username != "" || body.All {
+		if !req.user.IsSuperuser {
+			forbidden(req.w)
+			return
+		}
+	}
+	if body.All {
+		for username := range c.users {
+			c.revokeTokens(username)
+			c.tokenFor(username)
+		}
+		writeJSON(req.w, http.StatusCreated, map[string]string{})
+		return
+	}
+	if body.Username != "" {
+		var ok bool
+		if target, ok = c.users[body.Username]; !ok {
+			notFound(req.w, "No User matches the given query.")
+			return
+		}
+	}
+	c.revokeTokens(target.Username)
+	writeJSON(req.w, http.StatusCreated, map[string]string{"token": c.tokenFor(target.Username)})
+}
+
+func (c *Controller) cancel(req *request) {
+	var body struct {
+		Username string `json:"username"`
+	}
+	if !decode(req, &body) {
+		return
+	}
+	target := req.user
+	if body.Username != "" && body.Username != req.user.Username {
+		if !req.user.IsSuperuser {
+			forbidden(req.w)
+			return
+		}
+		var ok bool
+		if target, ok = c.users[body.Username]; !ok {
+			notFound(req.w, "No User matches the given query.")
+			return
+		}
+	}
+	for _, a := range c.apps {
+		if a.Owner == target.Username {
+			writeFieldError(req.w, "detail", fmt.Sprintf("%s still has applications assigned. Delete or transfer ownership", target.Username))
+			return
+		}
+	}
+	for name, crt := range c.certs {
+		if crt.Owner == target.Username {
+			delete(c.certs, name)
+		}
+	}
+	for id, k := range c.keys {
+		if k.Owner == target.Username {
+			delete(c.keys, id)
+		}
+	}
+	c.revokeTokens(target.Username)
+	delete(c.users, target.Username)
+	req.w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *Controller) serveKeys(req *request) {
+	switch {
+	case req.Method == "GET" && pathIs(req.path, "keys"):
+		var results []interface{}
+		for _, id := range sortedKeys(c.keys) {
+			if k := c.keys[id]; k.Owner == req.user.Username {
+				results = append(results, k)
+			}
+		}
+		writeList(req, results)
+	case req.Method == "POST" && pathIs(req.path, "keys"):
+		var body struct {
+			ID     string `json:"id"`
+			Public string `json:"public"`
+		}
+		if !decode(req, &body) {
+			return
+		}
+		if _, ok := c.keys[body.ID]; ok {
+			writeFieldError(req.w, "id", "Key with this id already exists.")
+			return
+		}
+		for _, k := range c.keys {
+			if k.Public == body.Public {
+				writeFieldError(req.w, "public", "Public Key is already in use")
+				return
+			}
+		}
+		k := &key{
+			UUID:    newUUID(),
+			ID:      body.ID,
+			Owner:   req.user.Username,
+			Public:  body.Public,
+			Created: now(),
+			Updated: now(),
+		}
+		c.keys[k.ID] = k
+		writeJSON(req.w, http.StatusCreated, k)
+	case req.Method == "DELETE" && len(req.path) == 2:
+		k, ok := c.keys[req.path[1]]
+		if !ok || (k.Owner != req.user.Username && !req.user.IsSuperuser) {
+			notFound(req.w, "No Key matches the given query.")
+			return
+		}
+		delete(c.keys, k.ID)
+		req.w.WriteHeader(http.StatusNoContent)
+	default:
+		notFound(req.w, "Not found.")
+	}
+}
+
+func (c *Controller) serveUsers(req *request) {
+	if req.Method != "GET" || !pathIs(req.path, "users") {
+		notFound(req.w, "Not found.")
+		return
+	}
+	if !req.user.IsSuperuser {
+		forbidden(req.w)
+		return
+	}
+	var results []interface{}
+	for _, username := range sortedKeys(c.users) {
+		results = append(results, c.users[username])
+	}
+	writeList(req, results)
+}
+
+// tokenFor returns a token for the given user, issuing one if the user has none.
+func (c *Controller) tokenFor(username string) string {
+	for token, owner := range c.tokens {
+		if owner == username {
+			return token
+		}
+	}
+	token := randomHex(20)
+	c.tokens[token] = username
+	return token
+}
+
+func (c *Controller) revokeTokens(username string) {
+	for token, owner := range c.tokens {
+		if owner == username {
+			delete(c.tokens, token)
+		}
+	}
+}
+
+func pathIs(path []string, elems ...string) bool {
+	if len(path) != len(elems) {
+		return false
+	}
+	for i := range path {
+		if path[i] != elems[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// decode unmarshals the request body into v, writing a 400 response and returning false if the
+// body is not valid JSON. An empty body is not an error.
+func decode(req *request, v interface{}) bool {
+	if req.Body == nil || req.ContentLength == 0 {
+		return true
+	}
+	if err := json.NewDecoder(req.Body).Decode(v); err != nil {
+		writeFieldError(req.w, "detail", fmt.Sprintf("JSON parse error - %s", err))
+		return false
+	}
+	return true
+}
+
+// writeList writes results in the paginated envelope used by the controller's list endpoints,
+// honoring the "limit" query parameter.
+func writeList(req *request, results []interface{}) {
+	count := len(results)
+	if limit, err := strconv.Atoi(req.URL.Query().Get("limit")); err == nil && limit > 0 && limit < count {
+		results = results[:limit]
+	}
+	if results == nil {
+		results = []interface{}{}
+	}
+	writeJSON(req.w, http.StatusOK, map[string]interface{}{
+		"count":    count,
+		"next":     nil,
+		"previous": nil,
+		"results":  results,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, detail string) {
+	writeJSON(w, status, map[string]string{"detail": detail})
+}
+
+// writeFieldError writes a 400 response in the field-keyed form used by django-rest-framework
+// validation errors.
+func writeFieldError(w http.ResponseWriter, field, msg string) {
+	writeJSON(w, http.StatusBadRequest, map[string][]string{field: {msg}})
+}
+
+func notFound(w http.ResponseWriter, detail string) {
+	writeError(w, http.StatusNotFound, detail)
+}
+
+func forbidden(w http.ResponseWriter) {
+	writeError(w, http.StatusForbidden, "You do not have permission to perform this action.")
+}
+
+func now() string {
+	return time.Now().UTC().Format(timeFormat)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func newUUID() string {
+	h := randomHex(16)
+	return fmt.Sprintf("%s-%s-%s-%s-%s", h[0:8], h[8:12], h[12:16], h[16:20], h[20:32])
+}
+
+// sortedKeys returns the keys of a map with string keys in sorted order, so that list endpoints
+// return results in a stable order.
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]*user:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]*app:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]*cert:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]*key:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// appURL returns the URL the router would serve the named app on.
+func appURL(name string) string {
+	return fmt.Sprintf("%s.%s", name, settings.DeisRootHostname)
+}
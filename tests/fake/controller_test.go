@@ -0,0 +1,325 @@
+package fake
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// apiTest talks to a fake controller over HTTP, the way the deis CLI does.
+type apiTest struct {
+	t          *testing.T
+	controller *Controller
+}
+
+func newAPITest(t *testing.T) *apiTest {
+	return &apiTest{t: t, controller: NewController()}
+}
+
+func (a *apiTest) Close() {
+	a.controller.Close()
+}
+
+// do sends a request with an optional token and JSON body, and returns the status code and the
+// decoded JSON response, if there is one.
+func (a *apiTest) do(method, path, token string, body interface{}) (int, map[string]interface{}) {
+	var encoded []byte
+	if body != nil {
+		var err error
+		if encoded, err = json.Marshal(body); err != nil {
+			a.t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, a.controller.URL+path, bytes.NewReader(encoded))
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "token "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var decoded map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&decoded)
+	return resp.StatusCode, decoded
+}
+
+// expect sends a request and fails the test unless it gets the given status code.
+func (a *apiTest) expect(status int, method, path, token string, body interface{}) map[string]interface{} {
+	got, decoded := a.do(method, path, token, body)
+	if got != status {
+		a.t.Fatalf("%s %s: expected %d, got %d: %v", method, path, status, got, decoded)
+	}
+	return decoded
+}
+
+// user registers a user, as the given admin unless it is the first one, and logs it in.
+func (a *apiTest) user(username, adminToken string) string {
+	a.expect(http.StatusCreated, "POST", "/v2/auth/register/", adminToken,
+		map[string]string{"username": username, "password": username, "email": username + "@example.com"})
+	login := a.expect(http.StatusOK, "POST", "/v2/auth/login/", "",
+		map[string]string{"username": username, "password": username})
+	return login["token"].(string)
+}
+
+// results returns the field of each result in a list response.
+func results(list map[string]interface{}, field string) []string {
+	var values []string
+	for _, result := range list["results"].([]interface{}) {
+		values = append(values, result.(map[string]interface{})[field].(string))
+	}
+	return values
+}
+
+func TestAuth(t *testing.T) {
+	a := newAPITest(t)
+	defer a.Close()
+
+	admin := a.user("admin", "")
+	whoami := a.expect(http.StatusOK, "GET", "/v2/auth/whoami/", admin, nil)
+	if whoami["username"] != "admin" || whoami["is_superuser"] != true {
+		t.Errorf("expected the first user to be a superuser, got %v", whoami)
+	}
+
+	// After the first user, only an admin may register users.
+	a.expect(http.StatusForbidden, "POST", "/v2/auth/register/", "",
+		map[string]string{"username": "eve", "password": "eve", "email": "eve@example.com"})
+	bob := a.user("bob", admin)
+	if whoami := a.expect(http.StatusOK, "GET", "/v2/auth/whoami/", bob, nil); whoami["is_superuser"] != false {
+		t.Errorf("expected a later user not to be a superuser, got %v", whoami)
+	}
+	a.expect(http.StatusBadRequest, "POST", "/v2/auth/register/", admin,
+		map[string]string{"username": "bob", "password": "bob", "email": "bob@example.com"})
+
+	tests := []struct {
+		method string
+		path   string
+		token  string
+		body   interface{}
+		status int
+	}{
+		{"POST", "/v2/auth/login/", "", map[string]string{"username": "bob", "password": "wrong"}, http.StatusBadRequest},
+		{"POST", "/v2/auth/login/", "", map[string]string{"username": "nobody", "password": "nobody"}, http.StatusBadRequest},
+		{"GET", "/v2/", "", nil, http.StatusUnauthorized},
+		{"GET", "/v2/", bob, nil, http.StatusOK},
+		{"GET", "/v2/auth/whoami/", "", nil, http.StatusUnauthorized},
+		{"GET", "/v2/apps/", "not-a-token", nil, http.StatusUnauthorized},
+		{"GET", "/v2/users/", bob, nil, http.StatusForbidden},
+		{"GET", "/v2/users/", admin, nil, http.StatusOK},
+		{"POST", "/v2/auth/tokens/", bob, map[string]string{"username": "admin"}, http.StatusForbidden},
+		{"DELETE", "/v2/auth/cancel/", bob, map[string]string{"username": "admin"}, http.StatusForbidden},
+		{"GET", "/v1/apps/", admin, nil, http.StatusNotFound},
+	}
+	for _, test := range tests {
+		if got, body := a.do(test.method, test.path, test.token, test.body); got != test.status {
+			t.Errorf("%s %s as %q: expected %d, got %d: %v", test.method, test.path, test.token, test.status, got, body)
+		}
+	}
+
+	// Regenerating a token revokes the old one.
+	regenerated := a.expect(http.StatusCreated, "POST", "/v2/auth/tokens/", bob, map[string]string{})
+	a.expect(http.StatusUnauthorized, "GET", "/v2/auth/whoami/", bob, nil)
+	bob = regenerated["token"].(string)
+	a.expect(http.StatusOK, "GET", "/v2/auth/whoami/", bob, nil)
+
+	// A user who owns apps cannot be cancelled.
+	a.expect(http.StatusCreated, "POST", "/v2/apps/", bob, map[string]string{"id": "bobs-app"})
+	cancel := a.expect(http.StatusBadRequest, "DELETE", "/v2/auth/cancel/", bob, map[string]string{})
+	if !strings.Contains(cancel["detail"].([]interface{})[0].(string), "still has applications assigned") {
+		t.Errorf("expected cancelling a user who owns apps to fail, got %v", cancel)
+	}
+	a.expect(http.StatusNoContent, "DELETE", "/v2/apps/bobs-app/", bob, nil)
+	a.expect(http.StatusNoContent, "DELETE", "/v2/auth/cancel/", admin, map[string]string{"username": "bob"})
+	a.expect(http.StatusUnauthorized, "GET", "/v2/auth/whoami/", bob, nil)
+	a.expect(http.StatusBadRequest, "POST", "/v2/auth/login/", "", map[string]string{"username": "bob", "password": "bob"})
+}
+
+func TestApps(t *testing.T) {
+	a := newAPITest(t)
+	defer a.Close()
+	admin := a.user("admin", "")
+	bob := a.user("bob", admin)
+	alice := a.user("alice", admin)
+
+	created := a.expect(http.StatusCreated, "POST", "/v2/apps/", bob, map[string]string{"id": "bobs-app"})
+	if created["owner"] != "bob" || created["url"] != appURL("bobs-app") {
+		t.Errorf("expected bob to own bobs-app at %s, got %v", appURL("bobs-app"), created)
+	}
+	a.expect(http.StatusCreated, "POST", "/v2/apps/", alice, map[string]string{"id": "alices-app"})
+	for _, id := range []string{"Bobs-App", "bobs_app", "-bobs-app", "bobs--app"} {
+		a.expect(http.StatusBadRequest, "POST", "/v2/apps/", bob, map[string]string{"id": id})
+	}
+	a.expect(http.StatusBadRequest, "POST", "/v2/apps/", alice, map[string]string{"id": "bobs-app"})
+
+	// Users see their own apps; the admin sees them all.
+	for _, test := range []struct {
+		token    string
+		expected []string
+	}{
+		{bob, []string{"bobs-app"}},
+		{alice, []string{"alices-app"}},
+		{admin, []string{"alices-app", "bobs-app"}},
+	} {
+		list := a.expect(http.StatusOK, "GET", "/v2/apps/", test.token, nil)
+		if got := results(list, "id"); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("expected %v, got %v", test.expected, got)
+		}
+	}
+	if list := a.expect(http.StatusOK, "GET", "/v2/apps/?limit=1", admin, nil); list["count"] != 2.0 || len(list["results"].([]interface{})) != 1 {
+		t.Errorf("expected one of two apps, got %v", list)
+	}
+
+	// Other users' apps are forbidden, and missing ones are not found.
+	for _, path := range []string{"/v2/apps/bobs-app/", "/v2/apps/bobs-app/config/", "/v2/apps/bobs-app/releases/"} {
+		a.expect(http.StatusForbidden, "GET", path, alice, nil)
+	}
+	a.expect(http.StatusForbidden, "DELETE", "/v2/apps/bobs-app/", alice, nil)
+	a.expect(http.StatusNotFound, "GET", "/v2/apps/no-such-app/", bob, nil)
+	a.expect(http.StatusOK, "GET", "/v2/apps/bobs-app/", admin, nil)
+
+	// Transferring an app hands it over, after which the old owner has no access.
+	a.expect(http.StatusNoContent, "POST", "/v2/apps/bobs-app/", bob, map[string]string{"owner": "alice"})
+	if app := a.expect(http.StatusOK, "GET", "/v2/apps/bobs-app/", alice, nil); app["owner"] != "alice" {
+		t.Errorf("expected alice to own bobs-app, got %v", app)
+	}
+	a.expect(http.StatusForbidden, "GET", "/v2/apps/bobs-app/", bob, nil)
+
+	a.expect(http.StatusNoContent, "DELETE", "/v2/apps/bobs-app/", admin, nil)
+	a.expect(http.StatusNotFound, "GET", "/v2/apps/bobs-app/", admin, nil)
+}
+
+func TestConfig(t *testing.T) {
+	a := newAPITest(t)
+	defer a.Close()
+	admin := a.user("admin", "")
+	a.expect(http.StatusCreated, "POST", "/v2/apps/", admin, map[string]string{"id": "my-app"})
+
+	a.expect(http.StatusCreated, "POST", "/v2/apps/my-app/config/", admin,
+		map[string]interface{}{"values": map[string]string{"POWERED_BY": "Deis", "FOO": "bar"}})
+	a.expect(http.StatusCreated, "POST", "/v2/apps/my-app/config/", admin,
+		map[string]interface{}{"values": map[string]interface{}{"FOO": nil}, "memory": map[string]string{"cmd": "64M"}})
+	config := a.expect(http.StatusOK, "GET", "/v2/apps/my-app/config/", admin, nil)
+	if expected := map[string]interface{}{"POWERED_BY": "Deis"}; !reflect.DeepEqual(config["values"], expected) {
+		t.Errorf("expected values %v, got %v", expected, config["values"])
+	}
+	if expected := map[string]interface{}{"cmd": "64M"}; !reflect.DeepEqual(config["memory"], expected) {
+		t.Errorf("expected memory %v, got %v", expected, config["memory"])
+	}
+
+	// Each change is released, and the summary names the sections that changed.
+	releases := a.expect(http.StatusOK, "GET", "/v2/apps/my-app/releases/", admin, nil)
+	expected := []string{"admin changed memory, values", "admin changed values", "admin created initial release"}
+	if got := results(releases, "summary"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected summaries %q, got %q", expected, got)
+	}
+	latest := releases["results"].([]interface{})[0].(map[string]interface{})
+	if latest["config"] != config["uuid"] {
+		t.Errorf("expected the latest release to use config %v, got %v", config["uuid"], latest["config"])
+	}
+
+	if got, body := a.do("POST", "/v2/apps/my-app/config/", admin, "not a config"); got != http.StatusBadRequest {
+		t.Errorf("expected a malformed config to be rejected, got %d: %v", got, body)
+	}
+}
+
+func TestReleases(t *testing.T) {
+	a := newAPITest(t)
+	defer a.Close()
+	admin := a.user("admin", "")
+	a.expect(http.StatusCreated, "POST", "/v2/apps/", admin, map[string]string{"id": "my-app"})
+
+	build := a.expect(http.StatusCreated, "POST", "/v2/apps/my-app/builds/", admin, map[string]string{"image": "deis/example-go"})
+	a.expect(http.StatusCreated, "POST", "/v2/apps/my-app/config/", admin,
+		map[string]interface{}{"values": map[string]string{"POWERED_BY": "Deis"}})
+	v2 := a.expect(http.StatusOK, "GET", "/v2/apps/my-app/releases/v2/", admin, nil)
+	if v2["build"] != build["uuid"] || v2["summary"] != "admin deployed deis/example-go" {
+		t.Errorf("expected v2 to deploy build %v, got %v", build["uuid"], v2)
+	}
+
+	// Rolling back with no version goes back one release, to v2's build and config.
+	rollback := a.expect(http.StatusCreated, "POST", "/v2/apps/my-app/releases/rollback/", admin, map[string]string{})
+	if rollback["version"] != 4.0 {
+		t.Errorf("expected the rollback to be v4, got %v", rollback)
+	}
+	v4 := a.expect(http.StatusOK, "GET", "/v2/apps/my-app/releases/v4/", admin, nil)
+	if v4["build"] != v2["build"] || v4["config"] != v2["config"] || v4["summary"] != "admin rolled back to v2" {
+		t.Errorf("expected v4 to roll back to v2 (%v), got %v", v2, v4)
+	}
+	a.expect(http.StatusCreated, "POST", "/v2/apps/my-app/releases/rollback/", admin, map[string]int{"version": 1})
+	if v5 := a.expect(http.StatusOK, "GET", "/v2/apps/my-app/releases/v5/", admin, nil); v5["build"] != "" {
+		t.Errorf("expected v5 to roll back to v1, which has no build, got %v", v5)
+	}
+
+	releases := a.expect(http.StatusOK, "GET", "/v2/apps/my-app/releases/", admin, nil)
+	if got := results(releases, "summary"); len(got) != 5 || got[0] != "admin rolled back to v1" {
+		t.Errorf("expected five releases, newest first, got %q", got)
+	}
+	for _, version := range []string{"v0", "v6", "latest"} {
+		a.expect(http.StatusNotFound, "GET", "/v2/apps/my-app/releases/"+version+"/", admin, nil)
+	}
+	a.expect(http.StatusNotFound, "POST", "/v2/apps/my-app/releases/rollback/", admin, map[string]int{"version": 6})
+}
+
+func TestPerms(t *testing.T) {
+	a := newAPITest(t)
+	defer a.Close()
+	admin := a.user("admin", "")
+	bob := a.user("bob", admin)
+	alice := a.user("alice", admin)
+	carol := a.user("carol", admin)
+	a.expect(http.StatusCreated, "POST", "/v2/apps/", bob, map[string]string{"id": "bobs-app"})
+
+	a.expect(http.StatusForbidden, "GET", "/v2/apps/bobs-app/", alice, nil)
+	a.expect(http.StatusCreated, "POST", "/v2/apps/bobs-app/perms/", bob, map[string]string{"username": "alice"})
+	a.expect(http.StatusNotFound, "POST", "/v2/apps/bobs-app/perms/", bob, map[string]string{"username": "nobody"})
+	perms := a.expect(http.StatusOK, "GET", "/v2/apps/bobs-app/perms/", alice, nil)
+	if expected := []interface{}{"alice"}; !reflect.DeepEqual(perms["users"], expected) {
+		t.Errorf("expected users %v, got %v", expected, perms["users"])
+	}
+
+	// A collaborator can use the app but not share it, delete it, or remove others from it.
+	a.expect(http.StatusOK, "GET", "/v2/apps/bobs-app/", alice, nil)
+	a.expect(http.StatusCreated, "POST", "/v2/apps/bobs-app/config/", alice, map[string]interface{}{"values": map[string]string{"FOO": "bar"}})
+	a.expect(http.StatusForbidden, "POST", "/v2/apps/bobs-app/perms/", alice, map[string]string{"username": "carol"})
+	a.expect(http.StatusForbidden, "DELETE", "/v2/apps/bobs-app/", alice, nil)
+	a.expect(http.StatusCreated, "POST", "/v2/apps/bobs-app/perms/", admin, map[string]string{"username": "carol"})
+	a.expect(http.StatusForbidden, "DELETE", "/v2/apps/bobs-app/perms/carol/", alice, nil)
+
+	// Collaborators may remove themselves.
+	a.expect(http.StatusNoContent, "DELETE", "/v2/apps/bobs-app/perms/carol/", carol, nil)
+	a.expect(http.StatusForbidden, "GET", "/v2/apps/bobs-app/", carol, nil)
+	a.expect(http.StatusNoContent, "DELETE", "/v2/apps/bobs-app/perms/alice/", bob, nil)
+	a.expect(http.StatusForbidden, "GET", "/v2/apps/bobs-app/", alice, nil)
+	a.expect(http.StatusNotFound, "DELETE", "/v2/apps/bobs-app/perms/alice/", bob, nil)
+}
+
+func TestScale(t *testing.T) {
+	a := newAPITest(t)
+	defer a.Close()
+	admin := a.user("admin", "")
+	a.expect(http.StatusCreated, "POST", "/v2/apps/", admin, map[string]string{"id": "my-app"})
+
+	if body := a.expect(http.StatusMethodNotAllowed, "GET", "/v2/apps/my-app/scale/", admin, nil); body["detail"] != `Method "GET" not allowed.` {
+		t.Errorf("expected GET to be disallowed, got %v", body)
+	}
+	a.expect(http.StatusBadRequest, "POST", "/v2/apps/my-app/scale/", admin, map[string]int{"cmd": 2})
+
+	// The first build scales cmd to one, then scaling changes the pods listed.
+	a.expect(http.StatusCreated, "POST", "/v2/apps/my-app/builds/", admin, map[string]string{"image": "deis/example-go"})
+	if pods := a.expect(http.StatusOK, "GET", "/v2/apps/my-app/pods/", admin, nil); pods["count"] != 1.0 {
+		t.Errorf("expected one pod after the first build, got %v", pods)
+	}
+	a.expect(http.StatusNoContent, "POST", "/v2/apps/my-app/scale/", admin, map[string]int{"cmd": 3})
+	pods := a.expect(http.StatusOK, "GET", "/v2/apps/my-app/pods/", admin, nil)
+	expected := []string{"my-app-cmd-2-0", "my-app-cmd-2-1", "my-app-cmd-2-2"}
+	if got := results(pods, "name"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected pods %v, got %v", expected, got)
+	}
+}
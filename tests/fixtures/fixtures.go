@@ -0,0 +1,302 @@
+package fixtures
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/deis/workflow-e2e/tests/settings"
+
+	. "github.com/onsi/gomega"
+)
+
+// The code in this package writes the sample apps that specs push to the builder into local git
+// repositories, so that specs need not clone them from GitHub. Every app serves "Powered by" and
+// the value of $POWERED_BY, or "Deis" if it is not set, on $PORT.
+
+// Kind is a kind of sample app.
+type Kind string
+
+// Kinds of sample app.
+const (
+	// Dockerfile is an app built from a Dockerfile that serves HTTP.
+	Dockerfile Kind = "dockerfile-http"
+	// DockerfileProcfile is an app built from a Dockerfile whose web process is declared in a
+	// Procfile.
+	DockerfileProcfile Kind = "dockerfile-procfile-http"
+	// BrokenDockerfile is an app whose Dockerfile has an instruction that does not exist, so
+	// building it fails with "Unknown instruction: BOGUS".
+	BrokenDockerfile Kind = "dockerfile-broken"
+	// Go, NodeJS, PHP and Python are small apps with a dependency each, so that the buildpack
+	// for each language has something to install: a vendored package, Express, the mbstring
+	// extension and Flask.
+	Go     Kind = "go"
+	NodeJS Kind = "nodejs"
+	PHP    Kind = "php"
+	Python Kind = "python"
+)
+
+// DefaultBanner is what every app serves unless $POWERED_BY is set.
+const DefaultBanner = "Powered by Deis"
+
+// DefaultBaseImage is the image apps built from a Dockerfile are based on, unless
+// settings.FixtureBaseImage says otherwise.
+const DefaultBaseImage = "busybox"
+
+// templates hold the files of each kind of app, which are rendered with a templateData.
+var templates = map[Kind]map[string]string{
+	Dockerfile: {
+		"Dockerfile": dockerfileHTTP,
+	},
+	DockerfileProcfile: {
+		"Dockerfile": dockerfileHTTP,
+		"Procfile":   "web: " + busyboxHTTPD + "\n",
+	},
+	BrokenDockerfile: {
+		"Dockerfile": dockerfileHTTP + "BOGUS command\n",
+	},
+	Go: {
+		"main.go": goMain,
+		// The buildpack builds what is vendored rather than fetching anything.
+		"vendor/github.com/deis/example-go-banner/banner.go": goBanner,
+		"Godeps/Godeps.json": `{
+	"ImportPath": "github.com/deis/example-go",
+	"GoVersion": "go1.7",
+	"Deps": [
+		{
+			"ImportPath": "github.com/deis/example-go-banner"
+		}
+	]
+}
+`,
+		// The buildpack names the binary after the last element of the import path.
+		"Procfile": "web: example-go\n",
+	},
+	NodeJS: {
+		"package.json": `{
+  "name": "{{.Name}}",
+  "version": "1.0.0",
+  "private": true,
+  "scripts": {
+    "start": "node server.js"
+  },
+  "dependencies": {
+    "express": "^4.14.0"
+  }
+}
+`,
+		"server.js": nodeServer,
+		"Procfile":  "web: node server.js\n",
+	},
+	PHP: {
+		"composer.json": composerJSON,
+		// The buildpack will not install what composer.json requires without a lock file.
+		"composer.lock": composerLock,
+		"index.php":     phpIndex,
+	},
+	Python: {
+		"requirements.txt": "Flask==0.12.2\n",
+		"app.py":           pythonApp,
+		"Procfile":         "web: python app.py\n",
+	},
+}
+
+// busyboxHTTPD serves the banner with the httpd built into busybox.
+const busyboxHTTPD = `/bin/sh -c 'mkdir -p /www && echo "Powered by ${POWERED_BY:-Deis}" > /www/index.html && exec httpd -f -p "${PORT:-80}" -h /www'`
+
+const dockerfileHTTP = `FROM {{.BaseImage}}
+ENV PORT 80
+EXPOSE 80
+CMD ` + busyboxHTTPD + `
+`
+
+const goMain = `package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/deis/example-go-banner"
+)
+
+func main() {
+	poweredBy := os.Getenv("POWERED_BY")
+	if poweredBy == "" {
+		poweredBy = "Deis"
+	}
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "5000"
+	}
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, banner.For(poweredBy))
+	})
+	if err := http.ListenAndServe(":"+port, nil); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+`
+
+const goBanner = `// Package banner says what an app is powered by.
+package banner
+
+import "fmt"
+
+// For returns the banner of an app powered by poweredBy.
+func For(poweredBy string) string {
+	return fmt.Sprintf("Powered by %s\n", poweredBy)
+}
+`
+
+const nodeServer = `var express = require('express');
+
+var poweredBy = process.env.POWERED_BY || 'Deis';
+var port = process.env.PORT || 5000;
+
+var app = express();
+app.get('/', function (req, res) {
+  res.type('text/plain').send('Powered by ' + poweredBy + '\n');
+});
+app.listen(port);
+`
+
+const composerJSON = `{
+    "require": {
+        "ext-mbstring": "*"
+    }
+}
+`
+
+// composerLock locks composerJSON; its content-hash is the MD5 sum of
+// {"require":{"ext-mbstring":"*"}}, the part of composer.json that composer hashes.
+const composerLock = `{
+    "_readme": [
+        "This file locks the dependencies of your project to a known state",
+        "Read more about it at https://getcomposer.org/doc/01-basic-usage.md#composer-lock-the-lock-file",
+        "This file is @generated automatically"
+    ],
+    "content-hash": "adc392ef7340a30855efcdacc40133fd",
+    "packages": [],
+    "packages-dev": [],
+    "aliases": [],
+    "minimum-stability": "stable",
+    "stability-flags": [],
+    "prefer-stable": false,
+    "prefer-lowest": false,
+    "platform": {
+        "ext-mbstring": "*"
+    },
+    "platform-dev": []
+}
+`
+
+const phpIndex = `<?php
+$poweredBy = getenv("POWERED_BY") ?: "Deis";
+echo "Powered by " . mb_convert_encoding($poweredBy, "UTF-8", "UTF-8") . "\n";
+`
+
+const pythonApp = `import os
+
+from flask import Flask
+
+app = Flask(__name__)
+
+
+@app.route('/')
+def index():
+    return 'Powered by %s\n' % os.environ.get('POWERED_BY', 'Deis'), 200, {'Content-Type': 'text/plain'}
+
+
+if __name__ == '__main__':
+    app.run(host='0.0.0.0', port=int(os.environ.get('PORT', 5000)))
+`
+
+type templateData struct {
+	// Name is the name of the directory the app is written to.
+	Name      string
+	BaseImage string
+}
+
+// Kinds returns every kind of app, sorted.
+func Kinds() []Kind {
+	var kinds []string
+	for kind := range templates {
+		kinds = append(kinds, string(kind))
+	}
+	sort.Strings(kinds)
+	all := make([]Kind, len(kinds))
+	for i, kind := range kinds {
+		all[i] = Kind(kind)
+	}
+	return all
+}
+
+// New writes an app of the given kind to dir, which must not exist yet, and commits it to a new
+// git repository on the master branch. It returns the banner the app serves.
+func New(dir string, kind Kind) (string, error) {
+	files, ok := templates[kind]
+	if !ok {
+		return "", fmt.Errorf("unknown kind of app %q", kind)
+	}
+	if _, err := os.Stat(dir); err == nil {
+		return "", fmt.Errorf("%s already exists", dir)
+	}
+	data := templateData{Name: filepath.Base(dir), BaseImage: settings.FixtureBaseImage}
+	if data.BaseImage == "" {
+		data.BaseImage = DefaultBaseImage
+	}
+	for name, text := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return "", err
+		}
+		if err := render(path, text, data); err != nil {
+			return "", fmt.Errorf("writing %s: %s", name, err)
+		}
+	}
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		// Whatever init.defaultBranch says, apps are pushed from master.
+		{"checkout", "--quiet", "-b", "master"},
+		{"add", "."},
+		{"-c", "user.name=Deis CI", "-c", "user.email=ci@deis.com", "commit", "--quiet", "-m", fmt.Sprintf("Add the %s sample app", kind)},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return "", fmt.Errorf("git %s: %s: %s", strings.Join(args, " "), err, output)
+		}
+	}
+	return DefaultBanner, nil
+}
+
+// Repo writes an app of the given kind to a git repository of its own in settings.TestRoot. It
+// returns the path of the repository and the banner the app serves.
+func Repo(kind Kind) (string, string) {
+	path := filepath.Join(settings.TestRoot, string(kind))
+	banner, err := New(path, kind)
+	Expect(err).NotTo(HaveOccurred())
+	return path, banner
+}
+
+func render(path, text string, data templateData) error {
+	t, err := template.New(filepath.Base(path)).Parse(text)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if err := t.Execute(f, data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
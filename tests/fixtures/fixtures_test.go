@@ -0,0 +1,152 @@
+package fixtures
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/deis/workflow-e2e/tests/settings"
+)
+
+func TestNew(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fixtures-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, kind := range Kinds() {
+		repo := filepath.Join(dir, string(kind))
+		banner, err := New(repo, kind)
+		if err != nil {
+			t.Fatalf("%s: %s", kind, err)
+		}
+		if banner != DefaultBanner {
+			t.Errorf("%s: expected the banner %q, got %q", kind, DefaultBanner, banner)
+		}
+		if got := git(t, repo, "rev-parse", "--abbrev-ref", "HEAD"); got != "master" {
+			t.Errorf("%s: expected master to be checked out, got %s", kind, got)
+		}
+		if got := git(t, repo, "rev-list", "--count", "HEAD"); got != "1" {
+			t.Errorf("%s: expected a single commit, got %s", kind, got)
+		}
+		if got := git(t, repo, "status", "--porcelain"); got != "" {
+			t.Errorf("%s: expected every file to be committed, got:\n%s", kind, got)
+		}
+		files := strings.Split(git(t, repo, "ls-files"), "\n")
+		if len(files) != len(templates[kind]) {
+			t.Errorf("%s: expected %d files, got %v", kind, len(templates[kind]), files)
+		}
+	}
+
+	if _, err := New(filepath.Join(dir, string(Go)), Go); err == nil {
+		t.Error("expected an error writing an app over an existing directory")
+	}
+	if _, err := New(filepath.Join(dir, "cobol"), Kind("cobol")); err == nil {
+		t.Error("expected an error for an unknown kind of app")
+	}
+}
+
+func TestDockerfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fixtures-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(image string) { settings.FixtureBaseImage = image }(settings.FixtureBaseImage)
+
+	for _, test := range []struct {
+		kind      Kind
+		baseImage string
+		expected  []string
+		broken    bool
+	}{
+		{Dockerfile, "", []string{"FROM busybox\n", "EXPOSE 80\n"}, false},
+		{DockerfileProcfile, "registry.local:5000/library/busybox", []string{"FROM registry.local:5000/library/busybox\n"}, false},
+		{BrokenDockerfile, "", []string{"FROM busybox\n", "\nBOGUS command\n"}, true},
+	} {
+		settings.FixtureBaseImage = test.baseImage
+		repo := filepath.Join(dir, string(test.kind))
+		if _, err := New(repo, test.kind); err != nil {
+			t.Fatal(err)
+		}
+		contents, err := ioutil.ReadFile(filepath.Join(repo, "Dockerfile"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dockerfile := string(contents)
+		for _, s := range test.expected {
+			if !strings.Contains(dockerfile, s) {
+				t.Errorf("%s: expected the Dockerfile to contain %q:\n%s", test.kind, s, dockerfile)
+			}
+		}
+		if broken := strings.Contains(dockerfile, "BOGUS"); broken != test.broken {
+			t.Errorf("%s: expected the Dockerfile to be broken: %t:\n%s", test.kind, test.broken, dockerfile)
+		}
+	}
+}
+
+// TestGo checks that the Go app builds with its vendored dependency, in a $GOPATH as the buildpack
+// builds it, since Go is the one language of the sample apps that is sure to be installed.
+func TestGo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fixtures-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	repo := filepath.Join(dir, "src", "github.com", "deis", "example-go")
+	if _, err := New(repo, Go); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("go", "vet", ".")
+	cmd.Dir = repo
+	cmd.Env = append(os.Environ(), "GOPATH="+dir, "GO111MODULE=off")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("expected the Go app to build: %s: %s", err, output)
+	}
+}
+
+// TestComposerLock checks that the lock file of the PHP app is up to date with its composer.json,
+// which the buildpack would otherwise warn about.
+func TestComposerLock(t *testing.T) {
+	var composer struct {
+		Require map[string]string `json:"require"`
+	}
+	if err := json.Unmarshal([]byte(templates[PHP]["composer.json"]), &composer); err != nil {
+		t.Fatal(err)
+	}
+	var lock struct {
+		ContentHash string            `json:"content-hash"`
+		Platform    map[string]string `json:"platform"`
+	}
+	if err := json.Unmarshal([]byte(templates[PHP]["composer.lock"]), &lock); err != nil {
+		t.Fatal(err)
+	}
+	relevant, err := json.Marshal(map[string]interface{}{"require": composer.Require})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum := fmt.Sprintf("%x", md5.Sum(relevant)); lock.ContentHash != sum {
+		t.Errorf("expected the content-hash of %s to be %s, got %s", relevant, sum, lock.ContentHash)
+	}
+	if !reflect.DeepEqual(lock.Platform, composer.Require) {
+		t.Errorf("expected the lock file to require %v, got %v", composer.Require, lock.Platform)
+	}
+}
+
+func git(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %s: %s", strings.Join(args, " "), err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/deis/workflow-e2e/tests/cmd/auth"
 	"github.com/deis/workflow-e2e/tests/cmd/git"
 	"github.com/deis/workflow-e2e/tests/cmd/keys"
+	"github.com/deis/workflow-e2e/tests/cmd/ps"
+	"github.com/deis/workflow-e2e/tests/fixtures"
 	"github.com/deis/workflow-e2e/tests/model"
 	"github.com/deis/workflow-e2e/tests/settings"
 
@@ -37,14 +39,15 @@ var _ = Describe("git push deis master", func() {
 		Context("who has added their public key", func() {
 
 			BeforeEach(func() {
-				_, keyPath = keys.Add(user)
+				keyPath = keys.Add(user).Path
 			})
 
 			Context("and who has a local git repo containing buildpack source code", func() {
 
+				var dir, banner string
+
 				BeforeEach(func() {
-					output, err := cmd.Execute(`git clone https://github.com/deis/example-go.git`)
-					Expect(err).NotTo(HaveOccurred(), output)
+					dir, banner = fixtures.Repo(fixtures.Go)
 				})
 
 				Context("and has run `deis apps:create` from within that repo", func() {
@@ -52,7 +55,7 @@ var _ = Describe("git push deis master", func() {
 					var app model.App
 
 					BeforeEach(func() {
-						os.Chdir("example-go")
+						os.Chdir(dir)
 						app = apps.Create(user)
 					})
 
@@ -61,7 +64,7 @@ var _ = Describe("git push deis master", func() {
 					})
 
 					Specify("that user can deploy that app using a git push", func() {
-						git.Push(user, keyPath, app, "Powered by Deis")
+						git.Push(user, keyPath, app, banner)
 					})
 
 					Specify("that user can interrupt the deploy of the app and recover", func() {
@@ -82,14 +85,14 @@ var _ = Describe("git push deis master", func() {
 						sess2 := git.StartPush(user, keyPath)
 						Eventually(sess2.Err).Should(Say("fatal: remote error: Another git push is ongoing"))
 						Eventually(sess2).Should(Exit(128))
-						git.PushUntilResult(user, keyPath,
-							model.CmdResult{
-								Out:      nil,
-								Err:      []byte("Everything up-to-date"),
-								ExitCode: 0,
-							})
+						// once the first push has finished, the lock must be released
+						git.PushUntilResult(user, keyPath, model.AllOf(
+							model.Not(model.ErrContains("Another git push is ongoing")),
+							model.ErrContains("Everything up-to-date"),
+							model.ExitCodeIn(0),
+						))
 						Eventually(sess, settings.MaxEventuallyTimeout).Should(Exit(0))
-						git.Curl(app, "Powered by Deis")
+						git.Curl(app, banner)
 					})
 
 					Context("with a bad buildpack", func() {
@@ -120,10 +123,10 @@ var _ = Describe("git push deis master", func() {
 
 					Context("and who has another local git repo containing buildpack source code", func() {
 
+						var dir2, banner2 string
+
 						BeforeEach(func() {
-							os.Chdir("..")
-							output, err := cmd.Execute(`git clone https://github.com/deis/example-nodejs-express.git`)
-							Expect(err).NotTo(HaveOccurred(), output)
+							dir2, banner2 = fixtures.Repo(fixtures.NodeJS)
 						})
 
 						Context("and has run `deis apps:create` from within that repo", func() {
@@ -131,7 +134,7 @@ var _ = Describe("git push deis master", func() {
 							var app2 model.App
 
 							BeforeEach(func() {
-								os.Chdir("example-nodejs-express")
+								os.Chdir(dir2)
 								app2 = apps.Create(user)
 							})
 
@@ -140,14 +143,14 @@ var _ = Describe("git push deis master", func() {
 							})
 
 							Specify("that user can deploy both apps concurrently", func() {
-								os.Chdir(filepath.Join("..", "example-go"))
+								os.Chdir(dir)
 								sess := git.StartPush(user, keyPath)
-								os.Chdir(filepath.Join("..", "example-nodejs-express"))
+								os.Chdir(dir2)
 								sess2 := git.StartPush(user, keyPath)
 								Eventually(sess, settings.MaxEventuallyTimeout).Should(Exit(0))
 								Eventually(sess2, settings.MaxEventuallyTimeout).Should(Exit(0))
-								git.Curl(app, "Powered by Deis")
-								git.Curl(app2, "Powered by Deis")
+								git.Curl(app, banner)
+								git.Curl(app2, banner2)
 							})
 
 						})
@@ -155,7 +158,7 @@ var _ = Describe("git push deis master", func() {
 					})
 
 					Specify("and can execute deis run successfully", func() {
-						git.Push(user, keyPath, app, "Powered by Deis")
+						git.Push(user, keyPath, app, banner)
 						sess, err := cmd.Start("deis run env -a %s", &user, app.Name)
 						Expect(err).NotTo(HaveOccurred())
 						Eventually(sess, settings.MaxEventuallyTimeout).Should(Exit(0))
@@ -167,9 +170,10 @@ var _ = Describe("git push deis master", func() {
 
 			Context("and who has a local git repo containing dockerfile source code", func() {
 
+				var dir, banner string
+
 				BeforeEach(func() {
-					output, err := cmd.Execute(`git clone https://github.com/deis/example-dockerfile-http.git`)
-					Expect(err).NotTo(HaveOccurred(), output)
+					dir, banner = fixtures.Repo(fixtures.Dockerfile)
 				})
 
 				Context("and has run `deis apps:create` from within that repo", func() {
@@ -177,7 +181,7 @@ var _ = Describe("git push deis master", func() {
 					var app model.App
 
 					BeforeEach(func() {
-						os.Chdir("example-dockerfile-http")
+						os.Chdir(dir)
 						app = apps.Create(user)
 					})
 
@@ -186,7 +190,7 @@ var _ = Describe("git push deis master", func() {
 					})
 
 					Specify("that user can deploy that app using a git push", func() {
-						git.Push(user, keyPath, app, "Powered by Deis")
+						git.Push(user, keyPath, app, banner)
 					})
 
 					Specify("that user can deploy that app using a git push after setting config values", func() {
@@ -210,21 +214,22 @@ var _ = Describe("git push deis master", func() {
 						sess2 := git.StartPush(user, keyPath)
 						Eventually(sess2.Err).Should(Say("fatal: remote error: Another git push is ongoing"))
 						Eventually(sess2).Should(Exit(128))
-						git.PushUntilResult(user, keyPath,
-							model.CmdResult{
-								Out:      nil,
-								Err:      []byte("Everything up-to-date"),
-								ExitCode: 0,
-							})
+						// once the first push has finished, the lock must be released
+						git.PushUntilResult(user, keyPath, model.AllOf(
+							model.Not(model.ErrContains("Another git push is ongoing")),
+							model.ErrContains("Everything up-to-date"),
+							model.ExitCodeIn(0),
+						))
 						Eventually(sess, settings.MaxEventuallyTimeout).Should(Exit(0))
-						git.Curl(app, "Powered by Deis")
+						git.Curl(app, banner)
 					})
 
 					Context("with a bad Dockerfile", func() {
 
 						BeforeEach(func() {
-							badCommit := `echo "BOGUS command" >> Dockerfile && EMAIL="ci@deis.com" git commit Dockerfile -m "Added a bogus command"`
-							output, err := cmd.Execute(badCommit)
+							broken, _ := fixtures.Repo(fixtures.BrokenDockerfile)
+							output, err := cmd.Execute(`cp %s Dockerfile && EMAIL="ci@deis.com" git commit Dockerfile -m "Added a bogus command"`,
+								cmd.Quote(filepath.Join(broken, "Dockerfile")))
 							Expect(err).NotTo(HaveOccurred(), output)
 						})
 
@@ -245,10 +250,10 @@ var _ = Describe("git push deis master", func() {
 
 					Context("and who has another local git repo containing dockerfile source code", func() {
 
+						var dir2, banner2 string
+
 						BeforeEach(func() {
-							os.Chdir("..")
-							output, err := cmd.Execute(`git clone https://github.com/deis/example-dockerfile-procfile-http.git`)
-							Expect(err).NotTo(HaveOccurred(), output)
+							dir2, banner2 = fixtures.Repo(fixtures.DockerfileProcfile)
 						})
 
 						Context("and has run `deis apps:create` from within that repo", func() {
@@ -256,7 +261,7 @@ var _ = Describe("git push deis master", func() {
 							var app2 model.App
 
 							BeforeEach(func() {
-								os.Chdir("example-dockerfile-procfile-http")
+								os.Chdir(dir2)
 								app2 = apps.Create(user)
 							})
 
@@ -265,15 +270,15 @@ var _ = Describe("git push deis master", func() {
 							})
 
 							Specify("that user can deploy both apps concurrently", func() {
-								os.Chdir(filepath.Join("..", "example-dockerfile-http"))
+								os.Chdir(dir)
 								sess := git.StartPush(user, keyPath)
-								os.Chdir(filepath.Join("..", "example-dockerfile-procfile-http"))
+								os.Chdir(dir2)
 								sess2 := git.StartPush(user, keyPath)
 								Eventually(sess, settings.MaxEventuallyTimeout).Should(Exit(0))
 								Eventually(sess2, settings.MaxEventuallyTimeout).Should(Exit(0))
-								git.Curl(app, "Powered by Deis")
-								git.Curl(app2, "Powered by Deis")
-								_ = listProcs(user, app2, "web")
+								git.Curl(app, banner)
+								git.Curl(app2, banner2)
+								Expect(ps.OfType(ps.List(user, app2), "web")).NotTo(BeEmpty())
 							})
 
 						})
@@ -281,7 +286,7 @@ var _ = Describe("git push deis master", func() {
 					})
 
 					Specify("and can execute deis run successfully", func() {
-						git.Push(user, keyPath, app, "Powered by Deis")
+						git.Push(user, keyPath, app, banner)
 						sess, err := cmd.Start("deis run env -a %s", &user, app.Name)
 						Expect(err).NotTo(HaveOccurred())
 						Eventually(sess, settings.MaxEventuallyTimeout).Should(Exit(0))
@@ -5,8 +5,8 @@ import (
 	"github.com/deis/workflow-e2e/tests/cmd/apps"
 	"github.com/deis/workflow-e2e/tests/cmd/auth"
 	"github.com/deis/workflow-e2e/tests/cmd/builds"
+	"github.com/deis/workflow-e2e/tests/cmd/healthchecks"
 	"github.com/deis/workflow-e2e/tests/model"
-	"github.com/deis/workflow-e2e/tests/settings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
@@ -49,121 +49,85 @@ var _ = Describe("deis healthchecks", func() {
 				Eventually(sess).Should(Exit(0))
 			})
 
-			Specify("that user can set an exec liveness healthcheck", func() {
-				sess, err := cmd.Start("deis healthchecks:set -a %s liveness exec -- /bin/true", &user, app.Name)
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Say("Applying livenessProbe healthcheck..."))
-				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Healthchecks", app.Name))
-				Eventually(sess).Should(Say(`Exec Probe\: Command=\[/bin/true]`))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
-
-				sess, err = cmd.Start("deis healthchecks:list -a %s", &user, app.Name)
-				Eventually(sess).Should(Say("=== %s Healthchecks", app.Name))
-				Eventually(sess).Should(Say(`Exec Probe\: Command=\[/bin/true]`))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
-			})
-
 			// 1500 is the port of the app we are deploying deis/example-dockerfile-http
-			Specify("that user can set a httpGet liveness healthcheck", func() {
-				sess, err := cmd.Start("deis healthchecks:set liveness httpGet -a %s 1500", &user, app.Name)
-				Eventually(sess).Should(Say("Applying livenessProbe healthcheck..."))
-				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Healthchecks", app.Name))
-				Eventually(sess).Should(Say(`HTTP GET Probe\: Path="/" Port=1500 HTTPHeaders=\[]`))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
-
-				sess, err = cmd.Start("deis healthchecks:list -a %s", &user, app.Name)
-				Eventually(sess).Should(Say("=== %s Healthchecks", app.Name))
-				Eventually(sess).Should(Say(`HTTP GET Probe\: Path="/" Port=1500 HTTPHeaders=\[]`))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
-			})
-
-			Specify("that user can set a tcpSocket liveness healthcheck", func() {
-				sess, err := cmd.Start("deis healthchecks:set liveness tcpSocket -a %s 1500", &user, app.Name)
-				Eventually(sess).Should(Say("Applying livenessProbe healthcheck..."))
-				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Healthchecks", app.Name))
-				Eventually(sess).Should(Say(`TCP Socket Probe\: Port=1500`))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
-
-				sess, err = cmd.Start("deis healthchecks:list -a %s", &user, app.Name)
-				Eventually(sess).Should(Say("=== %s Healthchecks", app.Name))
-				Eventually(sess).Should(Say(`TCP Socket Probe\: Port=1500`))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
-			})
+			DescribeTable("that user can set a healthcheck",
+				func(healthType healthchecks.HealthType, probe healthchecks.Probe) {
+					hc := healthchecks.Healthcheck{Type: healthType, Probe: probe}
+					for _, hcs := range [][]healthchecks.Healthcheck{
+						healthchecks.Set(user, app, hc),
+						healthchecks.List(user, app),
+					} {
+						actual, ok := healthchecks.Find(hcs, healthType, "")
+						Expect(ok).To(BeTrue(), "no %s healthcheck listed", healthType)
+						Expect(actual.Probe).To(Equal(probe))
+						Expect(actual.Timing).To(Equal(healthchecks.DefaultTiming))
+					}
+				},
+				Entry("exec liveness", healthchecks.Liveness,
+					healthchecks.ExecProbe{Command: []string{"/bin/true"}}),
+				Entry("httpGet liveness", healthchecks.Liveness,
+					healthchecks.HTTPGetProbe{Path: "/", Port: 1500}),
+				Entry("tcpSocket liveness", healthchecks.Liveness,
+					healthchecks.TCPSocketProbe{Port: 1500}),
+				Entry("exec readiness", healthchecks.Readiness,
+					healthchecks.ExecProbe{Command: []string{"/bin/true"}}),
+				Entry("httpGet readiness", healthchecks.Readiness,
+					healthchecks.HTTPGetProbe{Path: "/", Port: 1500}),
+				Entry("tcpSocket readiness", healthchecks.Readiness,
+					healthchecks.TCPSocketProbe{Port: 1500}),
+			)
+
+			DescribeTable("that user can set healthcheck options",
+				func(hc healthchecks.Healthcheck) {
+					healthchecks.Set(user, app, hc)
+					actual, ok := healthchecks.Find(healthchecks.List(user, app), hc.Type, hc.ProcType)
+					Expect(ok).To(BeTrue(), "no %s healthcheck listed", hc.Type)
+					Expect(actual.Probe).To(Equal(hc.Probe))
+					Expect(actual.Timing).To(Equal(hc.Timing.WithDefaults()))
+				},
+				Entry("a httpGet path", healthchecks.Healthcheck{
+					Type:  healthchecks.Liveness,
+					Probe: healthchecks.HTTPGetProbe{Path: "/healthz", Port: 1500},
+				}),
+				Entry("httpGet headers", healthchecks.Healthcheck{
+					Type: healthchecks.Readiness,
+					Probe: healthchecks.HTTPGetProbe{Path: "/", Port: 1500, Headers: []healthchecks.Header{
+						{Name: "X-Client-Version", Value: "v1.0"},
+					}},
+				}),
+				Entry("an initial delay and timeout", healthchecks.Healthcheck{
+					Type:   healthchecks.Liveness,
+					Probe:  healthchecks.TCPSocketProbe{Port: 1500},
+					Timing: healthchecks.Timing{InitialDelay: 5, Timeout: 2},
+				}),
+				Entry("a period and thresholds", healthchecks.Healthcheck{
+					Type:   healthchecks.Readiness,
+					Probe:  healthchecks.TCPSocketProbe{Port: 1500},
+					Timing: healthchecks.Timing{Period: 3, SuccessThreshold: 1, FailureThreshold: 5},
+				}),
+				Entry("a process type", healthchecks.Healthcheck{
+					Type:     healthchecks.Liveness,
+					ProcType: "cmd",
+					Probe:    healthchecks.ExecProbe{Command: []string{"/bin/true"}},
+				}),
+			)
 
-			Specify("that user can set an exec readiness healthcheck", func() {
-				sess, err := cmd.Start("deis healthchecks:set readiness exec -a %s -- /bin/true", &user, app.Name)
-				Eventually(sess).Should(Say("Applying readinessProbe healthcheck..."))
-				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Healthchecks", app.Name))
-				Eventually(sess).Should(Say(`Exec Probe\: Command=\[/bin/true]`))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
-
-				sess, err = cmd.Start("deis healthchecks:list -a %s", &user, app.Name)
-				Eventually(sess).Should(Say("=== %s Healthchecks", app.Name))
-				Eventually(sess).Should(Say(`Exec Probe\: Command=\[/bin/true]`))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
-			})
-
-			Specify("that user can set a httpGet readiness healthcheck", func() {
-				sess, err := cmd.Start("deis healthchecks:set readiness httpGet -a %s 1500", &user, app.Name)
-				Eventually(sess).Should(Say("Applying readinessProbe healthcheck..."))
-				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Healthchecks", app.Name))
-				Eventually(sess).Should(Say(`HTTP GET Probe\: Path="/" Port=1500 HTTPHeaders=\[]`))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
-
-				sess, err = cmd.Start("deis healthchecks:list -a %s", &user, app.Name)
-				Eventually(sess).Should(Say("=== %s Healthchecks", app.Name))
-				Eventually(sess).Should(Say(`HTTP GET Probe\: Path="/" Port=1500 HTTPHeaders=\[]`))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
-			})
-
-			Specify("that user can set a tcpSocket readiness healthcheck", func() {
-				sess, err := cmd.Start("deis healthchecks:set readiness tcpSocket -a %s 1500", &user, app.Name)
-				Eventually(sess).Should(Say("Applying readinessProbe healthcheck..."))
-				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Healthchecks", app.Name))
-				Eventually(sess).Should(Say(`TCP Socket Probe\: Port=1500`))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
+			Context("and already has a healthcheck set", func() {
 
-				sess, err = cmd.Start("deis healthchecks:list -a %s", &user, app.Name)
-				Eventually(sess).Should(Say("=== %s Healthchecks", app.Name))
-				Eventually(sess).Should(Say(`TCP Socket Probe\: Port=1500`))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
-			})
+				probe := healthchecks.ExecProbe{Command: []string{"/bin/true"}}
 
-			Context("and already has a healthcheck set", func() {
 				BeforeEach(func() {
-					sess, err := cmd.Start(`deis healthchecks:set readiness exec -a %s -- /bin/true`, &user, app.Name)
-					Eventually(sess).Should(Say("Applying readinessProbe healthcheck..."))
-					Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Healthchecks", app.Name))
-					Eventually(sess).Should(Say(`Exec Probe\: Command=\[/bin/true]`))
-					Expect(err).NotTo(HaveOccurred())
-					Eventually(sess).Should(Exit(0))
+					healthchecks.Set(user, app, healthchecks.Healthcheck{Type: healthchecks.Readiness, Probe: probe})
 				})
 
 				Specify("that user can unset that healthcheck", func() {
-					sess, err := cmd.Start("deis healthchecks:unset -a %s readiness", &user, app.Name)
-					Eventually(sess).Should(Say("Removing healthchecks..."))
-					Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Healthchecks", app.Name))
-					Eventually(sess).ShouldNot(Say(`Exec Probe\: Command=\[/bin/true]`))
-					Expect(err).NotTo(HaveOccurred())
-					Eventually(sess).Should(Exit(0))
-
-					sess, err = cmd.Start("deis healthchecks:list -a %s", &user, app.Name)
-					Eventually(sess).Should(Say("=== %s Healthchecks", app.Name))
-					Eventually(sess).ShouldNot(Say(`Exec Probe\: Command=\[/bin/true]`))
-					Expect(err).NotTo(HaveOccurred())
-					Eventually(sess).Should(Exit(0))
+					for _, hcs := range [][]healthchecks.Healthcheck{
+						healthchecks.Unset(user, app, "", healthchecks.Readiness),
+						healthchecks.List(user, app),
+					} {
+						_, ok := healthchecks.Find(hcs, healthchecks.Readiness, "")
+						Expect(ok).To(BeFalse())
+					}
 				})
 			})
 		})
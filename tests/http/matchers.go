@@ -0,0 +1,104 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/onsi/gomega/format"
+	"github.com/onsi/gomega/types"
+)
+
+// RespondWith succeeds if the response has the given status code.
+func RespondWith(code int) types.GomegaMatcher {
+	return &responseMatcher{
+		description: fmt.Sprintf("to respond with status %d", code),
+		match: func(r Response) bool {
+			return r.StatusCode == code
+		},
+	}
+}
+
+// HaveHeader succeeds if the response has the named header and, if a value is given, one of its
+// values equals it.
+func HaveHeader(name string, value ...string) types.GomegaMatcher {
+	description := fmt.Sprintf("to have header %q", name)
+	if len(value) > 0 {
+		description = fmt.Sprintf("to have header %q with value %q", name, value[0])
+	}
+	return &responseMatcher{
+		description: description,
+		match: func(r Response) bool {
+			values := r.Header[http.CanonicalHeaderKey(name)]
+			if len(value) == 0 {
+				return len(values) > 0
+			}
+			for _, v := range values {
+				if v == value[0] {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// HaveBodyContaining succeeds if the response body contains substr.
+func HaveBodyContaining(substr string) types.GomegaMatcher {
+	return &responseMatcher{
+		description: fmt.Sprintf("to have a body containing %q", substr),
+		match: func(r Response) bool {
+			return strings.Contains(string(r.Body), substr)
+		},
+	}
+}
+
+type responseMatcher struct {
+	description string
+	match       func(Response) bool
+}
+
+func (m *responseMatcher) Match(actual interface{}) (bool, error) {
+	r, err := toResponse(actual)
+	if err != nil {
+		return false, err
+	}
+	if r.Err != nil {
+		return false, nil
+	}
+	return m.match(r), nil
+}
+
+func (m *responseMatcher) FailureMessage(actual interface{}) string {
+	return format.Message(describe(actual), m.description)
+}
+
+func (m *responseMatcher) NegatedFailureMessage(actual interface{}) string {
+	return format.Message(describe(actual), "not "+m.description)
+}
+
+func toResponse(actual interface{}) (Response, error) {
+	switch r := actual.(type) {
+	case Response:
+		return r, nil
+	case *Response:
+		if r == nil {
+			return Response{}, fmt.Errorf("expected a Response, got nil")
+		}
+		return *r, nil
+	}
+	return Response{}, fmt.Errorf("expected a Response, got\n%s", format.Object(actual, 1))
+}
+
+// describe summarizes a response for a failure message without dumping its TLS state.
+func describe(actual interface{}) string {
+	r, err := toResponse(actual)
+	if err != nil {
+		return fmt.Sprintf("%v", actual)
+	}
+	body := string(r.Body)
+	if len(body) > 512 {
+		body = body[:512] + "..."
+	}
+	return fmt.Sprintf("%s\nheaders: %v\nbody: %s", r, r.Header, body)
+}
@@ -0,0 +1,170 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/deis/workflow-e2e/tests/resolver"
+	"github.com/deis/workflow-e2e/tests/retry"
+
+	"github.com/onsi/gomega"
+	"github.com/onsi/gomega/types"
+)
+
+// The code in this package probes deployed apps over HTTP from within the test process, so that
+// specs can assert on status codes, headers, bodies, redirects, TLS and latency instead of on the
+// output of curl.
+
+// backoff is quicker than the default, since requests to the router are cheap.
+var backoff = retry.Backoff{Initial: 250 * time.Millisecond, Max: 5 * time.Second, Factor: 2, Jitter: 0.2}
+
+// Probe describes a request to make against an app.
+type Probe struct {
+	// URL is the URL to request, usually model.App.URL.
+	URL string
+	// Method defaults to GET.
+	Method string
+	// Host overrides the Host header, e.g. to reach an app through one of its custom domains while
+	// connecting to its default URL. It is also used for SNI unless ServerName is set.
+	Host string
+	// Header holds any additional request headers.
+	Header http.Header
+	// ServerName overrides the name sent for SNI during the TLS handshake.
+	ServerName string
+	// InsecureSkipVerify disables verification of the server's certificate.
+	InsecureSkipVerify bool
+	// FollowRedirects makes the probe follow redirects, recording each hop in Response.Redirects.
+	// By default the redirect itself is returned.
+	FollowRedirects bool
+	// Timeout bounds each attempt; it defaults to 30 seconds.
+	Timeout time.Duration
+}
+
+// Response is the outcome of a single attempt of a Probe.
+type Response struct {
+	// Host is the host the final request was made for, which is Probe.Host if that was set.
+	Host       string
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// Duration is how long the attempt took, including any redirects that were followed.
+	Duration time.Duration
+	// TLS describes the connection the final response was received over, if it used TLS.
+	TLS *tls.ConnectionState
+	// Redirects lists the URLs redirected to, in order.
+	Redirects []string
+	// Err is set if no response was received at all.
+	Err error
+}
+
+func (r Response) String() string {
+	if r.Err != nil {
+		return fmt.Sprintf("error after %s: %s", r.Duration, r.Err)
+	}
+	return fmt.Sprintf("%d after %s", r.StatusCode, r.Duration)
+}
+
+// Do makes a single attempt of the probe.
+func (p Probe) Do() (resp Response) {
+	start := time.Now()
+	defer func() { resp.Duration = time.Since(start) }()
+
+	method := p.Method
+	if method == "" {
+		method = "GET"
+	}
+	req, err := http.NewRequest(method, p.URL, nil)
+	if err != nil {
+		resp.Err = err
+		return resp
+	}
+	for name, values := range p.Header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	if p.Host != "" {
+		req.Host = p.Host
+	}
+
+	httpResp, err := p.client(&resp).Do(req)
+	if err != nil {
+		resp.Err = err
+		return resp
+	}
+	defer httpResp.Body.Close()
+	resp.Host = httpResp.Request.Host
+	if resp.Host == "" {
+		resp.Host = httpResp.Request.URL.Host
+	}
+	resp.StatusCode = httpResp.StatusCode
+	resp.Header = httpResp.Header
+	resp.TLS = httpResp.TLS
+	resp.Body, resp.Err = ioutil.ReadAll(httpResp.Body)
+	return resp
+}
+
+// Until repeats the probe, backing off between attempts, until a response satisfies every one
+// of the matchers, and returns that response. If the timeout elapses first, the spec fails with
+// the last response.
+func (p Probe) Until(timeout time.Duration, matchers ...types.GomegaMatcher) Response {
+	matcher := gomega.SatisfyAll(matchers...)
+	var resp Response
+	retry.Until(context.Background(), retry.Options{
+		Description: fmt.Sprintf("%s to respond as expected", p),
+		Timeout:     timeout,
+		Backoff:     backoff,
+	}, func(ctx context.Context) (string, bool, error) {
+		resp = p.Do()
+		ok, _ := matcher.Match(resp)
+		return resp.String(), ok, nil
+	})
+	gomega.ExpectWithOffset(1, resp).To(matcher, "%s did not respond as expected within %s", p, timeout)
+	return resp
+}
+
+func (p Probe) String() string {
+	method := p.Method
+	if method == "" {
+		method = "GET"
+	}
+	if p.Host != "" {
+		return fmt.Sprintf("%s %s (Host: %s)", method, p.URL, p.Host)
+	}
+	return fmt.Sprintf("%s %s", method, p.URL)
+}
+
+// client returns an HTTP client for the probe that records redirects in resp.
+func (p Probe) client(resp *Response) *http.Client {
+	transport := resolver.Transport()
+	serverName := p.ServerName
+	if serverName == "" && p.Host != "" {
+		serverName = strings.Split(p.Host, ":")[0]
+	}
+	transport.TLSClientConfig = &tls.Config{ServerName: serverName, InsecureSkipVerify: p.InsecureSkipVerify}
+	transport.DisableKeepAlives = true
+
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if !p.FollowRedirects {
+				return http.ErrUseLastResponse
+			}
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after %d redirects", len(via))
+			}
+			resp.Redirects = append(resp.Redirects, req.URL.String())
+			return nil
+		},
+	}
+}
@@ -0,0 +1,222 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+)
+
+func TestProbe(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Method", r.Method)
+		fmt.Fprintf(w, "Host: %s\nX-Test: %s\n", r.Host, r.Header.Get("X-Test"))
+	}))
+	defer s.Close()
+	serverHost := strings.TrimPrefix(s.URL, "http://")
+
+	tests := []struct {
+		probe  Probe
+		host   string
+		method string
+		body   string
+	}{
+		{Probe{URL: s.URL}, serverHost, "GET", "Host: " + serverHost + "\nX-Test: \n"},
+		{
+			Probe{URL: s.URL, Method: "POST", Host: "my-app.example.com", Header: http.Header{"X-Test": {"yes"}}},
+			"my-app.example.com", "POST", "Host: my-app.example.com\nX-Test: yes\n",
+		},
+	}
+	for _, test := range tests {
+		resp := test.probe.Do()
+		if resp.Err != nil {
+			t.Fatalf("%s: %s", test.probe, resp.Err)
+		}
+		if resp.StatusCode != http.StatusOK || resp.Host != test.host || resp.Header.Get("X-Method") != test.method || string(resp.Body) != test.body {
+			t.Errorf("%s: expected a %s for %s with body %q, got %s for %s with %v and body %q", test.probe,
+				test.method, test.host, test.body, resp, resp.Host, resp.Header, resp.Body)
+		}
+		if resp.TLS != nil || resp.Duration <= 0 {
+			t.Errorf("%s: expected a timed response without TLS, got %s (TLS %v)", test.probe, resp, resp.TLS)
+		}
+	}
+
+	if got := (Probe{URL: s.URL, Host: "my-app.example.com"}).String(); got != "GET "+s.URL+" (Host: my-app.example.com)" {
+		t.Errorf("expected the probe to describe the Host it asks for, got %q", got)
+	}
+}
+
+func TestProbeFollowRedirects(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			http.Redirect(w, r, "/one", http.StatusFound)
+		case "/one":
+			http.Redirect(w, r, "/two", http.StatusMovedPermanently)
+		case "/two":
+			fmt.Fprintf(w, "arrived at %s", r.Host)
+		default:
+			http.Redirect(w, r, r.URL.Path, http.StatusFound)
+		}
+	}))
+	defer s.Close()
+
+	// By default the redirect itself is the response.
+	resp := Probe{URL: s.URL + "/"}.Do()
+	if resp.Err != nil || resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "/one" || len(resp.Redirects) != 0 {
+		t.Errorf("expected a redirect to /one, got %s to %q after %v", resp, resp.Header.Get("Location"), resp.Redirects)
+	}
+
+	resp = Probe{URL: s.URL + "/", FollowRedirects: true, Host: "my-app.example.com"}.Do()
+	if resp.Err != nil || resp.StatusCode != http.StatusOK || string(resp.Body) != "arrived at my-app.example.com" {
+		t.Errorf("expected to arrive at /two with the Host kept, got %s with body %q", resp, resp.Body)
+	}
+	if expected := []string{s.URL + "/one", s.URL + "/two"}; fmt.Sprint(resp.Redirects) != fmt.Sprint(expected) {
+		t.Errorf("expected redirects %v, got %v", expected, resp.Redirects)
+	}
+
+	resp = Probe{URL: s.URL + "/loop", FollowRedirects: true}.Do()
+	// The tenth redirect is the one that is not followed.
+	if resp.Err == nil || !strings.Contains(resp.Err.Error(), "stopped after 10 redirects") || len(resp.Redirects) != 9 {
+		t.Errorf("expected to give up on a redirect loop, got %s after %d redirects", resp, len(resp.Redirects))
+	}
+}
+
+func TestProbeError(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := s.URL
+	s.Close()
+
+	resp := Probe{URL: url, Timeout: time.Second}.Do()
+	if resp.Err == nil || !strings.HasPrefix(resp.String(), "error after ") {
+		t.Fatalf("expected a request to a closed server to fail, got %s", resp)
+	}
+	// No matcher matches a response that never arrived, not even a negated one.
+	for _, matcher := range []interface {
+		Match(interface{}) (bool, error)
+	}{RespondWith(0), HaveHeader("X-Missing"), HaveBodyContaining("")} {
+		if match(matcher, resp) {
+			t.Errorf("expected %#v not to match a failed request", matcher)
+		}
+	}
+}
+
+func TestProbeUntil(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	var mu sync.Mutex
+	requests := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "Powered by Deis")
+	}))
+	defer s.Close()
+
+	resp := Probe{URL: s.URL}.Until(10*time.Second, RespondWith(http.StatusOK), HaveBodyContaining("Powered by"))
+	mu.Lock()
+	if resp.StatusCode != http.StatusOK || requests != 3 {
+		t.Errorf("expected the third request to succeed, got %s on request %d", resp, requests)
+	}
+	mu.Unlock()
+
+	failures := gomega.InterceptGomegaFailures(func() {
+		Probe{URL: s.URL}.Until(time.Second, HaveBodyContaining("Powered by Heroku"))
+	})
+	if len(failures) != 1 {
+		t.Fatalf("expected the probe to fail once, got %q", failures)
+	}
+	for _, expected := range []string{
+		fmt.Sprintf("GET %s did not respond as expected within 1s", s.URL),
+		"body: Powered by Deis",
+		`to have a body containing "Powered by Heroku"`,
+	} {
+		if !strings.Contains(failures[0], expected) {
+			t.Errorf("expected the failure to contain %q, got %q", expected, failures[0])
+		}
+	}
+}
+
+func TestMatchers(t *testing.T) {
+	resp := Response{
+		StatusCode: http.StatusServiceUnavailable,
+		Header:     http.Header{"Content-Type": {"text/plain"}, "X-Powered-By": {"Deis", "Go"}},
+		Body:       []byte("Service Unavailable"),
+		Duration:   1500 * time.Millisecond,
+	}
+	tests := []struct {
+		matcher interface {
+			Match(interface{}) (bool, error)
+		}
+		expected bool
+	}{
+		{RespondWith(http.StatusServiceUnavailable), true},
+		{RespondWith(http.StatusOK), false},
+		{HaveHeader("x-powered-by"), true},
+		{HaveHeader("X-Powered-By", "Go"), true},
+		{HaveHeader("X-Powered-By", "Heroku"), false},
+		{HaveHeader("Location"), false},
+		{HaveBodyContaining("Unavailable"), true},
+		{HaveBodyContaining(""), true},
+		{HaveBodyContaining("unavailable"), false},
+	}
+	for _, test := range tests {
+		if got := match(test.matcher, resp); got != test.expected {
+			t.Errorf("%#v: expected %t, got %t", test.matcher, test.expected, got)
+		}
+		if got := match(test.matcher, &resp); got != test.expected {
+			t.Errorf("%#v with a *Response: expected %t, got %t", test.matcher, test.expected, got)
+		}
+	}
+
+	for _, actual := range []interface{}{"503", (*Response)(nil), nil} {
+		if _, err := RespondWith(http.StatusOK).Match(actual); err == nil {
+			t.Errorf("expected matching %#v to fail", actual)
+		}
+	}
+}
+
+func TestMatcherFailureMessages(t *testing.T) {
+	resp := Response{
+		StatusCode: http.StatusServiceUnavailable,
+		Header:     http.Header{"X-Powered-By": {"Deis"}},
+		Body:       []byte(strings.Repeat("x", 600)),
+		Duration:   1500 * time.Millisecond,
+	}
+	// The response is described by its status, headers and as much of its body as is useful.
+	described := []string{"503 after 1.5s\n", "headers: map[X-Powered-By:[Deis]]\n", "body: " + strings.Repeat("x", 512) + "...\n"}
+
+	tests := []struct {
+		message  string
+		expected string
+	}{
+		{RespondWith(http.StatusOK).FailureMessage(resp), "to respond with status 200"},
+		{RespondWith(http.StatusServiceUnavailable).NegatedFailureMessage(resp), "not to respond with status 503"},
+		{HaveHeader("Location").FailureMessage(resp), `to have header "Location"`},
+		{HaveHeader("X-Powered-By", "Go").FailureMessage(resp), `to have header "X-Powered-By" with value "Go"`},
+		{HaveBodyContaining("Deis").FailureMessage(resp), `to have a body containing "Deis"`},
+		{HaveBodyContaining("x").NegatedFailureMessage(&resp), `not to have a body containing "x"`},
+	}
+	for _, test := range tests {
+		for _, expected := range append(described, test.expected) {
+			if !strings.Contains(test.message, expected) {
+				t.Errorf("expected the message to contain %q, got %q", expected, test.message)
+			}
+		}
+	}
+
+	// Responses that never arrived are described by their error.
+	message := RespondWith(http.StatusOK).FailureMessage(Response{Err: fmt.Errorf("connection refused"), Duration: time.Second})
+	if !strings.Contains(message, "error after 1s: connection refused") {
+		t.Errorf("expected the message to give the error, got %q", message)
+	}
+}
@@ -0,0 +1,266 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"time"
+
+	"github.com/deis/workflow-e2e/tests/model"
+	"github.com/deis/workflow-e2e/tests/pki"
+	"github.com/deis/workflow-e2e/tests/resolver"
+	"github.com/deis/workflow-e2e/tests/retry"
+
+	"github.com/onsi/gomega"
+	"github.com/onsi/gomega/format"
+	"github.com/onsi/gomega/types"
+)
+
+// TLSProbe describes a TLS handshake with the router, made to find out which certificate it
+// presents for a domain. Nothing is sent once the handshake is done, so the app behind the domain
+// does not need to be serving.
+type TLSProbe struct {
+	// URL is the URL of the host to connect to, usually model.App.URL. Unless its scheme is https,
+	// port 443 is used, since that is where the router terminates TLS.
+	URL string
+	// ServerName is the name sent for SNI, usually a domain a cert is attached to.
+	ServerName string
+	// Timeout bounds each attempt; it defaults to 30 seconds.
+	Timeout time.Duration
+}
+
+// Handshake is the outcome of a single attempt of a TLSProbe.
+type Handshake struct {
+	// PeerCertificates is the chain the server presented, leaf first.
+	PeerCertificates []*x509.Certificate
+	// Duration is how long the attempt took.
+	Duration time.Duration
+	// Err is set if the handshake did not complete.
+	Err error
+}
+
+// Leaf returns the certificate the server presented for itself, or nil if it presented none.
+func (h Handshake) Leaf() *x509.Certificate {
+	if len(h.PeerCertificates) == 0 {
+		return nil
+	}
+	return h.PeerCertificates[0]
+}
+
+func (h Handshake) String() string {
+	if h.Err != nil {
+		return fmt.Sprintf("error after %s: %s", h.Duration, h.Err)
+	}
+	if leaf := h.Leaf(); leaf != nil {
+		return fmt.Sprintf("presented %q (SANs %v, fingerprint %s) after %s",
+			leaf.Subject.CommonName, leaf.DNSNames, pki.Fingerprint(leaf), h.Duration)
+	}
+	return fmt.Sprintf("presented no certificate after %s", h.Duration)
+}
+
+// Do makes a single attempt of the probe.
+func (p TLSProbe) Do() (h Handshake) {
+	start := time.Now()
+	defer func() { h.Duration = time.Since(start) }()
+
+	addr, err := p.addr()
+	if err != nil {
+		h.Err = err
+		return h
+	}
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	raw, err := resolver.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		h.Err = err
+		return h
+	}
+	// The chain is compared with the cert that was attached rather than verified against a root,
+	// since the CAs the specs issue certs with are trusted by nobody.
+	conn := tls.Client(raw, &tls.Config{ServerName: p.ServerName, InsecureSkipVerify: true})
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if err := conn.Handshake(); err != nil {
+		h.Err = err
+		return h
+	}
+	h.PeerCertificates = conn.ConnectionState().PeerCertificates
+	return h
+}
+
+// Until repeats the probe, backing off between attempts, until a handshake satisfies every one of
+// the matchers, and returns that handshake. If the timeout elapses first, the spec fails with the
+// last handshake.
+func (p TLSProbe) Until(timeout time.Duration, matchers ...types.GomegaMatcher) Handshake {
+	matcher := gomega.SatisfyAll(matchers...)
+	var h Handshake
+	retry.Until(context.Background(), retry.Options{
+		Description: fmt.Sprintf("%s to present the expected certificate", p),
+		Timeout:     timeout,
+		Backoff:     backoff,
+	}, func(ctx context.Context) (string, bool, error) {
+		h = p.Do()
+		ok, _ := matcher.Match(h)
+		return h.String(), ok, nil
+	})
+	gomega.ExpectWithOffset(1, h).To(matcher, "%s did not present the expected certificate within %s", p, timeout)
+	return h
+}
+
+func (p TLSProbe) String() string {
+	addr, err := p.addr()
+	if err != nil {
+		addr = p.URL
+	}
+	return fmt.Sprintf("TLS handshake with %s (SNI: %s)", addr, p.ServerName)
+}
+
+// addr returns the host and port of the probe's URL to connect to.
+func (p TLSProbe) addr() (string, error) {
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return "", err
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("%q has no host", p.URL)
+	}
+	if u.Scheme == "https" {
+		if _, _, err := net.SplitHostPort(u.Host); err == nil {
+			return u.Host, nil
+		}
+	}
+	host := u.Host
+	if h, _, err := net.SplitHostPort(u.Host); err == nil {
+		host = h
+	}
+	return net.JoinHostPort(host, "443"), nil
+}
+
+// PresentCertificate succeeds if the leaf certificate of a handshake has the same subject common
+// name, subject alternative names and SHA256 fingerprint as the certificate in cert.CertPath.
+func PresentCertificate(cert model.Cert) types.GomegaMatcher {
+	return &certMatcher{cert: cert}
+}
+
+// CompleteHandshake succeeds if the handshake completed and the server presented a certificate.
+// Negated matchers such as Not(PresentCertificate(cert)) also succeed when the handshake failed,
+// so combine them with this one to tell that the server presented some other certificate.
+func CompleteHandshake() types.GomegaMatcher {
+	return &completedMatcher{}
+}
+
+type completedMatcher struct{}
+
+func (m *completedMatcher) Match(actual interface{}) (bool, error) {
+	h, err := toHandshake(actual)
+	if err != nil {
+		return false, err
+	}
+	return h.Err == nil && h.Leaf() != nil, nil
+}
+
+func (m *completedMatcher) FailureMessage(actual interface{}) string {
+	return format.Message(describeHandshake(actual), "to complete and present a certificate")
+}
+
+func (m *completedMatcher) NegatedFailureMessage(actual interface{}) string {
+	return format.Message(describeHandshake(actual), "not to complete")
+}
+
+type certMatcher struct {
+	cert     model.Cert
+	expected *x509.Certificate
+}
+
+func (m *certMatcher) Match(actual interface{}) (bool, error) {
+	h, err := toHandshake(actual)
+	if err != nil {
+		return false, err
+	}
+	if m.expected == nil {
+		if m.expected, err = pki.ReadCertificate(m.cert.CertPath); err != nil {
+			return false, err
+		}
+	}
+	leaf := h.Leaf()
+	if h.Err != nil || leaf == nil {
+		return false, nil
+	}
+	return leaf.Subject.CommonName == m.expected.Subject.CommonName &&
+		reflect.DeepEqual(pki.SortedNames(leaf.DNSNames), pki.SortedNames(m.expected.DNSNames)) &&
+		pki.Fingerprint(leaf) == pki.Fingerprint(m.expected), nil
+}
+
+func (m *certMatcher) FailureMessage(actual interface{}) string {
+	return format.Message(describeHandshake(actual), "to present", m.description())
+}
+
+func (m *certMatcher) NegatedFailureMessage(actual interface{}) string {
+	return format.Message(describeHandshake(actual), "not to present", m.description())
+}
+
+func (m *certMatcher) description() string {
+	if m.expected == nil {
+		return m.cert.CertPath
+	}
+	return fmt.Sprintf("%s: %q (SANs %v, fingerprint %s)",
+		m.cert.CertPath, m.expected.Subject.CommonName, m.expected.DNSNames, pki.Fingerprint(m.expected))
+}
+
+func toHandshake(actual interface{}) (Handshake, error) {
+	switch h := actual.(type) {
+	case Handshake:
+		return h, nil
+	case *Handshake:
+		if h == nil {
+			return Handshake{}, fmt.Errorf("expected a Handshake, got nil")
+		}
+		return *h, nil
+	}
+	return Handshake{}, fmt.Errorf("expected a Handshake, got\n%s", format.Object(actual, 1))
+}
+
+// describeHandshake summarizes a handshake for a failure message without dumping certificates.
+func describeHandshake(actual interface{}) string {
+	h, err := toHandshake(actual)
+	if err != nil {
+		return fmt.Sprintf("%v", actual)
+	}
+	return h.String()
+}
+
+// RedirectToHTTPS succeeds if the response is a permanent redirect to the same host over HTTPS,
+// as the router sends for apps with TLS enforced.
+func RedirectToHTTPS() types.GomegaMatcher {
+	return &responseMatcher{
+		description: "to redirect permanently to HTTPS",
+		match: func(r Response) bool {
+			if r.StatusCode != 301 {
+				return false
+			}
+			location, err := url.Parse(r.Header.Get("Location"))
+			if err != nil || location.Scheme != "https" {
+				return false
+			}
+			return r.Host == "" || stripPort(location.Host) == stripPort(r.Host)
+		},
+	}
+}
+
+func stripPort(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
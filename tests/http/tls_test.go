@@ -0,0 +1,206 @@
+package http
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/deis/workflow-e2e/tests/model"
+	"github.com/deis/workflow-e2e/tests/pki"
+
+	"github.com/onsi/gomega"
+)
+
+// standIn is a TLS server that, like the router, presents the certificate attached to the domain
+// a client asks for over SNI, and a default one for any other domain.
+type standIn struct {
+	*httptest.Server
+	certs map[string]model.Cert
+	dir   string
+}
+
+func newStandIn(t *testing.T, domains ...string) *standIn {
+	dir, err := ioutil.TempDir("", "tls-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &standIn{certs: map[string]model.Cert{}, dir: dir}
+	pairs := map[string]tls.Certificate{}
+	for _, domain := range append(domains, "default") {
+		ca, err := pki.NewCA("stand-in CA")
+		if err != nil {
+			t.Fatal(err)
+		}
+		issued, err := ca.Issue(pki.Options{CommonName: domain, KeyType: pki.ECDSA})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pair, err := tls.X509KeyPair(issued.CertPEM, issued.KeyPEM)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pairs[domain] = pair
+		cert := model.Cert{Name: domain, CertPath: filepath.Join(dir, domain+".pem")}
+		if err := ioutil.WriteFile(cert.CertPath, issued.CertPEM, 0644); err != nil {
+			t.Fatal(err)
+		}
+		s.certs[domain] = cert
+	}
+
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	// The probe hangs up straight after the handshake, which the server would log as an error.
+	s.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	s.TLS = &tls.Config{GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		if pair, ok := pairs[hello.ServerName]; ok {
+			return &pair, nil
+		}
+		pair := pairs["default"]
+		return &pair, nil
+	}}
+	s.StartTLS()
+	return s
+}
+
+func (s *standIn) Close() {
+	s.Server.Close()
+	os.RemoveAll(s.dir)
+}
+
+func TestTLSProbePresentsCertificateForSNI(t *testing.T) {
+	s := newStandIn(t, "www.foo.com", "bar.com")
+	defer s.Close()
+
+	for _, test := range []struct {
+		serverName string
+		cert       string
+		expected   bool
+	}{
+		{"www.foo.com", "www.foo.com", true},
+		{"www.foo.com", "bar.com", false},
+		{"bar.com", "bar.com", true},
+		{"bar.com", "www.foo.com", false},
+		{"unknown.com", "default", true},
+		{"unknown.com", "www.foo.com", false},
+	} {
+		h := TLSProbe{URL: s.URL, ServerName: test.serverName}.Do()
+		if h.Err != nil {
+			t.Fatalf("handshake for %s failed: %s", test.serverName, h.Err)
+		}
+		if ok := match(PresentCertificate(s.certs[test.cert]), h); ok != test.expected {
+			t.Errorf("handshake for %s matched the %s cert %t, expected %t: %s", test.serverName, test.cert, ok, test.expected, h)
+		}
+		if ok := match(gomega.SatisfyAll(CompleteHandshake(), gomega.Not(PresentCertificate(s.certs[test.cert]))), h); ok == test.expected {
+			t.Errorf("handshake for %s presented another cert than %s %t, expected %t: %s", test.serverName, test.cert, ok, !test.expected, h)
+		}
+	}
+}
+
+func TestTLSProbeUntil(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	s := newStandIn(t, "www.foo.com")
+	defer s.Close()
+
+	h := TLSProbe{URL: s.URL, ServerName: "www.foo.com"}.Until(5*time.Second, PresentCertificate(s.certs["www.foo.com"]))
+	if h.Leaf() == nil || h.Leaf().Subject.CommonName != "www.foo.com" {
+		t.Errorf("expected the www.foo.com cert, got %s", h)
+	}
+}
+
+func TestTLSProbeError(t *testing.T) {
+	s := newStandIn(t, "www.foo.com")
+	url, cert := s.URL, s.certs["www.foo.com"]
+	s.Server.Close()
+	defer os.RemoveAll(s.dir)
+
+	h := TLSProbe{URL: url, ServerName: "www.foo.com", Timeout: time.Second}.Do()
+	if h.Err == nil {
+		t.Errorf("expected a handshake with a closed server to fail, got %s", h)
+	}
+	if match(PresentCertificate(cert), h) {
+		t.Error("expected a failed handshake not to present a certificate")
+	}
+	if match(CompleteHandshake(), h) {
+		t.Error("expected a failed handshake not to complete")
+	}
+	// A failed handshake presents no certificate, so only CompleteHandshake tells it apart from a
+	// server that presents another one.
+	if !match(gomega.Not(PresentCertificate(cert)), h) {
+		t.Error("expected a failed handshake to match Not(PresentCertificate)")
+	}
+	if match(gomega.SatisfyAll(CompleteHandshake(), gomega.Not(PresentCertificate(cert))), h) {
+		t.Error("expected a failed handshake not to count as presenting another certificate")
+	}
+	if _, err := PresentCertificate(cert).Match("www.foo.com"); err == nil {
+		t.Error("expected PresentCertificate to reject a string")
+	}
+	if _, err := PresentCertificate(model.Cert{CertPath: "/does/not/exist"}).Match(h); err == nil {
+		t.Error("expected PresentCertificate to fail on a missing cert file")
+	}
+}
+
+func TestTLSProbeAddr(t *testing.T) {
+	for _, test := range []struct {
+		url      string
+		expected string
+	}{
+		{"http://my-app.example.com", "my-app.example.com:443"},
+		{"http://my-app.example.com:8080", "my-app.example.com:443"},
+		{"https://my-app.example.com", "my-app.example.com:443"},
+		{"https://127.0.0.1:8443", "127.0.0.1:8443"},
+	} {
+		addr, err := TLSProbe{URL: test.url}.addr()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if addr != test.expected {
+			t.Errorf("%s: expected to connect to %s, got %s", test.url, test.expected, addr)
+		}
+	}
+	if _, err := (TLSProbe{URL: "my-app"}).addr(); err == nil {
+		t.Error("expected a URL without a host to be rejected")
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	location := ""
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if location == "" {
+			return
+		}
+		http.Redirect(w, r, location, http.StatusMovedPermanently)
+	}))
+	defer s.Close()
+
+	for _, test := range []struct {
+		location string
+		expected bool
+	}{
+		{"https://my-app.example.com/", true},
+		{"https://my-app.example.com:443/path", true},
+		{"http://my-app.example.com/", false},
+		{"https://other-app.example.com/", false},
+		{"", false},
+	} {
+		location = test.location
+		resp := Probe{URL: s.URL, Host: "my-app.example.com"}.Do()
+		if resp.Err != nil {
+			t.Fatal(resp.Err)
+		}
+		if ok := match(RedirectToHTTPS(), resp); ok != test.expected {
+			t.Errorf("a redirect to %q matched %t, expected %t", test.location, ok, test.expected)
+		}
+	}
+}
+
+func match(matcher interface {
+	Match(interface{}) (bool, error)
+}, actual interface{}) bool {
+	ok, err := matcher.Match(actual)
+	return ok && err == nil
+}
@@ -0,0 +1,182 @@
+package k8s
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Fake is a Client that serves objects held in memory, for testing code that inspects the cluster
+// without one.
+type Fake struct {
+	mu      sync.Mutex
+	objects []interface{}
+	// Err, if set, is returned by every call.
+	Err error
+}
+
+// NewFake returns a client serving the given objects, each of which must be a Pod, Deployment,
+// Service, Node or Namespace.
+func NewFake(objects ...interface{}) *Fake {
+	f := &Fake{}
+	for _, obj := range objects {
+		f.Add(obj)
+	}
+	return f
+}
+
+// Add adds an object to those the client serves.
+func (f *Fake) Add(obj interface{}) {
+	switch obj.(type) {
+	case Pod, Deployment, Service, Node, Namespace:
+	default:
+		panic(fmt.Sprintf("k8s.Fake cannot serve a %T", obj))
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects = append(f.objects, obj)
+}
+
+// list calls fn with every object in the namespace that matches the selector.
+func (f *Fake) list(namespace, selector string, fn func(obj interface{})) error {
+	if f.Err != nil {
+		return f.Err
+	}
+	sel, err := parseSelector(selector)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, obj := range f.objects {
+		m := obj.(interface {
+			meta() Meta
+		}).meta()
+		if namespace != "" && m.Namespace != namespace {
+			continue
+		}
+		if sel.matches(m.Labels) {
+			fn(obj)
+		}
+	}
+	return nil
+}
+
+// Pods returns the fake's pods.
+func (f *Fake) Pods(namespace, selector string) ([]Pod, error) {
+	var pods []Pod
+	err := f.list(namespace, selector, func(obj interface{}) {
+		if pod, ok := obj.(Pod); ok {
+			pods = append(pods, pod)
+		}
+	})
+	return pods, err
+}
+
+// Deployments returns the fake's deployments.
+func (f *Fake) Deployments(namespace, selector string) ([]Deployment, error) {
+	var deployments []Deployment
+	err := f.list(namespace, selector, func(obj interface{}) {
+		if deployment, ok := obj.(Deployment); ok {
+			deployments = append(deployments, deployment)
+		}
+	})
+	return deployments, err
+}
+
+// Services returns the fake's services.
+func (f *Fake) Services(namespace, selector string) ([]Service, error) {
+	var services []Service
+	err := f.list(namespace, selector, func(obj interface{}) {
+		if service, ok := obj.(Service); ok {
+			services = append(services, service)
+		}
+	})
+	return services, err
+}
+
+// Nodes returns the fake's nodes.
+func (f *Fake) Nodes(selector string) ([]Node, error) {
+	var nodes []Node
+	err := f.list("", selector, func(obj interface{}) {
+		if node, ok := obj.(Node); ok {
+			nodes = append(nodes, node)
+		}
+	})
+	return nodes, err
+}
+
+// Namespaces returns the fake's namespaces.
+func (f *Fake) Namespaces(selector string) ([]Namespace, error) {
+	var namespaces []Namespace
+	err := f.list("", selector, func(obj interface{}) {
+		if namespace, ok := obj.(Namespace); ok {
+			namespaces = append(namespaces, namespace)
+		}
+	})
+	return namespaces, err
+}
+
+// requirement is one comma-separated term of a label selector.
+type requirement struct {
+	key   string
+	op    string // "=", "!=", "exists" or "!exists"
+	value string
+}
+
+type selector []requirement
+
+// parseSelector parses the equality-based label selectors kubectl accepts: "k=v", "k==v", "k!=v",
+// "k" and "!k", separated by commas.
+func parseSelector(s string) (selector, error) {
+	var sel selector
+	if strings.TrimSpace(s) == "" {
+		return sel, nil
+	}
+	for _, term := range strings.Split(s, ",") {
+		term = strings.TrimSpace(term)
+		switch {
+		case strings.Contains(term, "!="):
+			parts := strings.SplitN(term, "!=", 2)
+			sel = append(sel, requirement{key: parts[0], op: "!=", value: parts[1]})
+		case strings.Contains(term, "=="):
+			parts := strings.SplitN(term, "==", 2)
+			sel = append(sel, requirement{key: parts[0], op: "=", value: parts[1]})
+		case strings.Contains(term, "="):
+			parts := strings.SplitN(term, "=", 2)
+			sel = append(sel, requirement{key: parts[0], op: "=", value: parts[1]})
+		case strings.HasPrefix(term, "!"):
+			sel = append(sel, requirement{key: term[1:], op: "!exists"})
+		case term != "":
+			sel = append(sel, requirement{key: term, op: "exists"})
+		default:
+			return nil, fmt.Errorf("invalid label selector %q", s)
+		}
+	}
+	return sel, nil
+}
+
+func (sel selector) matches(labels map[string]string) bool {
+	for _, r := range sel {
+		value, ok := labels[r.key]
+		switch r.op {
+		case "=":
+			if !ok || value != r.value {
+				return false
+			}
+		case "!=":
+			if ok && value == r.value {
+				return false
+			}
+		case "exists":
+			if !ok {
+				return false
+			}
+		case "!exists":
+			if ok {
+				return false
+			}
+		}
+	}
+	return true
+}
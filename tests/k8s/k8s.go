@@ -0,0 +1,205 @@
+package k8s
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/deis/workflow-e2e/tests/model"
+	"github.com/deis/workflow-e2e/tests/settings"
+)
+
+// The code in this package inspects the cluster the tests run against, so that specs can check
+// what the controller did to it. Everything goes through a Client: Default runs kubectl with the
+// user's real $HOME, where its config lives, and a Fake holds objects in memory so that the
+// helpers below can be tested without a cluster.
+
+// Client lists the Kubernetes objects the specs look at. Selectors are label selectors such as
+// "app=my-app,type=cmd"; an empty selector matches everything, and an empty namespace means all
+// namespaces.
+type Client interface {
+	Pods(namespace, selector string) ([]Pod, error)
+	Deployments(namespace, selector string) ([]Deployment, error)
+	Services(namespace, selector string) ([]Service, error)
+	Nodes(selector string) ([]Node, error)
+	Namespaces(selector string) ([]Namespace, error)
+}
+
+// Default is the client used by the helpers below.
+var Default Client = NewKubectl(settings.ActualHome)
+
+// Meta is the metadata common to every object.
+type Meta struct {
+	Name      string
+	Namespace string
+	Labels    map[string]string
+}
+
+func (m Meta) meta() Meta { return m }
+
+// Pod is the part of a Kubernetes pod the specs look at.
+type Pod struct {
+	Meta
+	// Phase is e.g. "Pending", "Running" or "Failed".
+	Phase    string
+	NodeName string
+	// StartTime is zero until the pod has been scheduled.
+	StartTime time.Time
+	// Terminating is true once the pod has been asked to shut down.
+	Terminating bool
+	Containers  []Container
+}
+
+// Container is the part of a container spec the specs look at.
+type Container struct {
+	Name      string
+	Image     string
+	Resources ResourceRequirements
+}
+
+// ResourceRequirements holds the requests and limits of a container, keyed by resource name, e.g.
+// "memory" or "cpu", with the quantities as Kubernetes prints them, e.g. "128Mi" or "500m".
+type ResourceRequirements struct {
+	Limits   map[string]string `json:"limits,omitempty"`
+	Requests map[string]string `json:"requests,omitempty"`
+}
+
+// Deployment is the part of a Kubernetes deployment the specs look at.
+type Deployment struct {
+	Meta
+	Replicas          int
+	AvailableReplicas int
+	Containers        []Container
+}
+
+// Service is the part of a Kubernetes service the specs look at.
+type Service struct {
+	Meta
+	ClusterIP string
+	Ports     []ServicePort
+}
+
+// ServicePort is a port exposed by a service. TargetPort may be a number or a port name.
+type ServicePort struct {
+	Name       string
+	Port       int
+	TargetPort string
+}
+
+// Node is the part of a Kubernetes node the specs look at.
+type Node struct {
+	Meta
+}
+
+// Namespace is the part of a Kubernetes namespace the specs look at.
+type Namespace struct {
+	Meta
+	// Phase is "Active" or "Terminating".
+	Phase string
+}
+
+// NotFoundError is returned when a helper expects to find an object but there is none.
+type NotFoundError struct {
+	Kind      string
+	Namespace string
+	Selector  string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("no %s matching %q in namespace %q", e.Kind, e.Selector, e.Namespace)
+}
+
+// IsNotFound returns true if err is a *NotFoundError.
+func IsNotFound(err error) bool {
+	_, ok := err.(*NotFoundError)
+	return ok
+}
+
+// appSelector selects the objects the controller created for a process type of an app. An empty
+// procType selects those of every process type.
+func appSelector(app model.App, procType string) string {
+	selector := "app=" + app.Name
+	if procType != "" {
+		selector += ",type=" + procType
+	}
+	return selector
+}
+
+// PodsForApp returns the pods of the given process type of an app, oldest first. An empty
+// procType returns the pods of every process type.
+func PodsForApp(app model.App, procType string) ([]Pod, error) {
+	pods, err := Default.Pods(app.Name, appSelector(app, procType))
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(byStartTime(pods))
+	return pods, nil
+}
+
+// PodsForRelease returns the pods of the given process type that the controller created for a
+// release of an app, oldest first. Pods of earlier releases that are still shutting down are left
+// out.
+func PodsForRelease(app model.App, procType string, version int) ([]Pod, error) {
+	pods, err := Default.Pods(app.Name, fmt.Sprintf("%s,version=v%d", appSelector(app, procType), version))
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(byStartTime(pods))
+	return pods, nil
+}
+
+type byStartTime []Pod
+
+func (p byStartTime) Len() int           { return len(p) }
+func (p byStartTime) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p byStartTime) Less(i, j int) bool { return p[i].StartTime.Before(p[j].StartTime) }
+
+// DeploymentFor returns the deployment of the given process type of an app.
+func DeploymentFor(app model.App, procType string) (Deployment, error) {
+	selector := appSelector(app, procType)
+	deployments, err := Default.Deployments(app.Name, selector)
+	if err != nil {
+		return Deployment{}, err
+	}
+	if len(deployments) == 0 {
+		return Deployment{}, &NotFoundError{Kind: "deployment", Namespace: app.Name, Selector: selector}
+	}
+	return deployments[0], nil
+}
+
+// ServiceFor returns the service that routes to an app.
+func ServiceFor(app model.App) (Service, error) {
+	selector := appSelector(app, "")
+	services, err := Default.Services(app.Name, selector)
+	if err != nil {
+		return Service{}, err
+	}
+	if len(services) == 0 {
+		return Service{}, &NotFoundError{Kind: "service", Namespace: app.Name, Selector: selector}
+	}
+	return services[0], nil
+}
+
+// NodesWithLabel returns the nodes labelled key=value, or with any value of key if value is
+// empty.
+func NodesWithLabel(key, value string) ([]Node, error) {
+	selector := key
+	if value != "" {
+		selector += "=" + value
+	}
+	return Default.Nodes(selector)
+}
+
+// NamespaceExists returns true if the named namespace exists and is not being deleted.
+func NamespaceExists(name string) (bool, error) {
+	namespaces, err := Default.Namespaces("")
+	if err != nil {
+		return false, err
+	}
+	for _, ns := range namespaces {
+		if ns.Name == name {
+			return ns.Phase != "Terminating", nil
+		}
+	}
+	return false, nil
+}
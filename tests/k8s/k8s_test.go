@@ -0,0 +1,309 @@
+package k8s
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/deis/workflow-e2e/tests/model"
+)
+
+var (
+	app = model.App{Name: "my-app"}
+	now = time.Date(2016, 10, 18, 10, 0, 0, 0, time.UTC)
+)
+
+// useFake makes the helpers use a Fake serving the given objects until the returned function is
+// called.
+func useFake(objects ...interface{}) func() {
+	old := Default
+	Default = NewFake(objects...)
+	return func() { Default = old }
+}
+
+func TestPodsForApp(t *testing.T) {
+	defer useFake(
+		Pod{Meta: Meta{Name: "new", Namespace: "my-app", Labels: map[string]string{"app": "my-app", "type": "cmd"}}, StartTime: now.Add(time.Minute)},
+		Pod{Meta: Meta{Name: "old", Namespace: "my-app", Labels: map[string]string{"app": "my-app", "type": "cmd"}}, StartTime: now},
+		Pod{Meta: Meta{Name: "web", Namespace: "my-app", Labels: map[string]string{"app": "my-app", "type": "web"}}, StartTime: now},
+		Pod{Meta: Meta{Name: "other", Namespace: "other-app", Labels: map[string]string{"app": "other-app", "type": "cmd"}}},
+	)()
+
+	pods, err := PodsForApp(app, "cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods) != 2 || pods[0].Name != "old" || pods[1].Name != "new" {
+		t.Errorf("expected the cmd pods oldest first, got %+v", pods)
+	}
+
+	pods, err = PodsForApp(app, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods) != 3 {
+		t.Errorf("expected the pods of every process type, got %+v", pods)
+	}
+}
+
+func TestPodsForRelease(t *testing.T) {
+	defer useFake(
+		Pod{Meta: Meta{Name: "v3", Namespace: "my-app", Labels: map[string]string{"app": "my-app", "type": "cmd", "version": "v3"}}, StartTime: now.Add(time.Minute)},
+		Pod{Meta: Meta{Name: "v2", Namespace: "my-app", Labels: map[string]string{"app": "my-app", "type": "cmd", "version": "v2"}}, StartTime: now},
+		Pod{Meta: Meta{Name: "web", Namespace: "my-app", Labels: map[string]string{"app": "my-app", "type": "web", "version": "v3"}}, StartTime: now},
+	)()
+
+	pods, err := PodsForRelease(app, "cmd", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods) != 1 || pods[0].Name != "v3" {
+		t.Errorf("expected only the cmd pod of v3, got %+v", pods)
+	}
+}
+
+func TestDeploymentFor(t *testing.T) {
+	defer useFake(
+		Deployment{Meta: Meta{Name: "my-app-cmd", Namespace: "my-app", Labels: map[string]string{"app": "my-app", "type": "cmd"}}, Replicas: 2},
+	)()
+
+	deployment, err := DeploymentFor(app, "cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deployment.Name != "my-app-cmd" || deployment.Replicas != 2 {
+		t.Errorf("got the wrong deployment: %+v", deployment)
+	}
+
+	if _, err := DeploymentFor(app, "worker"); !IsNotFound(err) {
+		t.Errorf("expected a NotFoundError for a missing process type, got %v", err)
+	}
+}
+
+func TestServiceFor(t *testing.T) {
+	defer useFake(
+		Service{Meta: Meta{Name: "my-app", Namespace: "my-app", Labels: map[string]string{"app": "my-app"}}, Ports: []ServicePort{{Port: 80, TargetPort: "5000"}}},
+	)()
+
+	service, err := ServiceFor(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if service.Name != "my-app" || len(service.Ports) != 1 {
+		t.Errorf("got the wrong service: %+v", service)
+	}
+
+	if _, err := ServiceFor(model.App{Name: "missing"}); !IsNotFound(err) {
+		t.Errorf("expected a NotFoundError for a missing app, got %v", err)
+	}
+}
+
+func TestNodesWithLabel(t *testing.T) {
+	defer useFake(
+		Node{Meta: Meta{Name: "a", Labels: map[string]string{"node": "worker1", "zone": "east"}}},
+		Node{Meta: Meta{Name: "b", Labels: map[string]string{"node": "worker2"}}},
+	)()
+
+	for _, test := range []struct {
+		key, value string
+		expected   int
+	}{
+		{"node", "worker1", 1},
+		{"node", "", 2},
+		{"zone", "west", 0},
+	} {
+		nodes, err := NodesWithLabel(test.key, test.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(nodes) != test.expected {
+			t.Errorf("NodesWithLabel(%q, %q) returned %d nodes, expected %d", test.key, test.value, len(nodes), test.expected)
+		}
+	}
+}
+
+func TestNamespaceExists(t *testing.T) {
+	defer useFake(
+		Namespace{Meta: Meta{Name: "my-app"}, Phase: "Active"},
+		Namespace{Meta: Meta{Name: "destroyed-app"}, Phase: "Terminating"},
+	)()
+
+	for name, expected := range map[string]bool{"my-app": true, "destroyed-app": false, "missing": false} {
+		exists, err := NamespaceExists(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exists != expected {
+			t.Errorf("NamespaceExists(%q) = %t, expected %t", name, exists, expected)
+		}
+	}
+}
+
+func TestFakeErr(t *testing.T) {
+	defer useFake()()
+	Default.(*Fake).Err = errors.New("connection refused")
+	if _, err := PodsForApp(app, "cmd"); err == nil {
+		t.Error("expected the client's error")
+	}
+}
+
+func TestSelectors(t *testing.T) {
+	labels := map[string]string{"app": "my-app", "type": "cmd"}
+	for selector, expected := range map[string]bool{
+		"":                     true,
+		"app=my-app":           true,
+		"app==my-app,type=cmd": true,
+		"app=my-app,type=web":  false,
+		"type!=web":            true,
+		"type!=cmd":            false,
+		"app":                  true,
+		"!app":                 false,
+		"!heritage":            true,
+	} {
+		sel, err := parseSelector(selector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sel.matches(labels) != expected {
+			t.Errorf("selector %q matched %t, expected %t", selector, !expected, expected)
+		}
+	}
+}
+
+func TestMatchers(t *testing.T) {
+	pod := Pod{
+		Meta:  Meta{Name: "my-app-cmd-1", Labels: map[string]string{"app": "my-app"}},
+		Phase: "Running",
+		Containers: []Container{{Resources: ResourceRequirements{
+			Limits:   map[string]string{"memory": "100Mi"},
+			Requests: map[string]string{"memory": "0"},
+		}}},
+	}
+	terminating := pod
+	terminating.Terminating = true
+	unlimited := pod
+	unlimited.Containers = []Container{{}}
+
+	for _, test := range []struct {
+		name     string
+		ok       bool
+		expected bool
+	}{
+		{"BeRunning", match(BeRunning(), pod), true},
+		{"BeRunning on a terminating pod", match(BeRunning(), terminating), false},
+		{"HaveContainerResources", match(HaveContainerResources(ResourceRequirements{
+			Limits:   map[string]string{"memory": "100Mi"},
+			Requests: map[string]string{"memory": "0"},
+		}), pod), true},
+		{"HaveContainerResources with different limits", match(HaveContainerResources(ResourceRequirements{
+			Limits: map[string]string{"memory": "100Mi"},
+		}), pod), false},
+		{"HaveContainerResources with none", match(HaveContainerResources(ResourceRequirements{}), unlimited), true},
+		{"EachPod", match(EachPod(BeRunning()), []Pod{pod, unlimited}), true},
+		{"EachPod with one pod that does not match", match(EachPod(BeRunning()), []Pod{pod, terminating}), false},
+		{"EachPod with no pods", match(EachPod(BeRunning()), []Pod{}), false},
+		{"HaveLabel", match(HaveLabel("app", "my-app"), pod), true},
+		{"HaveLabel with another value", match(HaveLabel("app", "other-app"), pod), false},
+	} {
+		if test.ok != test.expected {
+			t.Errorf("%s matched %t, expected %t", test.name, test.ok, test.expected)
+		}
+	}
+
+	if _, err := HaveLabel("app", "my-app").Match("my-app"); err == nil {
+		t.Error("expected HaveLabel to reject a string")
+	}
+	if _, err := EachPod(BeRunning()).Match(pod); err == nil {
+		t.Error("expected EachPod to reject a single pod")
+	}
+
+	each := EachPod(BeRunning())
+	each.Match([]Pod{pod, terminating})
+	if message := each.FailureMessage(nil); !strings.Contains(message, "pod my-app-cmd-1 does not") {
+		t.Errorf("expected the failure message to name the pod that does not match, got:\n%s", message)
+	}
+}
+
+func match(matcher interface {
+	Match(interface{}) (bool, error)
+}, actual interface{}) bool {
+	ok, err := matcher.Match(actual)
+	return ok && err == nil
+}
+
+// TestKubectl runs a stand-in for kubectl that records how it was called.
+func TestKubectl(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8s-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	script := `#!/bin/sh
+echo "$HOME $*" > "$(dirname "$0")/args"
+if [ "$2" = namespaces ]; then
+	echo $$ > "$(dirname "$0")/pid"
+	exec sleep 60
+fi
+if [ "$2" = nodes ]; then
+	echo "error: the server doesn't have a resource type \"nodes\"" >&2
+	exit 1
+fi
+echo "a warning" >&2
+echo '{"items": [{"metadata": {"name": "my-app-cmd-1", "namespace": "my-app", "labels": {"app": "my-app"}}, "status": {"phase": "Running"}}]}'
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "kubectl"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir+string(filepath.ListSeparator)+os.Getenv("PATH"))
+
+	kubectl := NewKubectl("/home/it's me")
+	pods, err := kubectl.Pods("my-app", "app=my-app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods) != 1 || pods[0].Name != "my-app-cmd-1" || pods[0].Phase != "Running" || pods[0].Labels["app"] != "my-app" {
+		t.Errorf("unexpected pods %+v", pods)
+	}
+	args, err := ioutil.ReadFile(filepath.Join(dir, "args"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "/home/it's me get pods --output json --namespace my-app --selector app=my-app\n"; string(args) != expected {
+		t.Errorf("expected kubectl to be run as %q, got %q", expected, args)
+	}
+
+	if _, err := kubectl.Nodes(""); err == nil || !strings.Contains(err.Error(), `doesn't have a resource type "nodes"`) {
+		t.Errorf("expected kubectl's error, got %v", err)
+	}
+
+	// A kubectl that hangs is killed rather than waited on forever.
+	kubectl.Timeout = 100 * time.Millisecond
+	start := time.Now()
+	if _, err := kubectl.Namespaces(""); err == nil || !strings.Contains(err.Error(), "timed out after 100ms") {
+		t.Errorf("expected kubectl to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("expected kubectl to be given up on after its timeout, took %s", elapsed)
+	}
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "pid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(contents)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); syscall.Kill(pid, 0) == nil; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatalf("expected kubectl (%d) to be killed", pid)
+		}
+	}
+}
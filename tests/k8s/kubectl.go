@@ -0,0 +1,238 @@
+package k8s
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/deis/workflow-e2e/tests/cmd"
+	"github.com/deis/workflow-e2e/tests/model"
+	"github.com/deis/workflow-e2e/tests/settings"
+)
+
+// Kubectl is a Client that runs kubectl and decodes its JSON output.
+type Kubectl struct {
+	// Home is the $HOME kubectl runs with, so that it finds its config.
+	Home string
+	// Timeout is how long kubectl may run before it is killed and the call fails.
+	Timeout time.Duration
+}
+
+// NewKubectl returns a client that runs kubectl with the given $HOME, for as long as any
+// Eventually may wait.
+func NewKubectl(home string) *Kubectl {
+	return &Kubectl{Home: home, Timeout: settings.MaxEventuallyTimeout}
+}
+
+// rawMeta is the JSON form of the metadata of any object.
+type rawMeta struct {
+	Name              string            `json:"name"`
+	Namespace         string            `json:"namespace"`
+	Labels            map[string]string `json:"labels"`
+	DeletionTimestamp *time.Time        `json:"deletionTimestamp"`
+}
+
+func (m rawMeta) meta() Meta {
+	return Meta{Name: m.Name, Namespace: m.Namespace, Labels: m.Labels}
+}
+
+type rawContainer struct {
+	Name      string               `json:"name"`
+	Image     string               `json:"image"`
+	Resources ResourceRequirements `json:"resources"`
+}
+
+func containers(raw []rawContainer) []Container {
+	var containers []Container
+	for _, c := range raw {
+		containers = append(containers, Container{Name: c.Name, Image: c.Image, Resources: c.Resources})
+	}
+	return containers
+}
+
+// Pods lists pods with `kubectl get pods`.
+func (k *Kubectl) Pods(namespace, selector string) ([]Pod, error) {
+	var list struct {
+		Items []struct {
+			Metadata rawMeta `json:"metadata"`
+			Spec     struct {
+				NodeName   string         `json:"nodeName"`
+				Containers []rawContainer `json:"containers"`
+			} `json:"spec"`
+			Status struct {
+				Phase     string     `json:"phase"`
+				StartTime *time.Time `json:"startTime"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if err := k.get(&list, "pods", namespace, selector); err != nil {
+		return nil, err
+	}
+	pods := make([]Pod, 0, len(list.Items))
+	for _, item := range list.Items {
+		pod := Pod{
+			Meta:        item.Metadata.meta(),
+			Phase:       item.Status.Phase,
+			NodeName:    item.Spec.NodeName,
+			Terminating: item.Metadata.DeletionTimestamp != nil,
+			Containers:  containers(item.Spec.Containers),
+		}
+		if item.Status.StartTime != nil {
+			pod.StartTime = *item.Status.StartTime
+		}
+		pods = append(pods, pod)
+	}
+	return pods, nil
+}
+
+// Deployments lists deployments with `kubectl get deployments`.
+func (k *Kubectl) Deployments(namespace, selector string) ([]Deployment, error) {
+	var list struct {
+		Items []struct {
+			Metadata rawMeta `json:"metadata"`
+			Spec     struct {
+				Replicas *int `json:"replicas"`
+				Template struct {
+					Spec struct {
+						Containers []rawContainer `json:"containers"`
+					} `json:"spec"`
+				} `json:"template"`
+			} `json:"spec"`
+			Status struct {
+				AvailableReplicas int `json:"availableReplicas"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if err := k.get(&list, "deployments", namespace, selector); err != nil {
+		return nil, err
+	}
+	deployments := make([]Deployment, 0, len(list.Items))
+	for _, item := range list.Items {
+		// Kubernetes defaults an unset replica count to 1.
+		replicas := 1
+		if item.Spec.Replicas != nil {
+			replicas = *item.Spec.Replicas
+		}
+		deployments = append(deployments, Deployment{
+			Meta:              item.Metadata.meta(),
+			Replicas:          replicas,
+			AvailableReplicas: item.Status.AvailableReplicas,
+			Containers:        containers(item.Spec.Template.Spec.Containers),
+		})
+	}
+	return deployments, nil
+}
+
+// Services lists services with `kubectl get services`.
+func (k *Kubectl) Services(namespace, selector string) ([]Service, error) {
+	var list struct {
+		Items []struct {
+			Metadata rawMeta `json:"metadata"`
+			Spec     struct {
+				ClusterIP string `json:"clusterIP"`
+				Ports     []struct {
+					Name       string          `json:"name"`
+					Port       int             `json:"port"`
+					TargetPort json.RawMessage `json:"targetPort"`
+				} `json:"ports"`
+			} `json:"spec"`
+		} `json:"items"`
+	}
+	if err := k.get(&list, "services", namespace, selector); err != nil {
+		return nil, err
+	}
+	services := make([]Service, 0, len(list.Items))
+	for _, item := range list.Items {
+		service := Service{Meta: item.Metadata.meta(), ClusterIP: item.Spec.ClusterIP}
+		for _, p := range item.Spec.Ports {
+			// targetPort is either a number or a quoted port name
+			service.Ports = append(service.Ports, ServicePort{
+				Name:       p.Name,
+				Port:       p.Port,
+				TargetPort: strings.Trim(string(p.TargetPort), `"`),
+			})
+		}
+		services = append(services, service)
+	}
+	return services, nil
+}
+
+// Nodes lists nodes with `kubectl get nodes`.
+func (k *Kubectl) Nodes(selector string) ([]Node, error) {
+	var list struct {
+		Items []struct {
+			Metadata rawMeta `json:"metadata"`
+		} `json:"items"`
+	}
+	if err := k.get(&list, "nodes", "", selector); err != nil {
+		return nil, err
+	}
+	nodes := make([]Node, 0, len(list.Items))
+	for _, item := range list.Items {
+		nodes = append(nodes, Node{Meta: item.Metadata.meta()})
+	}
+	return nodes, nil
+}
+
+// Namespaces lists namespaces with `kubectl get namespaces`.
+func (k *Kubectl) Namespaces(selector string) ([]Namespace, error) {
+	var list struct {
+		Items []struct {
+			Metadata rawMeta `json:"metadata"`
+			Status   struct {
+				Phase string `json:"phase"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if err := k.get(&list, "namespaces", "", selector); err != nil {
+		return nil, err
+	}
+	namespaces := make([]Namespace, 0, len(list.Items))
+	for _, item := range list.Items {
+		namespaces = append(namespaces, Namespace{Meta: item.Metadata.meta(), Phase: item.Status.Phase})
+	}
+	return namespaces, nil
+}
+
+// get runs `kubectl get <kind> -o json` and decodes the list it prints into out. Nodes and
+// namespaces are not namespaced, so namespace is ignored for them.
+func (k *Kubectl) get(out interface{}, kind, namespace, selector string) error {
+	args := []string{"get", kind, "--output", "json"}
+	switch {
+	case kind == "nodes" || kind == "namespaces":
+	case namespace == "":
+		args = append(args, "--all-namespaces")
+	default:
+		args = append(args, "--namespace", namespace)
+	}
+	if selector != "" {
+		args = append(args, "--selector", selector)
+	}
+
+	// Run kubectl like any other command, so that it shows up in the command log, but keep its
+	// output apart from its warnings so that only the JSON is decoded.
+	commandLine := "kubectl"
+	for _, arg := range args {
+		commandLine += " " + cmd.Quote(arg)
+	}
+	// The shell execs kubectl, so that killing the session kills kubectl.
+	sess, err := cmd.StartCmd(model.Cmd{Env: append(os.Environ(), "HOME="+k.Home), CommandLineString: "exec " + commandLine})
+	if err != nil {
+		return fmt.Errorf("%s: %s", commandLine, err)
+	}
+	select {
+	case <-sess.Exited:
+	case <-time.After(k.Timeout):
+		sess.Kill()
+		return fmt.Errorf("%s: timed out after %s", commandLine, k.Timeout)
+	}
+	if code := sess.ExitCode(); code != 0 {
+		return fmt.Errorf("%s: exit status %d: %s", commandLine, code, strings.TrimSpace(string(sess.Err.Contents())))
+	}
+	if err := json.Unmarshal(sess.Out.Contents(), out); err != nil {
+		return fmt.Errorf("%s: %s", commandLine, err)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package k8s
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/onsi/gomega/format"
+	"github.com/onsi/gomega/types"
+)
+
+// BeRunning succeeds if the actual value is a Pod that is running and not being shut down.
+func BeRunning() types.GomegaMatcher {
+	return &podMatcher{
+		description: "to be running",
+		match: func(pod Pod) bool {
+			return pod.Phase == "Running" && !pod.Terminating
+		},
+	}
+}
+
+// HaveContainerResources succeeds if the actual value is a Pod whose first container has the given
+// resource requirements. Missing and empty requests or limits are treated alike.
+func HaveContainerResources(resources ResourceRequirements) types.GomegaMatcher {
+	return &podMatcher{
+		description: fmt.Sprintf("to have container resources %+v", resources),
+		match: func(pod Pod) bool {
+			if len(pod.Containers) == 0 {
+				return false
+			}
+			actual := pod.Containers[0].Resources
+			return sameQuantities(actual.Limits, resources.Limits) &&
+				sameQuantities(actual.Requests, resources.Requests)
+		},
+	}
+}
+
+// EachPod succeeds if the actual value is a non-empty []Pod and every one of them satisfies the
+// matcher, unlike ContainElement, which is satisfied by any one pod.
+func EachPod(matcher types.GomegaMatcher) types.GomegaMatcher {
+	return &eachPodMatcher{matcher: matcher}
+}
+
+// HaveLabel succeeds if the actual value is a Pod, Deployment, Service, Node or Namespace labelled
+// key=value.
+func HaveLabel(key, value string) types.GomegaMatcher {
+	return &labelMatcher{key: key, value: value}
+}
+
+type podMatcher struct {
+	description string
+	match       func(Pod) bool
+}
+
+func (m *podMatcher) Match(actual interface{}) (bool, error) {
+	pod, ok := actual.(Pod)
+	if !ok {
+		return false, fmt.Errorf("expected a k8s.Pod, got\n%s", format.Object(actual, 1))
+	}
+	return m.match(pod), nil
+}
+
+func (m *podMatcher) FailureMessage(actual interface{}) string {
+	return format.Message(actual, m.description)
+}
+
+func (m *podMatcher) NegatedFailureMessage(actual interface{}) string {
+	return format.Message(actual, "not "+m.description)
+}
+
+type eachPodMatcher struct {
+	matcher types.GomegaMatcher
+	// failure says why the last match failed.
+	failure string
+}
+
+func (m *eachPodMatcher) Match(actual interface{}) (bool, error) {
+	pods, ok := actual.([]Pod)
+	if !ok {
+		return false, fmt.Errorf("expected a []k8s.Pod, got\n%s", format.Object(actual, 1))
+	}
+	if len(pods) == 0 {
+		m.failure = "there are no pods"
+		return false, nil
+	}
+	for _, pod := range pods {
+		ok, err := m.matcher.Match(pod)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			m.failure = fmt.Sprintf("pod %s does not:\n%s", pod.Name, m.matcher.FailureMessage(pod))
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (m *eachPodMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected every pod to satisfy the matcher, but %s", m.failure)
+}
+
+func (m *eachPodMatcher) NegatedFailureMessage(actual interface{}) string {
+	return format.Message(actual, "not to be pods that all satisfy the matcher")
+}
+
+type labelMatcher struct {
+	key, value string
+}
+
+func (m *labelMatcher) Match(actual interface{}) (bool, error) {
+	obj, ok := actual.(interface {
+		meta() Meta
+	})
+	if !ok {
+		return false, fmt.Errorf("expected a Kubernetes object, got\n%s", format.Object(actual, 1))
+	}
+	value, ok := obj.meta().Labels[m.key]
+	return ok && value == m.value, nil
+}
+
+func (m *labelMatcher) FailureMessage(actual interface{}) string {
+	return format.Message(actual, fmt.Sprintf("to have label %s=%s", m.key, m.value))
+}
+
+func (m *labelMatcher) NegatedFailureMessage(actual interface{}) string {
+	return format.Message(actual, fmt.Sprintf("not to have label %s=%s", m.key, m.value))
+}
+
+func sameQuantities(a, b map[string]string) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -1,7 +1,7 @@
 package tests
 
 import (
-	"fmt"
+	"io/ioutil"
 
 	"github.com/deis/workflow-e2e/tests/cmd"
 	"github.com/deis/workflow-e2e/tests/cmd/auth"
@@ -10,9 +10,11 @@ import (
 	"github.com/deis/workflow-e2e/tests/settings"
 
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 	. "github.com/onsi/gomega/gbytes"
 	. "github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/types"
 )
 
 var _ = Describe("deis keys", func() {
@@ -31,26 +33,87 @@ var _ = Describe("deis keys", func() {
 
 		Context("who has at least one key", func() {
 
-			var keyName string
+			var key model.Key
 
 			BeforeEach(func() {
-				keyName, _ = keys.Add(user)
+				key = keys.Add(user)
 			})
 
 			Specify("that user can list their own keys", func() {
-				sess, err := cmd.Start("deis keys:list", &user)
-				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say(fmt.Sprintf("%s ssh-rsa", keyName)))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
+				listed := keys.List(user)
+				Expect(listed).To(ContainElement(beListingOf(key)))
+				Expect(listed).To(HaveLen(1))
+				Expect(listed[0].Type()).To(Equal("ssh-rsa"))
+				Expect(keys.Default.Owned(user)).To(Equal([]model.Key{key}))
+			})
+
+			Context("and another user also exists", func() {
+
+				var otherUser model.User
+
+				BeforeEach(func() {
+					otherUser = auth.RegisterAndLogin()
+				})
+
+				AfterEach(func() {
+					auth.Cancel(otherUser)
+				})
+
+				Specify("that other user cannot add the key already registered to the first user", func() {
+					sess, err := cmd.Start("deis keys:add %s", &otherUser, key.PublicPath())
+					Expect(err).NotTo(HaveOccurred())
+					Eventually(sess.Err, settings.MaxEventuallyTimeout).Should(Say("already"))
+					Eventually(sess).Should(Exit(1))
+
+					Expect(keys.List(otherUser)).To(BeEmpty())
+					Expect(keys.List(user)).To(ContainElement(beListingOf(key)))
+				})
+
+				Specify("that other user gets key material of their own", func() {
+					otherKey := keys.Add(otherUser)
+					Expect(otherKey.Owner).To(Equal(otherUser.Username))
+					Expect(otherKey.Path).NotTo(Equal(key.Path))
+					Expect(readFile(otherKey.Path)).NotTo(Equal(readFile(key.Path)))
+					Expect(keys.Default.Owned(otherUser)).To(Equal([]model.Key{otherKey}))
+					Expect(keys.Default.Owned(user)).To(Equal([]model.Key{key}))
+					Expect(keys.List(otherUser)).NotTo(ContainElement(beListingOf(key)))
+				})
+
 			})
 
 		})
 
 		Specify("that user can add and remove keys", func() {
-			keyName, _ := keys.Add(user)
-			keys.Remove(user, keyName)
+			key := keys.Add(user)
+			Expect(keys.List(user)).To(ContainElement(beListingOf(key)))
+			keys.Remove(user, key)
+			Expect(keys.List(user)).NotTo(ContainElement(beListingOf(key)))
 		})
 
+		DescribeTable("that user can add keys of each type",
+			func(keyType, listedType string) {
+				key := keys.AddOfType(user, keyType)
+				Expect(key.Type).To(Equal(keyType))
+				listed := keys.List(user)
+				Expect(listed).To(ContainElement(beListingOf(key)))
+				Expect(listed[0].Type()).To(Equal(listedType))
+				keys.Remove(user, key)
+			},
+			Entry("RSA", keys.RSA, "ssh-rsa"),
+			Entry("ed25519", keys.Ed25519, "ssh-ed25519"),
+		)
+
 	})
 
 })
+
+// beListingOf succeeds if a keys.Listing is of the key.
+func beListingOf(key model.Key) types.GomegaMatcher {
+	return WithTransform(func(l keys.Listing) bool { return l.Is(key) }, BeTrue())
+}
+
+func readFile(path string) []byte {
+	contents, err := ioutil.ReadFile(path)
+	Expect(err).NotTo(HaveOccurred())
+	return contents
+}
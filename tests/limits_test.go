@@ -7,6 +7,9 @@ import (
 	"github.com/deis/workflow-e2e/tests/cmd/apps"
 	"github.com/deis/workflow-e2e/tests/cmd/auth"
 	"github.com/deis/workflow-e2e/tests/cmd/builds"
+	"github.com/deis/workflow-e2e/tests/cmd/limits"
+	"github.com/deis/workflow-e2e/tests/cmd/releases"
+	"github.com/deis/workflow-e2e/tests/k8s"
 	"github.com/deis/workflow-e2e/tests/model"
 	"github.com/deis/workflow-e2e/tests/settings"
 
@@ -14,8 +17,6 @@ import (
 	. "github.com/onsi/gomega"
 	. "github.com/onsi/gomega/gbytes"
 	. "github.com/onsi/gomega/gexec"
-
-	"strings"
 )
 
 var _ = Describe("deis limits", func() {
@@ -45,6 +46,23 @@ var _ = Describe("deis limits", func() {
 				apps.Destroy(user, app)
 			})
 
+			// runningWith matches the cmd pods of a release once every one of them is running with the
+			// resources Kubernetes should give it for the limit.
+			runningWith := func(requirements k8s.ResourceRequirements) OmegaMatcher {
+				return k8s.EachPod(And(k8s.BeRunning(), k8s.HaveContainerResources(requirements)))
+			}
+
+			// latestCmdPods returns a function that lists the cmd pods of the app's latest release,
+			// leaving out those of earlier releases that may still be shutting down.
+			latestCmdPods := func() func() []k8s.Pod {
+				version := releases.List(user, app)[0].Version
+				return func() []k8s.Pod {
+					pods, err := k8s.PodsForRelease(app, "cmd", version)
+					Expect(err).NotTo(HaveOccurred())
+					return pods
+				}
+			}
+
 			Specify("that user can list that app's limits", func() {
 				sess, err := cmd.Start("deis limits:list -a %s", &user, app.Name)
 				Eventually(sess).Should(Say(fmt.Sprintf("=== %s Limits", app.Name)))
@@ -52,92 +70,41 @@ var _ = Describe("deis limits", func() {
 				Eventually(sess).Should(Say("--- CPU\nUnlimited"))
 				Expect(err).NotTo(HaveOccurred())
 				Eventually(sess).Should(Exit(0))
+
+				Expect(limits.List(user, app)).To(Equal(limits.Limits{
+					Memory: map[string]limits.Limit{},
+					CPU:    map[string]limits.Limit{},
+				}))
 			})
 
 			Specify("that user can set a memory limit on that application", func() {
+				// memory is the default resource
 				sess, err := cmd.Start("deis limits:set cmd=64M -a %s", &user, app.Name)
 				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("--- Memory\ncmd     64M"))
 				Expect(err).NotTo(HaveOccurred())
 				Eventually(sess).Should(Exit(0))
 
-				// Check that --memory also works
-				// 128M
-				sess, err = cmd.Start("deis limits:set --memory cmd=128M -a %s", &user, app.Name)
-				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("--- Memory\ncmd     128M"))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
-
-				// Check Kubernetes pods manifest
-				sess, err = cmd.Start("HOME=%s kubectl get --all-namespaces pods -l app=%s --sort-by='.status.startTime' -o jsonpath={.items[*].spec.containers[0].resources}", nil, settings.ActualHome, app.Name)
-				Eventually(sess).Should(Exit(0))
-				Expect(err).NotTo(HaveOccurred())
-				resource := string(sess.Out.Contents())
-				// try to get test latest pod, in case cmd still see terminated pod.
-				// Also as per bug in https://github.com/kubernetes/kubernetes/issues/16707
-				if strings.Contains(resource, "] map[") {
-					resource = resource[strings.Index(resource, "] map[")+len("] "):]
-				}
-				Expect(resource).Should(SatisfyAny(
-					Equal("map[requests:map[memory:128Mi] limits:map[memory:128Mi]]"),
-					Equal("map[limits:map[memory:128Mi] requests:map[memory:128Mi]]")))
-
-				// 0/100M
-				sess, err = cmd.Start("deis limits:set cmd=0/100M -a %s", &user, app.Name)
-				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("--- Memory\ncmd     0/100M"))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
-
-				// Check Kubernetes pods manifest
-				sess, err = cmd.Start("HOME=%s kubectl get --all-namespaces pods -l app=%s --sort-by='.status.startTime' -o jsonpath={.items[*].spec.containers[0].resources}", nil, settings.ActualHome, app.Name)
-				Eventually(sess).Should(Exit(0))
-				Expect(err).NotTo(HaveOccurred())
-				resource = string(sess.Out.Contents())
-				// try to get test latest pod, in case cmd still see terminated pod.
-				if strings.Contains(resource, "] map[") {
-					resource = resource[strings.Index(resource, "] map[")+len("] "):]
-				}
-				Expect(resource).Should(SatisfyAny(
-					Equal("map[requests:map[memory:0] limits:map[memory:100Mi]]"),
-					Equal("map[limits:map[memory:100Mi] requests:map[memory:0]]")))
-
-				// 50/100MB
-				sess, err = cmd.Start("deis limits:set cmd=50M/100MB -a %s", &user, app.Name)
-				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("--- Memory\ncmd     50M/100M"))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
-
-				// Check Kubernetes pods manifest
-				sess, err = cmd.Start("HOME=%s kubectl get --all-namespaces pods -l app=%s --sort-by='.status.startTime' -o jsonpath={.items[*].spec.containers[0].resources}", nil, settings.ActualHome, app.Name)
-				Eventually(sess).Should(Exit(0))
-				Expect(err).NotTo(HaveOccurred())
-				resource = string(sess.Out.Contents())
-				// try to get test latest pod, in case cmd still see terminated pod.
-				if strings.Contains(resource, "] map[") {
-					resource = resource[strings.Index(resource, "] map[")+len("] "):]
+				for _, limit := range []struct {
+					set    limits.Limit
+					listed limits.Limit
+				}{
+					{limits.Limit{Limit: "128M"}, limits.Limit{Limit: "128M"}},
+					{limits.Limit{Request: "0", Limit: "100M"}, limits.Limit{Request: "0", Limit: "100M"}},
+					{limits.Limit{Request: "50M", Limit: "100MB"}, limits.Limit{Request: "50M", Limit: "100M"}},
+				} {
+					listed := limits.Set(user, app, limits.Memory, "cmd", limit.set)
+					Expect(listed.Memory).To(HaveKeyWithValue("cmd", limit.listed))
+					Expect(limits.List(user, app)).To(Equal(listed))
+					Eventually(latestCmdPods(), settings.MaxEventuallyTimeout).Should(runningWith(limit.set.Requirements(limits.Memory)))
 				}
-				Expect(resource).Should(SatisfyAny(
-					Equal("map[requests:map[memory:50Mi] limits:map[memory:100Mi]]"),
-					Equal("map[limits:map[memory:100Mi] requests:map[memory:50Mi]]")))
 			})
 
 			Specify("that user can set a CPU limit on that application", func() {
-				sess, err := cmd.Start("deis limits:set --cpu cmd=500m -a %s", &user, app.Name)
-				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("--- CPU\ncmd     500m"))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
-
-				// Check Kubernetes pods manifest
-				sess, err = cmd.Start("HOME=%s kubectl get --all-namespaces pods -l app=%s --sort-by='.status.startTime' -o jsonpath={.items[*].spec.containers[0].resources}", nil, settings.ActualHome, app.Name)
-				Eventually(sess).Should(Exit(0))
-				Expect(err).NotTo(HaveOccurred())
-				resource := string(sess.Out.Contents())
-				// try to get test latest pod, in case cmd still see terminated pod.
-				if strings.Contains(resource, "] map[") {
-					resource = resource[strings.Index(resource, "] map[")+len("] "):]
-				}
-				Expect(resource).Should(SatisfyAny(
-					Equal("map[requests:map[cpu:500m] limits:map[cpu:500m]]"),
-					Equal("map[limits:map[cpu:500m] requests:map[cpu:500m]]")))
+				limit := limits.Limit{Limit: "500m"}
+				listed := limits.Set(user, app, limits.CPU, "cmd", limit)
+				Expect(listed.CPU).To(HaveKeyWithValue("cmd", limit))
+				Expect(listed.Memory).To(BeEmpty())
+				Eventually(latestCmdPods(), settings.MaxEventuallyTimeout).Should(runningWith(limit.Requirements(limits.CPU)))
 			})
 
 			Specify("that user can unset a memory limit on that application", func() {
@@ -146,22 +113,11 @@ var _ = Describe("deis limits", func() {
 				Expect(err).NotTo(HaveOccurred())
 				Eventually(sess).Should(Exit(1))
 
-				// Check that --memory also works
-				sess, err = cmd.Start("deis limits:set --memory cmd=64M -a %s", &user, app.Name)
-				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("--- Memory\ncmd     64M"))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
-				sess, err = cmd.Start("deis limits:unset --memory cmd -a %s", &user, app.Name)
-				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("--- Memory\nUnlimited"))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
+				limits.Set(user, app, limits.Memory, "cmd", limits.Limit{Limit: "64M"})
+				listed := limits.Unset(user, app, limits.Memory, "cmd")
+				Expect(listed.Memory).To(BeEmpty())
 
-				// Check Kubernetes pods manifest
-				sess, err = cmd.Start("HOME=%s kubectl get --all-namespaces pods -l app=%s -o jsonpath={.items[*].spec.containers[0].resources}", nil, settings.ActualHome, app.Name)
-				Eventually(sess).Should(Exit(0))
-				Expect(err).NotTo(HaveOccurred())
-				// At least 1 pod have empty resources
-				Expect(string(sess.Out.Contents())).Should(ContainSubstring("map[]"))
+				Eventually(latestCmdPods(), settings.MaxEventuallyTimeout).Should(runningWith(k8s.ResourceRequirements{}))
 			})
 
 			Specify("that user can unset a CPU limit on that application", func() {
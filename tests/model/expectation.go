@@ -0,0 +1,256 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/onsi/gomega/format"
+	"github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/types"
+)
+
+// Expectation is something the result of a command can be checked against. CmdResult is itself
+// an Expectation; the functions below build others and combine them.
+type Expectation interface {
+	// Check returns nil if the result meets the expectation, and otherwise an error saying why not.
+	Check(result CmdResult) error
+	String() string
+}
+
+// Check returns an error if the result, ocd, does not satisfy the CmdResult, ecd, as defined by
+// Satisfies.
+func (ecd CmdResult) Check(ocd CmdResult) error {
+	if !ocd.Satisfies(ecd) {
+		return fmt.Errorf("%s does not satisfy %s", ocd, ecd)
+	}
+	return nil
+}
+
+type expectation struct {
+	description string
+	check       func(CmdResult) error
+}
+
+func (e expectation) Check(result CmdResult) error { return e.check(result) }
+func (e expectation) String() string               { return e.description }
+
+// ExpectationFunc returns an Expectation that calls check, described by description.
+func ExpectationFunc(description string, check func(CmdResult) error) Expectation {
+	return expectation{description: description, check: check}
+}
+
+// OutContains expects stdout to contain substr.
+func OutContains(substr string) Expectation {
+	return contains("stdout", substr, func(r CmdResult) []byte { return r.Out })
+}
+
+// ErrContains expects stderr to contain substr.
+func ErrContains(substr string) Expectation {
+	return contains("stderr", substr, func(r CmdResult) []byte { return r.Err })
+}
+
+// OutMatches expects stdout to match the regular expression pattern.
+func OutMatches(pattern string) Expectation {
+	return matches("stdout", regexp.MustCompile(pattern), func(r CmdResult) []byte { return r.Out })
+}
+
+// ErrMatches expects stderr to match the regular expression pattern.
+func ErrMatches(pattern string) Expectation {
+	return matches("stderr", regexp.MustCompile(pattern), func(r CmdResult) []byte { return r.Err })
+}
+
+// ExitCodeIn expects the command to exit with one of the given codes.
+func ExitCodeIn(codes ...int) Expectation {
+	strs := make([]string, len(codes))
+	for i, code := range codes {
+		strs[i] = strconv.Itoa(code)
+	}
+	description := fmt.Sprintf("exit code in [%s]", strings.Join(strs, ", "))
+	return ExpectationFunc(description, func(r CmdResult) error {
+		for _, code := range codes {
+			if r.ExitCode == code {
+				return nil
+			}
+		}
+		return fmt.Errorf("exit code %d is not in [%s]", r.ExitCode, strings.Join(strs, ", "))
+	})
+}
+
+// OutJSON expects stdout to be a JSON document with value at path. Path elements are separated by
+// dots and may be object keys or array indices, e.g. "results.0.id". The value is compared after
+// a round trip through encoding/json, so 1 and 1.0 are equal.
+func OutJSON(path string, value interface{}) Expectation {
+	description := fmt.Sprintf("stdout JSON %s == %v", path, value)
+	return ExpectationFunc(description, func(r CmdResult) error {
+		var doc interface{}
+		if err := json.Unmarshal(r.Out, &doc); err != nil {
+			return fmt.Errorf("stdout is not JSON: %s", err)
+		}
+		actual, err := jsonPath(doc, path)
+		if err != nil {
+			return err
+		}
+		expected, err := normalizeJSON(value)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			return fmt.Errorf("stdout JSON %s is %v, not %v", path, actual, expected)
+		}
+		return nil
+	})
+}
+
+// Not expects the result not to meet e, e.g. Not(ErrContains("Another git push is ongoing")).
+func Not(e Expectation) Expectation {
+	return ExpectationFunc(fmt.Sprintf("not (%s)", e), func(r CmdResult) error {
+		if e.Check(r) == nil {
+			return fmt.Errorf("unexpectedly met %s", e)
+		}
+		return nil
+	})
+}
+
+// AllOf expects the result to meet every one of expectations.
+func AllOf(expectations ...Expectation) Expectation {
+	return ExpectationFunc(join(expectations, " and "), func(r CmdResult) error {
+		for _, e := range expectations {
+			if err := e.Check(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// AnyOf expects the result to meet at least one of expectations.
+func AnyOf(expectations ...Expectation) Expectation {
+	return ExpectationFunc(join(expectations, " or "), func(r CmdResult) error {
+		var errs []string
+		for _, e := range expectations {
+			err := e.Check(r)
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, err.Error())
+		}
+		return fmt.Errorf("met none of: %s", strings.Join(errs, "; "))
+	})
+}
+
+// MeetExpectation succeeds if the actual value, a CmdResult or an exited *gexec.Session, meets
+// the expectation.
+func MeetExpectation(e Expectation) types.GomegaMatcher {
+	return &expectationMatcher{expectation: e}
+}
+
+type expectationMatcher struct {
+	expectation Expectation
+	err         error
+}
+
+func (m *expectationMatcher) Match(actual interface{}) (bool, error) {
+	result, err := toCmdResult(actual)
+	if err != nil {
+		return false, err
+	}
+	m.err = m.expectation.Check(result)
+	return m.err == nil, nil
+}
+
+func (m *expectationMatcher) FailureMessage(actual interface{}) string {
+	return format.Message(describeCmdResult(actual), fmt.Sprintf("to meet %s, but %s", m.expectation, m.err))
+}
+
+func (m *expectationMatcher) NegatedFailureMessage(actual interface{}) string {
+	return format.Message(describeCmdResult(actual), fmt.Sprintf("not to meet %s", m.expectation))
+}
+
+func toCmdResult(actual interface{}) (CmdResult, error) {
+	switch a := actual.(type) {
+	case CmdResult:
+		return a, nil
+	case *gexec.Session:
+		if a.ExitCode() == -1 {
+			return CmdResult{}, fmt.Errorf("MeetExpectation requires the session to have exited")
+		}
+		return CmdResult{Out: a.Out.Contents(), Err: a.Err.Contents(), ExitCode: a.ExitCode()}, nil
+	}
+	return CmdResult{}, fmt.Errorf("MeetExpectation expects a CmdResult or *gexec.Session, got\n%s", format.Object(actual, 1))
+}
+
+func describeCmdResult(actual interface{}) string {
+	if result, err := toCmdResult(actual); err == nil {
+		return result.String()
+	}
+	return fmt.Sprintf("%v", actual)
+}
+
+func contains(stream, substr string, get func(CmdResult) []byte) Expectation {
+	return ExpectationFunc(fmt.Sprintf("%s containing %q", stream, substr), func(r CmdResult) error {
+		if !bytes.Contains(get(r), []byte(substr)) {
+			return fmt.Errorf("%s does not contain %q", stream, substr)
+		}
+		return nil
+	})
+}
+
+func matches(stream string, re *regexp.Regexp, get func(CmdResult) []byte) Expectation {
+	return ExpectationFunc(fmt.Sprintf("%s matching %q", stream, re), func(r CmdResult) error {
+		if !re.Match(get(r)) {
+			return fmt.Errorf("%s does not match %q", stream, re)
+		}
+		return nil
+	})
+}
+
+func join(expectations []Expectation, sep string) string {
+	strs := make([]string, len(expectations))
+	for i, e := range expectations {
+		strs[i] = e.String()
+	}
+	return "(" + strings.Join(strs, sep) + ")"
+}
+
+// jsonPath walks a decoded JSON document along a dotted path.
+func jsonPath(doc interface{}, path string) (interface{}, error) {
+	if path == "" {
+		return doc, nil
+	}
+	current := doc
+	for _, elem := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[elem]
+			if !ok {
+				return nil, fmt.Errorf("stdout JSON has no %q in %s", elem, path)
+			}
+			current = value
+		case []interface{}:
+			i, err := strconv.Atoi(elem)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, fmt.Errorf("stdout JSON has no index %q in %s", elem, path)
+			}
+			current = node[i]
+		default:
+			return nil, fmt.Errorf("stdout JSON has no %q in %s", elem, path)
+		}
+	}
+	return current, nil
+}
+
+// normalizeJSON converts a Go value to what decoding its JSON encoding would give.
+func normalizeJSON(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var normalized interface{}
+	err = json.Unmarshal(data, &normalized)
+	return normalized, err
+}
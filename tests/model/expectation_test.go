@@ -0,0 +1,144 @@
+package model
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega/gexec"
+)
+
+func TestExpectations(t *testing.T) {
+	result := CmdResult{
+		Out:      []byte(`{"count": 2, "results": [{"id": "my-app", "structure": {"web": 1}}, {"id": "other"}]}`),
+		Err:      []byte("Warning: deprecated\nfatal: Another git push is ongoing\n"),
+		ExitCode: 128,
+	}
+	plain := CmdResult{Out: []byte("=== my-app Processes\n--- web:\nmy-app-web-1 up (v2)\n"), ExitCode: 0}
+
+	tests := []struct {
+		expectation Expectation
+		result      CmdResult
+		description string
+		// err is the error the expectation fails with, if any.
+		err string
+	}{
+		{CmdResult{Err: []byte("Another git push"), ExitCode: 128}, result,
+			"[Out: '', Err: 'Another git push', ExitCode: '128']", ""},
+		{CmdResult{ExitCode: 0}, result,
+			"[Out: '', Err: '', ExitCode: '0']", "does not satisfy [Out: '', Err: '', ExitCode: '0']"},
+
+		{OutContains("my-app-web-1 up"), plain, `stdout containing "my-app-web-1 up"`, ""},
+		{OutContains("down"), plain, `stdout containing "down"`, `stdout does not contain "down"`},
+		{ErrContains("Another git push"), result, `stderr containing "Another git push"`, ""},
+		{ErrContains("Another git push"), plain, `stderr containing "Another git push"`, `stderr does not contain "Another git push"`},
+
+		{OutMatches(`(?m)^my-app-web-\d+ up \(v\d+\)$`), plain, `stdout matching "(?m)^my-app-web-\\d+ up \\(v\\d+\\)$"`, ""},
+		{OutMatches(`crashed`), plain, `stdout matching "crashed"`, `stdout does not match "crashed"`},
+		{ErrMatches(`(?m)^fatal: .*ongoing$`), result, `stderr matching "(?m)^fatal: .*ongoing$"`, ""},
+		{ErrMatches(`^fatal`), result, `stderr matching "^fatal"`, `stderr does not match "^fatal"`},
+
+		{ExitCodeIn(0, 128), result, "exit code in [0, 128]", ""},
+		{ExitCodeIn(0), result, "exit code in [0]", "exit code 128 is not in [0]"},
+		{ExitCodeIn(), plain, "exit code in []", "exit code 0 is not in []"},
+
+		{OutJSON("count", 2), result, "stdout JSON count == 2", ""},
+		{OutJSON("count", 2.0), result, "stdout JSON count == 2", ""},
+		{OutJSON("results.0.id", "my-app"), result, "stdout JSON results.0.id == my-app", ""},
+		{OutJSON("results.0.structure", map[string]int{"web": 1}), result, "stdout JSON results.0.structure == map[web:1]", ""},
+		{OutJSON("results.1.id", "my-app"), result, "stdout JSON results.1.id == my-app", "stdout JSON results.1.id is other, not my-app"},
+		{OutJSON("results.2.id", "my-app"), result, "stdout JSON results.2.id == my-app", `stdout JSON has no index "2" in results.2.id`},
+		{OutJSON("results.first.id", "my-app"), result, "stdout JSON results.first.id == my-app", `stdout JSON has no index "first" in results.first.id`},
+		{OutJSON("results.0.name", "my-app"), result, "stdout JSON results.0.name == my-app", `stdout JSON has no "name" in results.0.name`},
+		{OutJSON("count.value", 2), result, "stdout JSON count.value == 2", `stdout JSON has no "value" in count.value`},
+		{OutJSON("count", 2), plain, "stdout JSON count == 2", "stdout is not JSON: invalid character '=' looking for beginning of value"},
+
+		{Not(ErrContains("Another git push")), plain, `not (stderr containing "Another git push")`, ""},
+		{Not(ErrContains("Another git push")), result, `not (stderr containing "Another git push")`, `unexpectedly met stderr containing "Another git push"`},
+
+		{AllOf(ExitCodeIn(128), ErrContains("fatal")), result, `(exit code in [128] and stderr containing "fatal")`, ""},
+		{AllOf(ExitCodeIn(128), ErrContains("denied"), ErrContains("missing")), result,
+			`(exit code in [128] and stderr containing "denied" and stderr containing "missing")`, `stderr does not contain "denied"`},
+		{AllOf(), plain, "()", ""},
+
+		{AnyOf(ExitCodeIn(0), ErrContains("fatal")), result, `(exit code in [0] or stderr containing "fatal")`, ""},
+		{AnyOf(ExitCodeIn(0), OutContains("up")), result, `(exit code in [0] or stdout containing "up")`,
+			`met none of: exit code 128 is not in [0]; stdout does not contain "up"`},
+		{AnyOf(), plain, "()", "met none of: "},
+
+		{ExpectationFunc("anything", func(CmdResult) error { return nil }), plain, "anything", ""},
+	}
+	for _, test := range tests {
+		if got := test.expectation.String(); got != test.description {
+			t.Errorf("expected the description %q, got %q", test.description, got)
+		}
+		err := test.expectation.Check(test.result)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: expected %s to be met, got %s", test.description, test.result, err)
+		case test.err != "" && err == nil:
+			t.Errorf("%s: expected %s not to be met", test.description, test.result)
+		case test.err != "" && !strings.HasSuffix(err.Error(), test.err):
+			t.Errorf("%s: expected the error %q, got %q", test.description, test.err, err)
+		}
+	}
+}
+
+func TestMeetExpectation(t *testing.T) {
+	result := CmdResult{Out: []byte("deployed"), Err: []byte("fatal: denied"), ExitCode: 1}
+	tests := []struct {
+		expectation Expectation
+		actual      interface{}
+		matches     bool
+		// message is the failure message if the match succeeds or fails unexpectedly.
+		message string
+	}{
+		{ExitCodeIn(1), result, true,
+			"Expected\n    <string>: [Out: 'deployed', Err: 'fatal: denied', ExitCode: '1']\nnot to meet exit code in [1]"},
+		{ExitCodeIn(0), result, false,
+			"Expected\n    <string>: [Out: 'deployed', Err: 'fatal: denied', ExitCode: '1']\nto meet exit code in [0], but exit code 1 is not in [0]"},
+		{AllOf(OutContains("deployed"), ErrContains("accepted")), result, false,
+			"to meet (stdout containing \"deployed\" and stderr containing \"accepted\"), but stderr does not contain \"accepted\""},
+	}
+	for _, test := range tests {
+		matcher := MeetExpectation(test.expectation)
+		matches, err := matcher.Match(test.actual)
+		if err != nil {
+			t.Fatalf("%s: %s", test.expectation, err)
+		}
+		if matches != test.matches {
+			t.Errorf("%s: expected a match to be %t, got %t", test.expectation, test.matches, matches)
+		}
+		message := matcher.FailureMessage(test.actual)
+		if test.matches {
+			message = matcher.NegatedFailureMessage(test.actual)
+		}
+		if !strings.Contains(message, test.message) {
+			t.Errorf("%s: expected the message %q, got %q", test.expectation, test.message, message)
+		}
+	}
+
+	if _, err := MeetExpectation(ExitCodeIn(0)).Match("deployed"); err == nil || !strings.Contains(err.Error(), "expects a CmdResult or *gexec.Session") {
+		t.Errorf("expected a string to be rejected, got %v", err)
+	}
+}
+
+func TestMeetExpectationWithSession(t *testing.T) {
+	sess, err := gexec.Start(exec.Command("/bin/sh", "-c", "echo deployed; echo 'fatal: denied' >&2; exit 3"), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-sess.Exited
+	if ok, err := MeetExpectation(AllOf(OutContains("deployed"), ErrContains("denied"), ExitCodeIn(3))).Match(sess); !ok || err != nil {
+		t.Errorf("expected the session's output and exit code to be checked, got %t (%v)", ok, err)
+	}
+
+	running, err := gexec.Start(exec.Command("sleep", "10"), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer running.Kill()
+	if _, err := MeetExpectation(ExitCodeIn(0)).Match(running); err == nil || !strings.Contains(err.Error(), "requires the session to have exited") {
+		t.Errorf("expected a running session to be rejected, got %v", err)
+	}
+}
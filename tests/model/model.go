@@ -3,13 +3,12 @@ package model
 import (
 	"bytes"
 	"fmt"
-	"math/rand"
-	"path"
-	"runtime"
 	"strings"
+	"time"
 
+	"github.com/deis/workflow-e2e/tests/naming"
+	"github.com/deis/workflow-e2e/tests/resolver"
 	"github.com/deis/workflow-e2e/tests/settings"
-	"github.com/deis/workflow-e2e/tests/util"
 )
 
 var Admin = User{
@@ -27,11 +26,11 @@ type User struct {
 }
 
 func NewUser() User {
-	randSuffix := rand.Intn(999999999)
+	username := naming.New("test")
 	return User{
-		Username:    fmt.Sprintf("test-%d", randSuffix),
+		Username:    username,
 		Password:    "asdf1234",
-		Email:       fmt.Sprintf("test-%d@deis.io", randSuffix),
+		Email:       fmt.Sprintf("%s@deis.io", username),
 		IsSuperuser: false,
 	}
 }
@@ -42,15 +41,15 @@ type App struct {
 }
 
 func NewApp() App {
-	name := fmt.Sprintf("test-%d", rand.Intn(999999999))
+	name := naming.New("test")
 	app := App{
 		Name: name,
 		URL:  strings.Replace(settings.DeisControllerURL, "deis", name, 1),
 	}
-	// try adding the URL to /etc/hosts but don't cry if it's not in there because the user may
+	// try making the URL resolve to the router but don't cry if it doesn't because the user may
 	// have other plans in store for DNS
-	if err := util.AddToEtcHosts(fmt.Sprintf("%s.%s", name, settings.DeisRootHostname)); err != nil {
-		fmt.Printf("WARNING: could not write %s to /etc/hosts (%s), continuing anyways\n",
+	if err := resolver.Register(fmt.Sprintf("%s.%s", name, settings.DeisRootHostname)); err != nil {
+		fmt.Printf("WARNING: could not resolve %s to the router (%s), continuing anyways\n",
 			app.URL,
 			err)
 	}
@@ -68,22 +67,63 @@ type Cert struct {
 	KeyPath  string
 }
 
-func NewCert() Cert {
-	certPath := path.Join(getDir(), "..", "files", "certs")
-	return Cert{
-		Name:     getRandCertName(),
-		CertPath: fmt.Sprintf("%s/www.foo.com.cert", certPath),
-		KeyPath:  fmt.Sprintf("%s/www.foo.com.key", certPath),
-	}
+// CertInfo describes a cert as `deis certs:info` prints it. Domains are those the cert is
+// attached to.
+type CertInfo struct {
+	Name        string
+	CommonName  string
+	SANs        []string
+	Starts      time.Time
+	Expires     time.Time
+	Fingerprint string
+	Issuer      string
+	Subject     string
+	Domains     []string
+	Owner       string
+}
+
+// Release is a release of an app, as `deis releases:info` describes it. Build and Config are the
+// UUIDs of the build and config the release deploys; a rollback reuses those of the release it
+// rolls back to.
+type Release struct {
+	Version int
+	Owner   string
+	Summary string
+	Build   string
+	Config  string
+	UUID    string
+	Created time.Time
+	Updated time.Time
+}
+
+// String returns the release as `deis releases:list` names it, e.g. "v2".
+func (r Release) String() string {
+	return fmt.Sprintf("v%d", r.Version)
+}
+
+// Process is a process of an app, as `deis ps:list` describes it. State is e.g. "up", "starting"
+// or "crashed", and Release is the version of the release the process runs.
+type Process struct {
+	Name    string
+	Type    string
+	State   string
+	Release int
+	Started time.Time
 }
 
-func getRandCertName() string {
-	return fmt.Sprintf("%d-cert", rand.Intn(999999999))
+// Key is an SSH key pair generated for a user. The private key is at Path and the public key next
+// to it with a .pub extension. Name is the ID the key is registered under, which `deis keys:add`
+// takes from the name of the file. Type is the type of key, "rsa" or "ed25519".
+type Key struct {
+	Name  string
+	Type  string
+	Owner string
+	Path  string
 }
 
-func getDir() string {
-	_, filename, _, _ := runtime.Caller(1)
-	return path.Dir(filename)
+// PublicPath returns the path of the public half of the key.
+func (k Key) PublicPath() string {
+	return k.Path + ".pub"
 }
 
 // CmdResult represents a generic command result, with expected Out, Err and
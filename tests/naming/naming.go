@@ -0,0 +1,73 @@
+package naming
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"regexp"
+	"sync/atomic"
+
+	"github.com/onsi/ginkgo/config"
+)
+
+// The functions in this file generate the names of everything the tests create on the
+// controller. Every name embeds the ID of the run (RunID), the number of the Ginkgo node
+// that created it and a counter private to that node, so names cannot collide between nodes or
+// between runs sharing a cluster, and everything belonging to one run can be found again by its
+// run ID.
+
+// This package depends on no other package of the suite, and nothing in it has side effects, so
+// that tools such as cmd/workflow-e2e-cleanup can match names without setting up a run.
+
+// RunIDPattern is the regular expression a run ID must match. Run IDs are embedded in the names
+// of apps, so they are kept short and restricted to characters that are valid in an app name.
+const RunIDPattern = `[a-z0-9]{1,12}`
+
+var runIDRegexp = regexp.MustCompile("^" + RunIDPattern + "$")
+
+// RunID identifies this run of the suite in the names of everything it creates. It is set up by
+// tests/settings, and then by the suite to the run ID chosen by the first Ginkgo node.
+var RunID string
+
+// NewRunID returns a random run ID.
+func NewRunID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatalf("Could not generate a run ID (%s)", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// ValidRunID reports whether id matches RunIDPattern.
+func ValidRunID(id string) bool {
+	return runIDRegexp.MatchString(id)
+}
+
+// MaxLength is the longest name New will return. Workflow app names become Kubernetes
+// namespaces, which may be no longer than a DNS label.
+const MaxLength = 63
+
+var counter uint64
+
+// New returns a name of the form <prefix>-<run ID>-<node>-<counter>. The prefix must consist of
+// lowercase letters, digits and hyphens, so that the result is a valid app name.
+func New(prefix string) string {
+	n := atomic.AddUint64(&counter, 1)
+	name := fmt.Sprintf("%s-%s-%d-%d", prefix, RunID, config.GinkgoConfig.ParallelNode, n)
+	if len(name) > MaxLength {
+		panic(fmt.Sprintf("generated name %s is longer than %d characters", name, MaxLength))
+	}
+	return name
+}
+
+// Matcher returns a regular expression matching names returned by New for the given prefix,
+// followed by the given suffix. If runID is empty, names from any run match; otherwise only
+// names from that run do.
+func Matcher(prefix, runID, suffix string) *regexp.Regexp {
+	run := RunIDPattern
+	if runID != "" {
+		run = regexp.QuoteMeta(runID)
+	}
+	return regexp.MustCompile(fmt.Sprintf(`^%s-%s-\d+-\d+%s$`, regexp.QuoteMeta(prefix), run, regexp.QuoteMeta(suffix)))
+}
@@ -0,0 +1,133 @@
+package naming
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/onsi/ginkgo/config"
+)
+
+func TestValidRunID(t *testing.T) {
+	tests := []struct {
+		id       string
+		expected bool
+	}{
+		{"3f2a9c1e", true},
+		{"a", true},
+		{"0", true},
+		{"abcdefghijkl", true},
+		{"abcdefghijklm", false},
+		{"", false},
+		{"3F2A9C1E", false},
+		{"run-1", false},
+		{"run_1", false},
+		{"run.1", false},
+		{" abc", false},
+		{"abc\n", false},
+		{".*", false},
+	}
+	for _, test := range tests {
+		if got := ValidRunID(test.id); got != test.expected {
+			t.Errorf("%q: expected %t, got %t", test.id, test.expected, got)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		if id := NewRunID(); !ValidRunID(id) {
+			t.Errorf("expected generated run ID %q to be valid", id)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	defer func(runID string, node int) {
+		RunID = runID
+		config.GinkgoConfig.ParallelNode = node
+	}(RunID, config.GinkgoConfig.ParallelNode)
+	RunID = "abc123"
+	config.GinkgoConfig.ParallelNode = 2
+
+	first, second := New("test"), New("test")
+	if first == second {
+		t.Errorf("expected distinct names, got %s twice", first)
+	}
+	for _, name := range []string{first, second} {
+		if !Matcher("test", "abc123", "").MatchString(name) {
+			t.Errorf("expected %s to match the names of run abc123", name)
+		}
+		if !strings.HasPrefix(name, "test-abc123-2-") {
+			t.Errorf("expected %s to name the run and node", name)
+		}
+	}
+
+	// A name of exactly MaxLength characters is allowed; one more is not.
+	RunID = strings.Repeat("a", 12)
+	fixed := len("-"+RunID+"-2-") + 3
+	long := strings.Repeat("p", MaxLength-fixed)
+	// The counter is at 99, so the next name ends in a three-digit number.
+	counter = 99
+	if name := New(long); len(name) != MaxLength {
+		t.Fatalf("expected a name of %d characters, got %s", MaxLength, name)
+	}
+	counter = 99
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a name longer than %d characters to panic", MaxLength)
+		}
+	}()
+	New(long + "p")
+}
+
+func TestMatcher(t *testing.T) {
+	tests := []struct {
+		prefix, runID, suffix string
+		name                  string
+		expected              bool
+	}{
+		{"test", "abc", "", "test-abc-1-2", true},
+		{"test", "abc", "", "test-abc-12-345", true},
+		{"test", "", "", "test-abc-1-2", true},
+		{"test", "", "", "test-xyz-1-2", true},
+		{"test", "abc", "-cert", "test-abc-1-2-cert", true},
+
+		// Other runs, including those whose ID starts or ends with this one's.
+		{"test", "abc", "", "test-xyz-1-2", false},
+		{"test", "abc", "", "test-abcd-1-2", false},
+		{"test", "abc", "", "test-zabc-1-2", false},
+		{"test", "abcd", "", "test-abc-1-2", false},
+
+		// Other prefixes, and names that merely contain a matching one.
+		{"test", "abc", "", "tests-abc-1-2", false},
+		{"test", "abc", "", "my-test-abc-1-2", false},
+		{"test", "abc", "", "test-abc-1-2-3", false},
+		{"test", "abc", "", "test-abc-1-2x", false},
+		{"test", "", "", "mytest-abc-1-2", false},
+		{"test", "", "", "test-abc-1-2\nbob", false},
+		{"te.t", "", "", "test-abc-1-2", false},
+
+		// The node and counter are numbers, and both are required.
+		{"test", "abc", "", "test-abc-1", false},
+		{"test", "abc", "", "test-abc-x-2", false},
+		{"test", "abc", "", "test-abc--2", false},
+		{"test", "", "", "test--1-2", false},
+		{"test", "", "", "test-ABC-1-2", false},
+		{"test", "", "", "test-abcdefghijklm-1-2", false},
+
+		// The suffix is required when given, and not allowed when not.
+		{"test", "abc", "-cert", "test-abc-1-2", false},
+		{"test", "abc", "-cert", "test-abc-1-2-cert-2", false},
+		{"test", "abc", "-cert", "test-abc-1-2xcert", false},
+		{"test", "abc", "", "test-abc-1-2-cert", false},
+	}
+	for _, test := range tests {
+		matcher := Matcher(test.prefix, test.runID, test.suffix)
+		if got := matcher.MatchString(test.name); got != test.expected {
+			t.Errorf("%s (%s): expected %q to match: %t, got %t", matcher, test.runID, test.name, test.expected, got)
+		}
+	}
+
+	// Run IDs and affixes are matched literally.
+	literal := Matcher("a+b", "c.d", "(e)")
+	if !literal.MatchString("a+b-c.d-1-2(e)") || literal.MatchString("aab-cxd-1-2e") {
+		t.Error("expected the prefix, run ID and suffix to be matched literally")
+	}
+}
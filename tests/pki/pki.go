@@ -0,0 +1,294 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deis/workflow-e2e/tests/model"
+	"github.com/deis/workflow-e2e/tests/naming"
+	"github.com/deis/workflow-e2e/tests/settings"
+)
+
+// The code in this package issues certificates at runtime, so that specs do not depend on
+// checked-in certificates that eventually expire. Every certificate is signed by a throwaway CA
+// that only lives as long as the process; nothing trusts it but the specs that ask for it.
+
+// KeyType is the kind of key a certificate is issued for.
+type KeyType string
+
+// Kinds of key.
+const (
+	RSA   KeyType = "rsa"
+	ECDSA KeyType = "ecdsa"
+)
+
+// DefaultRSABits is the size of RSA keys when Options.Bits is not set.
+const DefaultRSABits = 2048
+
+// Options describe a certificate to issue. Only CommonName is required.
+type Options struct {
+	// CommonName is the subject's common name, e.g. "www.foo.com" or "*.foo.com".
+	CommonName string
+	// DNSNames are the subject alternative names, and default to the common name alone, since
+	// clients no longer fall back to the common name when a certificate has none.
+	DNSNames []string
+	// KeyType defaults to RSA.
+	KeyType KeyType
+	// Bits is the size of an RSA key, and defaults to DefaultRSABits.
+	Bits int
+	// NotBefore defaults to an hour ago, to allow for clock skew.
+	NotBefore time.Time
+	// NotAfter defaults to 30 days after NotBefore.
+	NotAfter time.Time
+}
+
+// Expired returns the options with a validity window that ended yesterday.
+func (o Options) Expired() Options {
+	now := time.Now()
+	o.NotBefore = now.AddDate(0, 0, -30)
+	o.NotAfter = now.AddDate(0, 0, -1)
+	return o
+}
+
+// NotYetValid returns the options with a validity window that starts tomorrow.
+func (o Options) NotYetValid() Options {
+	now := time.Now()
+	o.NotBefore = now.AddDate(0, 0, 1)
+	o.NotAfter = now.AddDate(0, 0, 30)
+	return o
+}
+
+// withDefaults returns the options with unset fields filled in.
+func (o Options) withDefaults() Options {
+	if len(o.DNSNames) == 0 && o.CommonName != "" {
+		o.DNSNames = []string{o.CommonName}
+	}
+	if o.KeyType == "" {
+		o.KeyType = RSA
+	}
+	if o.Bits == 0 {
+		o.Bits = DefaultRSABits
+	}
+	if o.NotBefore.IsZero() {
+		o.NotBefore = time.Now().Add(-time.Hour)
+	}
+	if o.NotAfter.IsZero() {
+		o.NotAfter = o.NotBefore.AddDate(0, 0, 30)
+	}
+	return o
+}
+
+// Leaf is a certificate issued by a CA, with its private key, both PEM-encoded.
+type Leaf struct {
+	Cert    *x509.Certificate
+	CertPEM []byte
+	KeyPEM  []byte
+}
+
+// CA is a certificate authority that issues leaf certificates.
+type CA struct {
+	Cert    *x509.Certificate
+	CertPEM []byte
+	key     crypto.Signer
+}
+
+// NewCA creates a self-signed CA with the given common name, valid for a year.
+func NewCA(commonName string) (*CA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := newSerial()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName, Organization: []string{"Deis Workflow e2e"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &CA{Cert: cert, CertPEM: encode("CERTIFICATE", der), key: key}, nil
+}
+
+var (
+	defaultCA     *CA
+	defaultCAErr  error
+	defaultCAOnce sync.Once
+)
+
+// Default returns the CA that NewCert issues certificates with, creating it the first time it is
+// called.
+func Default() (*CA, error) {
+	defaultCAOnce.Do(func() {
+		defaultCA, defaultCAErr = NewCA(fmt.Sprintf("Deis Workflow e2e CA %s", naming.RunID))
+	})
+	return defaultCA, defaultCAErr
+}
+
+// Pool returns a pool holding only the CA's certificate, for verifying the certificates it issued.
+func (ca *CA) Pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+	return pool
+}
+
+// Issue issues a certificate as described by the options.
+func (ca *CA) Issue(opts Options) (*Leaf, error) {
+	opts = opts.withDefaults()
+	if opts.CommonName == "" {
+		return nil, fmt.Errorf("pki: a certificate needs a common name")
+	}
+
+	var key crypto.Signer
+	var keyPEM []byte
+	var err error
+	switch opts.KeyType {
+	case RSA:
+		var rsaKey *rsa.PrivateKey
+		if rsaKey, err = rsa.GenerateKey(rand.Reader, opts.Bits); err == nil {
+			key, keyPEM = rsaKey, encode("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+		}
+	case ECDSA:
+		var ecKey *ecdsa.PrivateKey
+		if ecKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err == nil {
+			var der []byte
+			if der, err = x509.MarshalECPrivateKey(ecKey); err == nil {
+				key, keyPEM = ecKey, encode("EC PRIVATE KEY", der)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("pki: unknown key type %q", opts.KeyType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := newSerial()
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: opts.CommonName, Organization: []string{"Deis Workflow e2e"}},
+		DNSNames:              opts.DNSNames,
+		NotBefore:             opts.NotBefore,
+		NotAfter:              opts.NotAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Cert, key.Public(), ca.key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &Leaf{Cert: cert, CertPEM: encode("CERTIFICATE", der), KeyPEM: keyPEM}, nil
+}
+
+// NewCert issues a certificate as described by the options and writes it and its key to a new
+// directory in settings.TestRoot. The returned cert has a new name, which ends in "-cert".
+func (ca *CA) NewCert(opts Options) (model.Cert, error) {
+	leaf, err := ca.Issue(opts)
+	if err != nil {
+		return model.Cert{}, err
+	}
+	dir, err := ioutil.TempDir(settings.TestRoot, "cert-")
+	if err != nil {
+		return model.Cert{}, err
+	}
+	cert := model.Cert{
+		Name:     naming.New("test") + "-cert",
+		CertPath: filepath.Join(dir, "cert.pem"),
+		KeyPath:  filepath.Join(dir, "key.pem"),
+	}
+	if err := ioutil.WriteFile(cert.CertPath, leaf.CertPEM, 0644); err != nil {
+		return model.Cert{}, err
+	}
+	if err := ioutil.WriteFile(cert.KeyPath, leaf.KeyPEM, 0600); err != nil {
+		return model.Cert{}, err
+	}
+	return cert, nil
+}
+
+// NewCert issues a certificate with the default CA, as CA.NewCert does.
+func NewCert(opts Options) (model.Cert, error) {
+	ca, err := Default()
+	if err != nil {
+		return model.Cert{}, err
+	}
+	return ca.NewCert(opts)
+}
+
+// ReadCertificate reads the first certificate in the PEM file at path.
+func ReadCertificate(path string) (*x509.Certificate, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(contents)
+	if block == nil {
+		return nil, fmt.Errorf("%s holds no PEM data", path)
+	}
+	if block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("%s holds a %s rather than a certificate", path, block.Type)
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// Fingerprint returns the SHA-256 fingerprint of a certificate as the controller prints it:
+// uppercase hex bytes separated by colons.
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// SortedNames returns a sorted copy of names, such as the subject alternative names of a
+// certificate, so that lists of names can be compared regardless of order.
+func SortedNames(names []string) []string {
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+func newSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+func encode(blockType string, der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+}
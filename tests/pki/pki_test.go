@@ -0,0 +1,235 @@
+package pki
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deis/workflow-e2e/tests/settings"
+)
+
+func TestIssue(t *testing.T) {
+	ca, err := NewCA("test CA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name     string
+		opts     Options
+		verify   string
+		dnsNames []string
+	}{
+		{"RSA", Options{CommonName: "www.foo.com"}, "www.foo.com", []string{"www.foo.com"}},
+		{"ECDSA", Options{CommonName: "www.foo.com", KeyType: ECDSA}, "www.foo.com", []string{"www.foo.com"}},
+		{"wildcard", Options{CommonName: "*.foo.com", KeyType: ECDSA}, "bar.foo.com", []string{"*.foo.com"}},
+		{"SANs", Options{CommonName: "foo.com", DNSNames: []string{"foo.com", "www.foo.com"}, KeyType: ECDSA}, "www.foo.com", []string{"foo.com", "www.foo.com"}},
+	} {
+		leaf, err := ca.Issue(test.opts)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if leaf.Cert.Subject.CommonName != test.opts.CommonName {
+			t.Errorf("%s: expected the common name %s, got %s", test.name, test.opts.CommonName, leaf.Cert.Subject.CommonName)
+		}
+		if !reflect.DeepEqual(leaf.Cert.DNSNames, test.dnsNames) {
+			t.Errorf("%s: expected the SANs %v, got %v", test.name, test.dnsNames, leaf.Cert.DNSNames)
+		}
+		if _, err := leaf.Cert.Verify(x509.VerifyOptions{DNSName: test.verify, Roots: ca.Pool()}); err != nil {
+			t.Errorf("%s: expected the certificate to be valid for %s: %s", test.name, test.verify, err)
+		}
+		if _, err := leaf.Cert.Verify(x509.VerifyOptions{DNSName: "www.bar.com", Roots: ca.Pool()}); err == nil {
+			t.Errorf("%s: expected the certificate not to be valid for www.bar.com", test.name)
+		}
+		if _, err := leaf.Cert.Verify(x509.VerifyOptions{DNSName: test.verify, Roots: x509.NewCertPool()}); err == nil {
+			t.Errorf("%s: expected the certificate not to be trusted without the CA", test.name)
+		}
+
+		// The key must be of the right type and belong to the certificate.
+		pair, err := tls.X509KeyPair(leaf.CertPEM, leaf.KeyPEM)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		switch test.opts.KeyType {
+		case ECDSA:
+			if _, ok := pair.PrivateKey.(*ecdsa.PrivateKey); !ok {
+				t.Errorf("%s: expected an ECDSA key, got %T", test.name, pair.PrivateKey)
+			}
+		default:
+			key, ok := pair.PrivateKey.(*rsa.PrivateKey)
+			if !ok {
+				t.Fatalf("%s: expected an RSA key, got %T", test.name, pair.PrivateKey)
+			}
+			if bits := key.N.BitLen(); bits != DefaultRSABits {
+				t.Errorf("%s: expected a %d-bit key, got %d bits", test.name, DefaultRSABits, bits)
+			}
+		}
+	}
+
+	if _, err := ca.Issue(Options{}); err == nil {
+		t.Error("expected an error issuing a certificate without a common name")
+	}
+	if _, err := ca.Issue(Options{CommonName: "www.foo.com", KeyType: "dsa"}); err == nil {
+		t.Error("expected an error issuing a certificate for an unknown kind of key")
+	}
+}
+
+func TestIssueValidity(t *testing.T) {
+	ca, err := NewCA("test CA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{CommonName: "www.foo.com", KeyType: ECDSA}
+	now := time.Now()
+	for _, test := range []struct {
+		name  string
+		opts  Options
+		valid bool
+	}{
+		{"default", opts, true},
+		{"expired", opts.Expired(), false},
+		{"not yet valid", opts.NotYetValid(), false},
+	} {
+		leaf, err := ca.Issue(test.opts)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		_, err = leaf.Cert.Verify(x509.VerifyOptions{DNSName: "www.foo.com", Roots: ca.Pool(), CurrentTime: now})
+		if test.valid && err != nil {
+			t.Errorf("%s: expected the certificate to be valid now: %s", test.name, err)
+		}
+		if !test.valid {
+			if invalid, ok := err.(x509.CertificateInvalidError); !ok || invalid.Reason != x509.Expired {
+				t.Errorf("%s: expected the certificate to be outside its validity window now, got %v", test.name, err)
+			}
+		}
+	}
+
+	expired := opts.Expired()
+	if !expired.NotAfter.Before(now) || !expired.NotBefore.Before(expired.NotAfter) {
+		t.Errorf("expected an expired window that ended before %s, got %s to %s", now, expired.NotBefore, expired.NotAfter)
+	}
+	notYetValid := opts.NotYetValid()
+	if !notYetValid.NotBefore.After(now) || !notYetValid.NotBefore.Before(notYetValid.NotAfter) {
+		t.Errorf("expected a window that starts after %s, got %s to %s", now, notYetValid.NotBefore, notYetValid.NotAfter)
+	}
+}
+
+func TestNewCert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pki-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(root string) { settings.TestRoot = root }(settings.TestRoot)
+	settings.TestRoot = dir
+
+	ca, err := NewCA("test CA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := ca.NewCert(Options{CommonName: "www.foo.com", KeyType: ECDSA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(cert.Name, "test-") || !strings.HasSuffix(cert.Name, "-cert") {
+		t.Errorf("expected a new name for the cert, got %s", cert.Name)
+	}
+	for _, path := range []string{cert.CertPath, cert.KeyPath} {
+		if !strings.HasPrefix(path, dir+string(os.PathSeparator)) {
+			t.Errorf("expected %s to be written in %s", path, dir)
+		}
+	}
+	pair, err := tls.LoadX509KeyPair(cert.CertPath, cert.KeyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{DNSName: "www.foo.com", Roots: ca.Pool()}); err != nil {
+		t.Errorf("expected the written certificate to be issued by the CA: %s", err)
+	}
+	info, err := os.Stat(cert.KeyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("expected the key to be readable only by its owner, got %s", perm)
+	}
+}
+
+func TestReadCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pki-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ca, err := NewCA("test CA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := ca.Issue(Options{CommonName: "www.foo.com", KeyType: ECDSA})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPath := filepath.Join(dir, "cert.pem")
+	// A chain is read as its first certificate.
+	if err := ioutil.WriteFile(certPath, append(leaf.CertPEM, ca.CertPEM...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := ReadCertificate(certPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cert.Equal(leaf.Cert) {
+		t.Errorf("expected to read the leaf certificate, got %q", cert.Subject.CommonName)
+	}
+
+	keyPath := filepath.Join(dir, "key.pem")
+	if err := ioutil.WriteFile(keyPath, leaf.KeyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{keyPath, filepath.Join(dir, "missing.pem"), filepath.Join(dir)} {
+		if _, err := ReadCertificate(path); err == nil {
+			t.Errorf("expected an error reading a certificate from %s", path)
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	ca, err := NewCA("test CA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(ca.Cert.Raw)
+	digits := strings.ToUpper(hex.EncodeToString(sum[:]))
+	var pairs []string
+	for i := 0; i < len(digits); i += 2 {
+		pairs = append(pairs, digits[i:i+2])
+	}
+	if expected, got := strings.Join(pairs, ":"), Fingerprint(ca.Cert); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestSortedNames(t *testing.T) {
+	names := []string{"www.foo.com", "*.foo.com", "foo.com"}
+	if got := SortedNames(names); !reflect.DeepEqual(got, []string{"*.foo.com", "foo.com", "www.foo.com"}) {
+		t.Errorf("expected the names sorted, got %v", got)
+	}
+	if names[0] != "www.foo.com" {
+		t.Errorf("expected the names to be left alone, got %v", names)
+	}
+}
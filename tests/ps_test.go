@@ -1,18 +1,15 @@
 package tests
 
 import (
-	"fmt"
 	"math/rand"
-	"net/http"
-	"regexp"
-	"sort"
-	"strconv"
 	"time"
 
 	"github.com/deis/workflow-e2e/tests/cmd"
 	"github.com/deis/workflow-e2e/tests/cmd/apps"
 	"github.com/deis/workflow-e2e/tests/cmd/auth"
 	"github.com/deis/workflow-e2e/tests/cmd/builds"
+	"github.com/deis/workflow-e2e/tests/cmd/ps"
+	"github.com/deis/workflow-e2e/tests/http"
 	"github.com/deis/workflow-e2e/tests/model"
 	"github.com/deis/workflow-e2e/tests/settings"
 
@@ -52,22 +49,13 @@ var _ = Describe("deis ps", func() {
 
 			DescribeTable("that user can scale that app up and down",
 				func(scaleTo, respCode int) {
-					sess, err := cmd.Start("deis ps:scale cmd=%d --app=%s", &user, scaleTo, app.Name)
-					Eventually(sess).Should(Say("Scaling processes... but first,"))
-					Eventually(sess, settings.MaxEventuallyTimeout).Should(Say(`done in \d+s`))
-					Eventually(sess).Should(Say("=== %s Processes", app.Name))
-					Expect(err).NotTo(HaveOccurred())
-					Eventually(sess).Should(Exit(0))
+					ps.Scale(user, app, map[string]int{"cmd": scaleTo})
 
-					// test that there are the right number of processes listed
-					procsListing := listProcs(user, app, "").Out.Contents()
-					procs := scrapeProcs(app.Name, procsListing)
-					Expect(procs).To(HaveLen(scaleTo))
+					// test that there are the right number of processes listed; pods scaled down
+					// may still be terminating when ps:scale returns
+					ps.WaitForState(user, app, "cmd", scaleTo)
 
-					// curl the app's root URL and print just the HTTP response code
-					cmdRetryTimeout := 60
-					curlCmd := model.Cmd{CommandLineString: fmt.Sprintf(`curl -sL -w "%%{http_code}\\n" "%s" -o /dev/null`, app.URL)}
-					Eventually(cmd.Retry(curlCmd, strconv.Itoa(respCode), cmdRetryTimeout)).Should(BeTrue())
+					http.Probe{URL: app.URL}.Until(settings.DefaultEventuallyTimeout, http.RespondWith(respCode))
 				},
 				Entry("scales to 1", 1, 200),
 				Entry("scales to 3", 3, 200),
@@ -88,16 +76,10 @@ var _ = Describe("deis ps", func() {
 					// Interrupt and wait for exit.
 					sess = sess.Interrupt().Wait()
 
-					// Ensure the right number of processes listed.
-					Eventually(func() []string {
-						procsListing := listProcs(user, app, "").Out.Contents()
-						return scrapeProcs(app.Name, procsListing)
-					}, settings.MaxEventuallyTimeout).Should(HaveLen(scaleTo))
+					// Ensure the right number of processes are up.
+					ps.WaitForState(user, app, "cmd", scaleTo)
 
-					// curl the app's root URL and print just the HTTP response code
-					cmdRetryTimeout := 60
-					curlCmd := model.Cmd{CommandLineString: fmt.Sprintf(`curl -sL -w "%%{http_code}\\n" "%s" -o /dev/null`, app.URL)}
-					Eventually(cmd.Retry(curlCmd, strconv.Itoa(respCode), cmdRetryTimeout)).Should(BeTrue())
+					http.Probe{URL: app.URL}.Until(settings.DefaultEventuallyTimeout, http.RespondWith(respCode))
 				},
 				Entry("scales to 3", 3, 200),
 				Entry("scales to 0", 0, 503),
@@ -121,9 +103,9 @@ var _ = Describe("deis ps", func() {
 				for i := 0; i < 10; i++ {
 					// start the scale operation. waits until the last scale op has finished
 					stopCh <- struct{}{}
-					resp, err := http.Get(app.URL)
-					Expect(err).To(BeNil())
-					Expect(resp.StatusCode).To(BeEquivalentTo(http.StatusOK))
+					resp := http.Probe{URL: app.URL}.Do()
+					Expect(resp.Err).To(BeNil())
+					Expect(resp).To(http.RespondWith(200))
 				}
 
 				// wait until the goroutine that was scaling the app shuts down. not strictly necessary, just good practice
@@ -132,60 +114,39 @@ var _ = Describe("deis ps", func() {
 
 			DescribeTable("that user can restart that app's processes",
 				func(restart string, scaleTo int, respCode int) {
-					// TODO: need some way to choose between "web" and "cmd" here!
 					// scale the app's processes to the desired number
-					sess, err := cmd.Start("deis ps:scale cmd=%d --app=%s", &user, scaleTo, app.Name)
-
-					Eventually(sess).Should(Say("Scaling processes... but first,"))
-					Eventually(sess, settings.MaxEventuallyTimeout).Should(Say(`done in \d+s`))
-					Eventually(sess).Should(Say("=== %s Processes", app.Name))
-					Expect(err).NotTo(HaveOccurred())
-					Eventually(sess).Should(Exit(0))
-
-					// capture the process names
-					beforeProcs := scrapeProcs(app.Name, sess.Out.Contents())
+					beforeProcs := ps.Names(ps.Scale(user, app, map[string]int{"cmd": scaleTo}))
 
 					// restart the app's process(es)
-					var arg string
+					var target string
 					switch restart {
 					case "all":
-						arg = ""
+						target = ""
 					case "by type":
-						// TODO: need some way to choose between "web" and "cmd" here!
-						arg = "cmd"
+						target = "cmd"
 					case "by wrong type":
-						// TODO: need some way to choose between "web" and "cmd" here!
-						arg = "web"
+						target = "web"
 					case "one":
 						procsLen := len(beforeProcs)
 						Expect(procsLen).To(BeNumerically(">", 0))
-						arg = beforeProcs[rand.Intn(procsLen)]
+						target = beforeProcs[rand.Intn(procsLen)]
 					}
-					sess, err = cmd.Start("deis ps:restart %s --app=%s", &user, arg, app.Name)
-					Eventually(sess).Should(Say("Restarting processes... but first,"))
+					restarted := ps.Restart(user, app, target)
 					if scaleTo == 0 || restart == "by wrong type" {
-						Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("Could not find any processes to restart"))
+						Expect(restarted).To(BeEmpty())
 					} else {
-						Eventually(sess, settings.MaxEventuallyTimeout).Should(Say(`done in \d+s`))
-						Eventually(sess).Should(Say("=== %s Processes", app.Name))
+						Expect(restarted).NotTo(BeEmpty())
 					}
-					Expect(err).NotTo(HaveOccurred())
-					Eventually(sess).Should(Exit(0))
-
-					// capture the process names
-					procsListing := listProcs(user, app, "").Out.Contents()
-					afterProcs := scrapeProcs(app.Name, procsListing)
 
 					// compare the before and after sets of process names
-					Expect(afterProcs).To(HaveLen(scaleTo))
+					afterProcs := ps.Names(ps.WaitForState(user, app, "cmd", scaleTo))
 					if scaleTo > 0 && restart != "by wrong type" {
 						Expect(beforeProcs).NotTo(Equal(afterProcs))
+					} else {
+						Expect(beforeProcs).To(Equal(afterProcs))
 					}
 
-					// curl the app's root URL and print just the HTTP response code
-					cmdRetryTimeout := 60
-					curlCmd := model.Cmd{CommandLineString: fmt.Sprintf(`curl -sL -w "%%{http_code}\\n" "%s" -o /dev/null`, app.URL)}
-					Eventually(cmd.Retry(curlCmd, strconv.Itoa(respCode), cmdRetryTimeout)).Should(BeTrue())
+					http.Probe{URL: app.URL}.Until(settings.DefaultEventuallyTimeout, http.RespondWith(respCode))
 				},
 				Entry("restarts one of 1", "one", 1, 200),
 				Entry("restarts all of 1", "all", 1, 200),
@@ -202,33 +163,56 @@ var _ = Describe("deis ps", func() {
 
 		})
 
+		Context("who owns an existing app that runs web and worker processes", func() {
+
+			var app model.App
+
+			BeforeEach(func() {
+				app = apps.Create(user, "--no-remote")
+				builds.CreateWithProcfile(user, app, procfileImage, map[string]string{
+					"web":    "/bin/boot",
+					"worker": "/bin/boot",
+				})
+			})
+
+			AfterEach(func() {
+				apps.Destroy(user, app)
+			})
+
+			Specify("that user can scale each process type independently", func() {
+				ps.Scale(user, app, map[string]int{"web": 2, "worker": 1})
+				web := ps.WaitForState(user, app, "web", 2)
+				ps.WaitForState(user, app, "worker", 1)
+
+				ps.Scale(user, app, map[string]int{"worker": 3})
+				ps.WaitForState(user, app, "worker", 3)
+				Expect(ps.Names(ps.WaitForState(user, app, "web", 2))).To(Equal(ps.Names(web)))
+
+				ps.Scale(user, app, map[string]int{"worker": 0})
+				ps.WaitForState(user, app, "worker", 0)
+				http.Probe{URL: app.URL}.Until(settings.DefaultEventuallyTimeout, http.RespondWith(200))
+			})
+
+			Specify("that user can restart one process type without touching the other", func() {
+				ps.Scale(user, app, map[string]int{"web": 1, "worker": 2})
+				web := ps.WaitForState(user, app, "web", 1)
+				workers := ps.WaitForState(user, app, "worker", 2)
+
+				restarted := ps.Restart(user, app, "worker")
+				Expect(restarted).To(HaveLen(2))
+				Expect(ps.OfType(restarted, "worker")).To(Equal(restarted))
+
+				Expect(ps.Names(ps.WaitForState(user, app, "worker", 2))).NotTo(Equal(ps.Names(workers)))
+				after := ps.WaitForState(user, app, "web", 1)
+				Expect(ps.Names(after)).To(Equal(ps.Names(web)))
+				Expect(after[0].Started).To(Equal(web[0].Started))
+			})
+
+		})
+
 	})
 
 })
 
-func listProcs(user model.User, app model.App, proctype string) *Session {
-	sess, err := cmd.Start("deis ps:list --app=%s", &user, app.Name)
-	Eventually(sess).Should(Say("=== %s Processes", app.Name))
-	if proctype != "" {
-		Eventually(sess).Should(Say("--- %s:", proctype))
-	}
-	Expect(err).NotTo(HaveOccurred())
-	Eventually(sess).Should(Exit(0))
-	return sess
-}
-
-// scrapeProcs returns the sorted process names for an app from the given output.
-// It matches the current "deis ps" output for a healthy container:
-//   earthy-vocalist-cmd-123456789-1d73e up (v2)
-//   myapp-web-123456789-bujlq up (v16)
-func scrapeProcs(app string, output []byte) []string {
-	procsRegexp := `(%s-[\w-]+) up \(v\d+\)`
-	re := regexp.MustCompile(fmt.Sprintf(procsRegexp, app))
-	found := re.FindAllSubmatch(output, -1)
-	procs := make([]string, len(found))
-	for i := range found {
-		procs[i] = string(found[i][1])
-	}
-	sort.Strings(procs)
-	return procs
-}
+// procfileImage is an image that can run more than one process type.
+const procfileImage = "smothiki/exampleapp:latest"
@@ -0,0 +1,43 @@
+package reaper
+
+import "sync"
+
+var (
+	globalMu sync.Mutex
+	global   *Registry
+)
+
+// Enable opens the registry at the given path and makes it the one that Track and Untrack record
+// into. Until Enable is called, Track and Untrack do nothing.
+func Enable(path string) (*Registry, error) {
+	r, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	global = r
+	return r, nil
+}
+
+// Track adds a resource to the registry passed to Enable, if any.
+func Track(res Resource) error {
+	globalMu.Lock()
+	r := global
+	globalMu.Unlock()
+	if r == nil {
+		return nil
+	}
+	return r.Track(res)
+}
+
+// Untrack removes a resource from the registry passed to Enable, if any.
+func Untrack(res Resource) error {
+	globalMu.Lock()
+	r := global
+	globalMu.Unlock()
+	if r == nil {
+		return nil
+	}
+	return r.Untrack(res)
+}
@@ -0,0 +1,204 @@
+package reaper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/deis/workflow-e2e/tests/api"
+)
+
+// The code in this package keeps a registry of every user, app, domain, cert and key the tests
+// create, and removes entries as the tests delete them. Whatever is still registered when the
+// suite ends, because a spec failed or timed out before it could clean up after itself, is
+// deleted by the reaper. The registry is written to disk after every change, so that resources
+// leaked by a run that was killed outright can be reaped later with cmd/workflow-e2e-reaper.
+
+// Kind is the type of a registered resource.
+type Kind string
+
+// Kinds of resources, in the order in which they are reaped.
+const (
+	Cert   Kind = "cert"
+	Domain Kind = "domain"
+	App    Kind = "app"
+	Key    Kind = "key"
+	User   Kind = "user"
+)
+
+var reapOrder = []Kind{Cert, Domain, App, Key, User}
+
+// Resource identifies something created on the controller by the tests.
+type Resource struct {
+	Kind Kind   `json:"kind"`
+	Name string `json:"name"`
+	// App is the app a domain belongs to; it is empty for every other kind.
+	App string `json:"app,omitempty"`
+}
+
+func (r Resource) String() string {
+	if r.App != "" {
+		return fmt.Sprintf("%s %s of app %s", r.Kind, r.Name, r.App)
+	}
+	return fmt.Sprintf("%s %s", r.Kind, r.Name)
+}
+
+// Registry is a set of resources backed by a file.
+type Registry struct {
+	path      string
+	mu        sync.Mutex
+	resources []Resource
+}
+
+// registryName is the name of the registry of a Ginkgo node, given the run ID and node number.
+const registryName = "e2e-resources-%s-%s.json"
+
+// Path returns the path of the registry that the given Ginkgo node of the given run keeps in dir.
+// Each run has registries of its own, so that runs sharing a $HOME never reap each other's
+// resources.
+func Path(dir, runID string, node int) string {
+	return filepath.Join(dir, fmt.Sprintf(registryName, runID, strconv.Itoa(node)))
+}
+
+// Glob returns the paths of the registries in dir that belong to the given run, or to any run if
+// runID is empty.
+func Glob(dir, runID string) ([]string, error) {
+	if runID == "" {
+		runID = "*"
+	}
+	return filepath.Glob(filepath.Join(dir, fmt.Sprintf(registryName, runID, "*")))
+}
+
+// Open returns the registry stored at the given path, creating an empty one if the file does not
+// exist yet.
+func Open(path string) (*Registry, error) {
+	r := &Registry{path: path}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &r.resources); err != nil {
+		return nil, fmt.Errorf("could not parse %s (%s)", path, err)
+	}
+	return r, nil
+}
+
+// Track adds a resource to the registry.
+func (r *Registry) Track(res Resource) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.resources {
+		if existing == res {
+			return nil
+		}
+	}
+	r.resources = append(r.resources, res)
+	return r.save()
+}
+
+// Untrack removes a resource from the registry. Untracking an app also untracks its domains,
+// since they are destroyed along with it.
+func (r *Registry) Untrack(res Resource) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.remove(res)
+	if res.Kind == App {
+		for _, domain := range append([]Resource{}, r.resources...) {
+			if domain.Kind == Domain && domain.App == res.Name {
+				r.remove(domain)
+			}
+		}
+	}
+	return r.save()
+}
+
+// Resources returns the registered resources.
+func (r *Registry) Resources() []Resource {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Resource{}, r.resources...)
+}
+
+// Reap deletes every registered resource using the given client, which must belong to an admin.
+// Certs are deleted before domains, domains before apps, and apps and keys before the users that
+// own them. Resources that turn out to be gone already are simply untracked. Progress is written
+// to out; an error is returned listing every resource that could not be deleted.
+func (r *Registry) Reap(client *api.Client, out io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var failed []string
+	for _, kind := range reapOrder {
+		for _, res := range append([]Resource{}, r.resources...) {
+			if res.Kind != kind {
+				continue
+			}
+			if err := del(client, res); err != nil && !api.IsNotFound(err) {
+				fmt.Fprintf(out, "could not reap %s (%s)\n", res, err)
+				failed = append(failed, res.String())
+				continue
+			}
+			fmt.Fprintf(out, "reaped %s\n", res)
+			r.remove(res)
+		}
+	}
+	if err := r.save(); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("could not reap %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func del(client *api.Client, res Resource) error {
+	// An empty name would address the client's own account when cancelling a user.
+	if res.Name == "" {
+		return fmt.Errorf("%s has no name", res.Kind)
+	}
+	switch res.Kind {
+	case Cert:
+		return client.DeleteCert(res.Name)
+	case Domain:
+		return client.DeleteDomain(res.App, res.Name)
+	case App:
+		return client.DeleteApp(res.Name)
+	case Key:
+		return client.DeleteKey(res.Name)
+	case User:
+		return client.Cancel(res.Name)
+	}
+	return fmt.Errorf("unknown kind %q", res.Kind)
+}
+
+func (r *Registry) remove(res Resource) {
+	for i, existing := range r.resources {
+		if existing == res {
+			r.resources = append(r.resources[:i], r.resources[i+1:]...)
+			return
+		}
+	}
+}
+
+// save writes the registry to its file, or removes the file once the registry is empty.
+func (r *Registry) save() error {
+	if len(r.resources) == 0 {
+		if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(r.resources, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.path, data, 0644)
+}
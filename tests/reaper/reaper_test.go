@@ -0,0 +1,309 @@
+// The fake controller depends on this package, through tests/cmd, so these tests live outside of
+// it.
+package reaper_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/deis/workflow-e2e/tests/api"
+	"github.com/deis/workflow-e2e/tests/fake"
+	"github.com/deis/workflow-e2e/tests/reaper"
+)
+
+const publicKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIHkyLzaV5kBqbsz8ogyAoOBEEGGuyLiBMw0QQB0y4BVj bob"
+
+// recorder passes requests through to a fake controller, recording the deletions.
+type recorder struct {
+	mu        sync.Mutex
+	deletions []string
+	proxy     *httputil.ReverseProxy
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method == "DELETE" {
+		r.mu.Lock()
+		r.deletions = append(r.deletions, req.URL.Path)
+		r.mu.Unlock()
+	}
+	r.proxy.ServeHTTP(w, req)
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "reaper-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestReap(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	controller := fake.NewController()
+	defer controller.Close()
+	target, err := url.Parse(controller.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &recorder{proxy: httputil.NewSingleHostReverseProxy(target)}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	if _, err := api.NewClient(server.URL).Register("admin", "admin", "admin@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	admin, err := api.Login(server.URL, "admin", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.Register("bob", "bob", "bob@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	bob, err := api.Login(server.URL, "bob", "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bob.CreateApp("bobs-app"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bob.CreateKey("bobs-key", publicKey); err != nil {
+		t.Fatal(err)
+	}
+
+	// Track everything in the opposite order to the one it must be reaped in. The user cannot be
+	// cancelled while it owns the app, and the cert is already gone.
+	registry, err := reaper.Open(reaper.Path(dir, "abc123", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, res := range []reaper.Resource{
+		{Kind: reaper.User, Name: "bob"},
+		{Kind: reaper.Key, Name: "bobs-key"},
+		{Kind: reaper.App, Name: "bobs-app"},
+		{Kind: reaper.Domain, Name: "bobs-app", App: "bobs-app"},
+		{Kind: reaper.Cert, Name: "bobs-cert"},
+	} {
+		if err := registry.Track(res); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var out bytes.Buffer
+	if err := registry.Reap(admin, &out); err != nil {
+		t.Fatalf("%s\n%s", err, out.String())
+	}
+
+	expected := []string{
+		"/v2/certs/bobs-cert/",
+		"/v2/apps/bobs-app/domains/bobs-app/",
+		"/v2/apps/bobs-app/",
+		"/v2/keys/bobs-key/",
+		"/v2/auth/cancel/",
+	}
+	if !reflect.DeepEqual(rec.deletions, expected) {
+		t.Errorf("expected deletions %v, got %v", expected, rec.deletions)
+	}
+	if !strings.Contains(out.String(), "reaped cert bobs-cert\n") || !strings.HasSuffix(out.String(), "reaped user bob\n") {
+		t.Errorf("expected the cert and the user to be reaped, got %q", out.String())
+	}
+	if users, err := admin.Users(); err != nil || len(users) != 1 {
+		t.Errorf("expected only the admin to be left, got %v (%v)", users, err)
+	}
+	if resources := registry.Resources(); len(resources) != 0 {
+		t.Errorf("expected nothing to be left to reap, got %v", resources)
+	}
+	if _, err := os.Stat(reaper.Path(dir, "abc123", 1)); !os.IsNotExist(err) {
+		t.Errorf("expected an empty registry to be removed, got %v", err)
+	}
+}
+
+func TestReapFailures(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	controller := fake.NewController()
+	defer controller.Close()
+	if _, err := api.NewClient(controller.URL).Register("admin", "admin", "admin@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	admin, err := api.Login(controller.URL, "admin", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.CreateApp("admins-app"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The admin still owns an app the registry does not know of, so it cannot be cancelled, and
+	// a user without a name must never be cancelled, as that would cancel the admin.
+	path := reaper.Path(dir, "abc123", 1)
+	registry, err := reaper.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failures := []reaper.Resource{{Kind: reaper.User, Name: "admin"}, {Kind: reaper.User}}
+	for _, res := range append([]reaper.Resource{{Kind: reaper.App, Name: "gone"}}, failures...) {
+		if err := registry.Track(res); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var out bytes.Buffer
+	err = registry.Reap(admin, &out)
+	if err == nil || err.Error() != "could not reap user admin, user " {
+		t.Errorf("expected both users to fail, got %v", err)
+	}
+	if !strings.Contains(out.String(), "reaped app gone\n") || !strings.Contains(out.String(), "could not reap user  (user has no name)\n") {
+		t.Errorf("expected the missing app to be reaped and the nameless user to fail, got %q", out.String())
+	}
+	if _, err := admin.Whoami(); err != nil {
+		t.Errorf("expected the admin to survive, got %s", err)
+	}
+
+	// What could not be reaped is kept for the next attempt.
+	reopened, err := reaper.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(reopened.Resources(), failures) {
+		t.Errorf("expected %v to be left, got %v", failures, reopened.Resources())
+	}
+}
+
+func TestUntrackApp(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	registry, err := reaper.Open(reaper.Path(dir, "abc123", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, res := range []reaper.Resource{
+		{Kind: reaper.App, Name: "app-1"},
+		{Kind: reaper.Domain, Name: "www.example.com", App: "app-1"},
+		{Kind: reaper.App, Name: "app-2"},
+		{Kind: reaper.Domain, Name: "api.example.com", App: "app-2"},
+		{Kind: reaper.Domain, Name: "app.example.com", App: "app-1"},
+		{Kind: reaper.Cert, Name: "app-1"},
+	} {
+		if err := registry.Track(res); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Untracking an app takes its domains with it, and nothing else that shares its name.
+	if err := registry.Untrack(reaper.Resource{Kind: reaper.App, Name: "app-1"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []reaper.Resource{
+		{Kind: reaper.App, Name: "app-2"},
+		{Kind: reaper.Domain, Name: "api.example.com", App: "app-2"},
+		{Kind: reaper.Cert, Name: "app-1"},
+	}
+	if !reflect.DeepEqual(registry.Resources(), expected) {
+		t.Errorf("expected %v, got %v", expected, registry.Resources())
+	}
+
+	// Untracking a domain leaves its app alone, and untracking something untracked does nothing.
+	if err := registry.Untrack(reaper.Resource{Kind: reaper.Domain, Name: "api.example.com", App: "app-2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Untrack(reaper.Resource{Kind: reaper.Key, Name: "app-2"}); err != nil {
+		t.Fatal(err)
+	}
+	expected = []reaper.Resource{{Kind: reaper.App, Name: "app-2"}, {Kind: reaper.Cert, Name: "app-1"}}
+	if !reflect.DeepEqual(registry.Resources(), expected) {
+		t.Errorf("expected %v, got %v", expected, registry.Resources())
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := reaper.Path(dir, "abc123", 2)
+	if path != filepath.Join(dir, "e2e-resources-abc123-2.json") {
+		t.Errorf("expected the registry to be named after the run and node, got %s", path)
+	}
+
+	registry, err := reaper.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resources := registry.Resources(); len(resources) != 0 {
+		t.Errorf("expected a new registry to be empty, got %v", resources)
+	}
+	tracked := []reaper.Resource{
+		{Kind: reaper.User, Name: "bob"},
+		{Kind: reaper.Domain, Name: "www.example.com", App: "bobs-app"},
+	}
+	for _, res := range append(tracked, tracked[0]) {
+		if err := registry.Track(res); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Every change is saved, so a registry left behind by a killed run loads as it was.
+	reopened, err := reaper.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(reopened.Resources(), tracked) {
+		t.Errorf("expected %v, got %v", tracked, reopened.Resources())
+	}
+
+	if err := ioutil.WriteFile(path, []byte("not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reaper.Open(path); err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("expected a malformed registry not to open, got %v", err)
+	}
+}
+
+func TestGlob(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	for _, name := range []string{
+		"e2e-resources-abc-1.json",
+		"e2e-resources-abc-2.json",
+		"e2e-resources-abcd-1.json",
+		"e2e-resources-xyz-1.json",
+		"e2e-resources.json",
+		"other.json",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("[]"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		runID    string
+		expected []string
+	}{
+		{"abc", []string{"e2e-resources-abc-1.json", "e2e-resources-abc-2.json"}},
+		{"xyz", []string{"e2e-resources-xyz-1.json"}},
+		{"nope", nil},
+		{"", []string{"e2e-resources-abc-1.json", "e2e-resources-abc-2.json", "e2e-resources-abcd-1.json", "e2e-resources-xyz-1.json"}},
+	}
+	for _, test := range tests {
+		paths, err := reaper.Glob(dir, test.runID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, path := range paths {
+			names = append(names, filepath.Base(path))
+		}
+		sort.Strings(names)
+		if !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("%q: expected %v, got %v", test.runID, test.expected, names)
+		}
+	}
+}
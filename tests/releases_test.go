@@ -1,17 +1,17 @@
 package tests
 
 import (
-	"github.com/deis/workflow-e2e/tests/cmd"
+	"fmt"
+
 	"github.com/deis/workflow-e2e/tests/cmd/apps"
 	"github.com/deis/workflow-e2e/tests/cmd/auth"
 	"github.com/deis/workflow-e2e/tests/cmd/builds"
+	"github.com/deis/workflow-e2e/tests/cmd/configs"
+	"github.com/deis/workflow-e2e/tests/cmd/releases"
 	"github.com/deis/workflow-e2e/tests/model"
-	"github.com/deis/workflow-e2e/tests/settings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
-	. "github.com/onsi/gomega/gbytes"
-	. "github.com/onsi/gomega/gexec"
 )
 
 var _ = Describe("deis releases", func() {
@@ -42,47 +42,51 @@ var _ = Describe("deis releases", func() {
 			})
 
 			Specify("that user can list that app's releases", func() {
-				sess, err := cmd.Start("deis releases:list -a %s", &user, app.Name)
-				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Releases", app.Name))
-				Eventually(sess).Should(Say(`v1\s+.*\s+%s created initial release`, user.Username))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
+				list := releases.List(user, app)
+				Expect(list).To(HaveLen(2))
+				Expect(list[0].Version).To(Equal(2))
+				Expect(list[1].Version).To(Equal(1))
+				Expect(list[1].Summary).To(Equal(fmt.Sprintf("%s created initial release", user.Username)))
+				Expect(list[0].Created).NotTo(BeTemporally("<", list[1].Created))
 			})
 
 			Specify("that user can get info on one of the app's releases", func() {
-				sess, err := cmd.Start("deis releases:info v2 -a %s", &user, app.Name)
-				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Release v2", app.Name))
-				Eventually(sess).Should(Say(`config:\s+[\w-]+`))
-				Eventually(sess).Should(Say(`owner:\s+%s`, user.Username))
-				Eventually(sess).Should(Say(`summary:\s+%s \w+`, user.Username))
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
+				release := releases.Info(user, app, 2)
+				Expect(release.Version).To(Equal(2))
+				Expect(release.Owner).To(Equal(user.Username))
+				Expect(release.Summary).To(MatchRegexp(`^%s \w+`, user.Username))
+				Expect(release.Build).NotTo(BeEmpty())
+				Expect(release.Config).To(MatchRegexp(`^[\w-]+$`))
+				Expect(release.UUID).To(MatchRegexp(`^[0-9a-f\-]+$`))
+				Expect(release.Created.IsZero()).To(BeFalse())
+				Expect(release.Updated.IsZero()).To(BeFalse())
 			})
 
-			Context("and that app has three releases", func() {
+			Context("and that app has since been reconfigured and rebuilt", func() {
+
+				var target releases.Snapshot
 
 				BeforeEach(func() {
+					target = releases.Take(user, app)
+					configs.Set(user, app, "ROLLBACK_TEST", "changed")
 					builds.Create(user, app)
 				})
 
 				Specify("that user can roll the application back to the second release", func() {
-					sess, err := cmd.Start("deis releases:rollback v2 -a %s", &user, app.Name)
-					Eventually(sess).Should(Say(`Rolling back to`))
-					Eventually(sess, settings.MaxEventuallyTimeout).Should(Say(`...done`))
-					Expect(err).NotTo(HaveOccurred())
-					Eventually(sess).Should(Exit(0))
-
-					sess, err = cmd.Start("deis releases:info v2 -a %s", &user, app.Name)
-					Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Release v2", app.Name))
-					Eventually(sess).Should(Say(`config:\s+[\w-]+`))
-					Eventually(sess).Should(Say(`owner:\s+%s`, user.Username))
-					Eventually(sess).Should(Say(`summary:\s+%s \w+`, user.Username))
-
-					// The updated date has to match a string like 2015-12-22T21:20:31Z:
-					Eventually(sess).Should(Say(`updated:\s+[\w\-\:]+Z`))
-					Eventually(sess).Should(Say(`uuid:\s+[0-9a-f\-]+`))
-					Expect(err).NotTo(HaveOccurred())
-					Eventually(sess).Should(Exit(0))
+					latest := releases.Latest(user, app)
+					Expect(latest.Version).To(Equal(4))
+					Expect(latest.Config).NotTo(Equal(target.Release.Config))
+
+					release := releases.ExpectRollback(user, app, target)
+					Expect(release.Version).To(Equal(5))
+				})
+
+				Specify("that user can roll the application back more than once", func() {
+					releases.ExpectRollback(user, app, target)
+					third := releases.Info(user, app, 3)
+					Expect(third.Summary).To(ContainSubstring("ROLLBACK_TEST"))
+
+					releases.ExpectRollback(user, app, target)
 				})
 
 			})
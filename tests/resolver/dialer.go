@@ -0,0 +1,58 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// dialer resolves hostnames under the default root hostname to the router in-process, and tells
+// curl to do the same. Nothing outside this process, such as the deis CLI or ssh, can resolve
+// them, so DEIS_CONTROLLER_URL must be set to an address those can reach.
+type dialer struct {
+	routerHost string
+}
+
+func (d *dialer) RootHostname() string {
+	return DefaultRootHostname
+}
+
+func (d *dialer) Register(hostname string) error {
+	if d.routerHost == "" {
+		return errNoRouterHost
+	}
+	return nil
+}
+
+func (d *dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if host, port, err := net.SplitHostPort(addr); err == nil && d.routerHost != "" && isUnder(host, DefaultRootHostname) {
+		addr = net.JoinHostPort(d.routerHost, port)
+	}
+	return netDialer.DialContext(ctx, network, addr)
+}
+
+// CurlFlags resolves the URL's host on its own port and, so that redirects between HTTP and HTTPS
+// can be followed, on the standard ones too.
+func (d *dialer) CurlFlags(rawURL string) string {
+	host, port, err := hostAndPort(rawURL)
+	if err != nil || d.routerHost == "" || !isUnder(host, DefaultRootHostname) {
+		return ""
+	}
+	var flags []string
+	for _, p := range []string{port, "80", "443"} {
+		flag := fmt.Sprintf("--resolve %s:%s:%s", host, p, d.routerHost)
+		if p != port || len(flags) == 0 {
+			flags = append(flags, flag)
+		}
+	}
+	return strings.Join(flags, " ")
+}
+
+func (d *dialer) AdoptRunID() error {
+	return nil
+}
+
+func (d *dialer) Cleanup() error {
+	return nil
+}
@@ -0,0 +1,183 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/deis/workflow-e2e/tests/naming"
+)
+
+// hostsMarker, followed by the run ID, ends every line etcHosts adds, so that Cleanup can find the
+// lines added during a run again without touching those of other runs on the same machine.
+const hostsMarker = "# workflow-e2e"
+
+// etcHosts aliases hostnames to the router in a hosts file.
+type etcHosts struct {
+	path       string
+	routerHost string
+	mu         sync.Mutex
+	// runIDs are the run IDs lines have been added under by this process.
+	runIDs map[string]bool
+}
+
+func marker(runID string) string {
+	return hostsMarker + " " + runID
+}
+
+func (h *etcHosts) RootHostname() string {
+	return DefaultRootHostname
+}
+
+func (h *etcHosts) Register(hostname string) error {
+	if h.routerHost == "" {
+		return errNoRouterHost
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	line := fmt.Sprintf("%s\t%s\t%s", h.routerHost, hostname, marker(naming.RunID))
+	if h.runIDs == nil {
+		h.runIDs = map[string]bool{}
+	}
+	h.runIDs[naming.RunID] = true
+	f, err := h.lock(os.O_CREATE)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	for _, existing := range strings.Split(string(data), "\n") {
+		if existing == line {
+			return nil
+		}
+	}
+	// Reading left the offset at the end of the file, which is where the line goes.
+	_, err = f.WriteString(line + "\n")
+	return err
+}
+
+func (h *etcHosts) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return netDialer.DialContext(ctx, network, addr)
+}
+
+func (h *etcHosts) CurlFlags(rawURL string) string {
+	return ""
+}
+
+// AdoptRunID rewrites the lines this process added under other run IDs to carry the current one,
+// dropping those that another process has already added under it.
+func (h *etcHosts) AdoptRunID() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stale := false
+	for runID := range h.runIDs {
+		stale = stale || runID != naming.RunID
+	}
+	if !stale {
+		return nil
+	}
+	current := marker(naming.RunID)
+	err := h.rewrite(func(lines []string) []string {
+		present := map[string]bool{}
+		for _, line := range lines {
+			present[line] = true
+		}
+		var kept []string
+		for _, line := range lines {
+			for runID := range h.runIDs {
+				if runID != naming.RunID && strings.HasSuffix(line, marker(runID)) {
+					line = strings.TrimSuffix(line, marker(runID)) + current
+					if present[line] {
+						line = ""
+					}
+					present[line] = true
+					break
+				}
+			}
+			if line != "" {
+				kept = append(kept, line)
+			}
+		}
+		return kept
+	})
+	if err != nil {
+		return err
+	}
+	h.runIDs = map[string]bool{naming.RunID: true}
+	return nil
+}
+
+// Cleanup removes every line added by Register during the current run from the hosts file,
+// whichever process added it.
+func (h *etcHosts) Cleanup() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	current := marker(naming.RunID)
+	return h.rewrite(func(lines []string) []string {
+		var kept []string
+		for _, line := range lines {
+			if !strings.HasSuffix(line, current) {
+				kept = append(kept, line)
+			}
+		}
+		return kept
+	})
+}
+
+// rewrite replaces the lines of the hosts file with those edit returns, if they differ. The file
+// is rewritten in place rather than replaced, since /etc/hosts is often a bind mount.
+func (h *etcHosts) rewrite(edit func(lines []string) []string) error {
+	f, err := h.lock(0)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	edited := edit(lines)
+	if strings.Join(edited, "\n") == strings.Join(lines, "\n") {
+		return nil
+	}
+	contents := strings.Join(edited, "\n")
+	if len(edited) > 0 {
+		contents += "\n"
+	}
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err = f.WriteAt([]byte(contents), 0)
+	return err
+}
+
+// lock opens the hosts file for reading and writing, with the given extra flags, and takes an
+// exclusive lock on it, which is released when the file is closed. Every Ginkgo node edits the
+// file, so it is only ever read or written under the lock; otherwise a node rewriting the file
+// could drop the lines another node appends meanwhile.
+func (h *etcHosts) lock(flag int) (*os.File, error) {
+	f, err := os.OpenFile(h.path, os.O_RDWR|flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,185 @@
+package resolver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/deis/workflow-e2e/tests/naming"
+)
+
+// hostsFile creates a hosts file holding the given lines in a new directory.
+func hostsFile(t *testing.T, lines ...string) (string, func()) {
+	dir, err := ioutil.TempDir("", "resolver-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "hosts")
+	if lines != nil {
+		if err := ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func readLines(t *testing.T, path string) []string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func withRunID(runID string) func() {
+	previous := naming.RunID
+	naming.RunID = runID
+	return func() { naming.RunID = previous }
+}
+
+func TestEtcHostsRegister(t *testing.T) {
+	defer withRunID("abc")()
+	path, remove := hostsFile(t)
+	defer remove()
+
+	if err := (&etcHosts{path: path}).Register("www.k8s.local"); err != errNoRouterHost {
+		t.Errorf("expected registering without a router host to fail, got %v", err)
+	}
+	h := &etcHosts{path: path, routerHost: "192.0.2.10"}
+	for _, hostname := range []string{"www.k8s.local", "api.k8s.local", "www.k8s.local"} {
+		if err := h.Register(hostname); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := []string{
+		"192.0.2.10\twww.k8s.local\t# workflow-e2e abc",
+		"192.0.2.10\tapi.k8s.local\t# workflow-e2e abc",
+	}
+	if got := readLines(t, path); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestEtcHostsAdoptRunIDAndCleanup(t *testing.T) {
+	restore := withRunID("")
+	defer restore()
+	path, remove := hostsFile(t,
+		"127.0.0.1\tlocalhost",
+		"192.0.2.10\tapi.k8s.local\t# workflow-e2e xyz",
+		"192.0.2.10\twww.k8s.local\t# workflow-e2e abc",
+	)
+	defer remove()
+
+	// A node registers hostnames before it learns the run ID, including one another node has
+	// already registered under it.
+	h := &etcHosts{path: path, routerHost: "192.0.2.10"}
+	for _, hostname := range []string{"www.k8s.local", "app.k8s.local"} {
+		if err := h.Register(hostname); err != nil {
+			t.Fatal(err)
+		}
+	}
+	naming.RunID = "abc"
+	if err := h.AdoptRunID(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"127.0.0.1\tlocalhost",
+		"192.0.2.10\tapi.k8s.local\t# workflow-e2e xyz",
+		"192.0.2.10\twww.k8s.local\t# workflow-e2e abc",
+		"192.0.2.10\tapp.k8s.local\t# workflow-e2e abc",
+	}
+	if got := readLines(t, path); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+
+	// Cleaning up removes the lines of this run only, whichever node added them.
+	if err := (&etcHosts{path: path}).Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	expected = expected[:2]
+	if got := readLines(t, path); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+
+	// Cleaning up after a run that never registered anything changes nothing.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&etcHosts{path: path}).Cleanup(); err != nil {
+		t.Errorf("expected cleaning up without a hosts file to succeed, got %s", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected cleaning up not to create a hosts file, got %v", err)
+	}
+}
+
+func TestEtcHostsConcurrentEdits(t *testing.T) {
+	defer withRunID("abc")()
+	path, remove := hostsFile(t, "127.0.0.1\tlocalhost")
+	defer remove()
+
+	// Several nodes register hostnames while another keeps rewriting the file, as AdoptRunID and
+	// Cleanup do. No line may be lost.
+	const nodes, hostnames = 4, 50
+	var wg, rewriting sync.WaitGroup
+	done := make(chan struct{})
+	rewriter := &etcHosts{path: path}
+	rewriting.Add(1)
+	go func() {
+		defer rewriting.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			comment := fmt.Sprintf("# rewrite %d", i)
+			rewriter.rewrite(func(lines []string) []string { return append(lines, comment) })
+			rewriter.rewrite(func(lines []string) []string {
+				var kept []string
+				for _, line := range lines {
+					if line != comment {
+						kept = append(kept, line)
+					}
+				}
+				return kept
+			})
+		}
+	}()
+	for node := 1; node <= nodes; node++ {
+		wg.Add(1)
+		go func(node int) {
+			defer wg.Done()
+			h := &etcHosts{path: path, routerHost: "192.0.2.10"}
+			for i := 0; i < hostnames; i++ {
+				if err := h.Register(fmt.Sprintf("app-%d-%d.k8s.local", node, i)); err != nil {
+					t.Error(err)
+				}
+			}
+		}(node)
+	}
+	wg.Wait()
+	close(done)
+	rewriting.Wait()
+
+	registered := map[string]bool{}
+	for _, line := range readLines(t, path) {
+		registered[line] = true
+	}
+	for node := 1; node <= nodes; node++ {
+		for i := 0; i < hostnames; i++ {
+			line := fmt.Sprintf("192.0.2.10\tapp-%d-%d.k8s.local\t# workflow-e2e abc", node, i)
+			if !registered[line] {
+				t.Errorf("expected %q to survive", line)
+			}
+		}
+	}
+	if !registered["127.0.0.1\tlocalhost"] {
+		t.Error("expected the existing lines to survive")
+	}
+}
@@ -0,0 +1,153 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// The code in this package decides how the hostnames of the controller, the builder and apps are
+// resolved to the router. The mode is chosen with DEIS_RESOLVER:
+//
+//   hosts    (default) alias each hostname to DEIS_ROUTER_SERVICE_HOST in /etc/hosts; needs root
+//   dialer   resolve hostnames under k8s.local to DEIS_ROUTER_SERVICE_HOST in-process only
+//   wildcard serve everything under <DEIS_ROUTER_SERVICE_HOST>.nip.io (or DEIS_WILDCARD_DNS)
+//
+// Whatever the mode, anything that talks to an app should use Transport, HTTPClient or
+// CurlFlags so that it reaches the router.
+
+// Modes of resolution.
+const (
+	Hosts    = "hosts"
+	Dialer   = "dialer"
+	Wildcard = "wildcard"
+)
+
+// DefaultRootHostname is the domain served by the router unless the wildcard mode is used.
+const DefaultRootHostname = "k8s.local"
+
+var errNoRouterHost = errors.New(`Set the router host and port for tests, such as:
+
+$ DEIS_ROUTER_SERVICE_HOST=192.0.2.10 DEIS_ROUTER_SERVICE_PORT=31182 make test-integration`)
+
+// Resolver makes hostnames under its root hostname resolve to the router.
+type Resolver interface {
+	// RootHostname returns the domain under which the controller, builder and apps are served.
+	RootHostname() string
+	// Register makes the given hostname resolve to the router for every process on this machine,
+	// if the mode requires any work to do so.
+	Register(hostname string) error
+	// DialContext dials addr, sending connections for hostnames under the root hostname to the
+	// router.
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+	// CurlFlags returns the flags curl needs to reach rawURL, if any.
+	CurlFlags(rawURL string) string
+	// AdoptRunID marks everything this process has registered as belonging to the run naming.RunID
+	// now names. Ginkgo nodes register hostnames before they learn the run ID from the first node.
+	AdoptRunID() error
+	// Cleanup undoes everything Register did during the run naming.RunID names, in any process.
+	Cleanup() error
+}
+
+var (
+	// Mode is the mode of resolution in use.
+	Mode = os.Getenv("DEIS_RESOLVER")
+	// Default is the resolver for Mode.
+	Default Resolver
+)
+
+func init() {
+	routerHost := os.Getenv("DEIS_ROUTER_SERVICE_HOST")
+	switch Mode {
+	case "", Hosts:
+		Mode = Hosts
+		Default = &etcHosts{path: "/etc/hosts", routerHost: routerHost}
+	case Dialer:
+		Default = &dialer{routerHost: routerHost}
+	case Wildcard:
+		domain := os.Getenv("DEIS_WILDCARD_DNS")
+		if domain == "" {
+			domain = "nip.io"
+		}
+		if net.ParseIP(routerHost) == nil {
+			log.Fatalf("DEIS_RESOLVER=%s requires DEIS_ROUTER_SERVICE_HOST to be an IP address, not %q", Wildcard, routerHost)
+		}
+		Default = wildcard{root: fmt.Sprintf("%s.%s", routerHost, domain)}
+	default:
+		log.Fatalf("DEIS_RESOLVER must be one of %s, %s or %s, not %q", Hosts, Dialer, Wildcard, Mode)
+	}
+}
+
+// RootHostname returns the root hostname of the default resolver.
+func RootHostname() string {
+	return Default.RootHostname()
+}
+
+// Register registers a hostname with the default resolver.
+func Register(hostname string) error {
+	return Default.Register(hostname)
+}
+
+// DialContext dials addr using the default resolver.
+func DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return Default.DialContext(ctx, network, addr)
+}
+
+// CurlFlags returns the flags curl needs to reach rawURL using the default resolver.
+func CurlFlags(rawURL string) string {
+	return Default.CurlFlags(rawURL)
+}
+
+// AdoptRunID marks everything registered with the default resolver as belonging to the current run.
+func AdoptRunID() error {
+	return Default.AdoptRunID()
+}
+
+// Cleanup undoes everything registered with the default resolver during the current run.
+func Cleanup() error {
+	return Default.Cleanup()
+}
+
+// Transport returns an HTTP transport that dials through the default resolver.
+func Transport() *http.Transport {
+	return &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+}
+
+// HTTPClient returns an HTTP client that dials through the default resolver.
+func HTTPClient() *http.Client {
+	return &http.Client{Transport: Transport()}
+}
+
+var netDialer = &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+
+// isUnder returns true if host is root or a subdomain of it.
+func isUnder(host, root string) bool {
+	return host == root || strings.HasSuffix(host, "."+root)
+}
+
+// hostAndPort returns the hostname of a URL and its port, filling in the default port for the
+// scheme.
+func hostAndPort(rawURL string) (string, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", err
+	}
+	if host, port, err := net.SplitHostPort(u.Host); err == nil {
+		return host, port, nil
+	}
+	if u.Scheme == "https" {
+		return u.Host, "443", nil
+	}
+	return u.Host, "80", nil
+}
@@ -0,0 +1,36 @@
+package resolver
+
+import (
+	"context"
+	"net"
+)
+
+// wildcard relies on a wildcard DNS service such as nip.io, which resolves every name under
+// <ip>.nip.io to <ip>, so there is nothing to register.
+type wildcard struct {
+	root string
+}
+
+func (w wildcard) RootHostname() string {
+	return w.root
+}
+
+func (w wildcard) Register(hostname string) error {
+	return nil
+}
+
+func (w wildcard) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return netDialer.DialContext(ctx, network, addr)
+}
+
+func (w wildcard) CurlFlags(rawURL string) string {
+	return ""
+}
+
+func (w wildcard) AdoptRunID() error {
+	return nil
+}
+
+func (w wildcard) Cleanup() error {
+	return nil
+}
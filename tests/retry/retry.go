@@ -0,0 +1,186 @@
+package retry
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/onsi/ginkgo"
+)
+
+// The code in this package repeats an attempt at something, such as running a command or
+// requesting a URL, until it succeeds, the context is cancelled or a timeout elapses. Every
+// attempt is logged as it happens and, on failure, the whole history is summarized, so that a
+// flaky spec shows exactly what it saw and when.
+
+// Backoff describes how long to wait between attempts. The wait starts at Initial and is
+// multiplied by Factor after every attempt, up to Max. Jitter randomizes each wait by up to that
+// fraction of it, in either direction, so that parallel nodes do not retry in lockstep.
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+	Factor  float64
+	Jitter  float64
+}
+
+// DefaultBackoff is used when Options.Backoff is left empty.
+var DefaultBackoff = Backoff{
+	Initial: 500 * time.Millisecond,
+	Max:     10 * time.Second,
+	Factor:  2,
+	Jitter:  0.2,
+}
+
+var (
+	random   = rand.New(rand.NewSource(time.Now().UnixNano()))
+	randomMu sync.Mutex
+)
+
+// Delay returns the wait after the given attempt, counting from 1.
+func (b Backoff) Delay(attempt int) time.Duration {
+	delay := float64(b.Initial)
+	for i := 1; i < attempt && (b.Max <= 0 || delay < float64(b.Max)); i++ {
+		if b.Factor > 1 {
+			delay *= b.Factor
+		}
+	}
+	if b.Max > 0 && delay > float64(b.Max) {
+		delay = float64(b.Max)
+	}
+	if b.Jitter > 0 {
+		randomMu.Lock()
+		delay += delay * b.Jitter * (2*random.Float64() - 1)
+		randomMu.Unlock()
+	}
+	return time.Duration(delay)
+}
+
+// Condition makes a single attempt. It returns a short summary of what it saw, for the log, and
+// whether that was what was being waited for. An error marks the attempt as failed; it is logged
+// and retried like any other.
+type Condition func(ctx context.Context) (result string, done bool, err error)
+
+// Options configure Until.
+type Options struct {
+	// Description says what is being waited for, e.g. "`deis apps` to return my-app".
+	Description string
+	// Timeout bounds all of the attempts together. If zero, only the context does.
+	Timeout time.Duration
+	// Backoff defaults to DefaultBackoff.
+	Backoff Backoff
+	// Log receives a line per attempt and the summary; it defaults to the GinkgoWriter.
+	Log io.Writer
+}
+
+// Attempt records the outcome of a single attempt.
+type Attempt struct {
+	Number int
+	// Elapsed is the time from the start of the first attempt to the start of this one.
+	Elapsed  time.Duration
+	Duration time.Duration
+	Result   string
+	Err      error
+}
+
+func (a Attempt) String() string {
+	outcome := a.Result
+	if a.Err != nil {
+		outcome = fmt.Sprintf("error: %s", a.Err)
+	}
+	return fmt.Sprintf("attempt %d at +%s (took %s): %s",
+		a.Number, round(a.Elapsed), round(a.Duration), outcome)
+}
+
+// Error is returned by Until when no attempt succeeded.
+type Error struct {
+	Description string
+	Attempts    []Attempt
+	// Cause is why Until gave up: context.DeadlineExceeded or context.Canceled.
+	Cause error
+}
+
+func (e *Error) Error() string {
+	if len(e.Attempts) == 0 {
+		return fmt.Sprintf("gave up waiting for %s before the first attempt: %s", e.Description, e.Cause)
+	}
+	last := e.Attempts[len(e.Attempts)-1]
+	return fmt.Sprintf("gave up waiting for %s after %d attempts (%s); last %s",
+		e.Description, len(e.Attempts), e.Cause, last)
+}
+
+// Summary lists every attempt that was made.
+func (e *Error) Summary() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "FAIL: gave up waiting for %s after %d attempts (%s):\n", e.Description, len(e.Attempts), e.Cause)
+	for _, a := range e.Attempts {
+		fmt.Fprintf(&b, "    %s\n", a)
+	}
+	return b.String()
+}
+
+// Until makes attempts with cond until one succeeds, backing off between them. It returns nil on
+// success and an *Error once the context is done or the timeout elapses.
+func Until(ctx context.Context, opts Options, cond Condition) error {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	if opts.Backoff == (Backoff{}) {
+		opts.Backoff = DefaultBackoff
+	}
+	if opts.Log == nil {
+		opts.Log = ginkgo.GinkgoWriter
+	}
+
+	if opts.Timeout > 0 {
+		fmt.Fprintf(opts.Log, "Waiting up to %d seconds for %s...\n", int(opts.Timeout.Seconds()), opts.Description)
+	} else {
+		fmt.Fprintf(opts.Log, "Waiting for %s...\n", opts.Description)
+	}
+
+	var attempts []Attempt
+	start := time.Now()
+	for n := 1; ; n++ {
+		if ctx.Err() != nil {
+			return fail(opts, attempts, ctx.Err())
+		}
+		attemptStart := time.Now()
+		result, done, err := cond(ctx)
+		attempt := Attempt{
+			Number:   n,
+			Elapsed:  attemptStart.Sub(start),
+			Duration: time.Since(attemptStart),
+			Result:   result,
+			Err:      err,
+		}
+		attempts = append(attempts, attempt)
+		fmt.Fprintf(opts.Log, "    %s\n", attempt)
+		if err == nil && done {
+			return nil
+		}
+
+		timer := time.NewTimer(opts.Backoff.Delay(n))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fail(opts, attempts, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+func fail(opts Options, attempts []Attempt, cause error) error {
+	err := &Error{Description: opts.Description, Attempts: attempts, Cause: cause}
+	io.WriteString(opts.Log, err.Summary())
+	return err
+}
+
+// round trims durations to milliseconds for the log.
+func round(d time.Duration) time.Duration {
+	return d - d%time.Millisecond
+}
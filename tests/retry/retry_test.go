@@ -0,0 +1,206 @@
+package retry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fast retries almost at once, so that tests don't wait on the backoff.
+var fast = Backoff{Initial: time.Millisecond, Max: time.Millisecond, Factor: 1}
+
+func TestDelay(t *testing.T) {
+	backoff := Backoff{Initial: 100 * time.Millisecond, Max: time.Second, Factor: 2}
+	for _, test := range []struct {
+		attempt  int
+		expected time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{50, time.Second},
+	} {
+		if got := backoff.Delay(test.attempt); got != test.expected {
+			t.Errorf("attempt %d: expected a delay of %s, got %s", test.attempt, test.expected, got)
+		}
+	}
+
+	// Without a factor the delay stays put, and without a maximum it keeps growing.
+	if got := (Backoff{Initial: time.Second}).Delay(10); got != time.Second {
+		t.Errorf("expected a constant delay of 1s, got %s", got)
+	}
+	if got := (Backoff{Initial: time.Second, Factor: 3}).Delay(4); got != 27*time.Second {
+		t.Errorf("expected an unbounded delay of 27s, got %s", got)
+	}
+}
+
+func TestDelayJitter(t *testing.T) {
+	backoff := Backoff{Initial: time.Second, Max: time.Second, Factor: 2, Jitter: 0.2}
+	min, max := 800*time.Millisecond, 1200*time.Millisecond
+	seen := map[time.Duration]bool{}
+	for i := 0; i < 100; i++ {
+		got := backoff.Delay(3)
+		if got < min || got > max {
+			t.Fatalf("expected a delay between %s and %s, got %s", min, max, got)
+		}
+		seen[got] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("expected jitter to vary the delay, got %v", seen)
+	}
+}
+
+func TestUntil(t *testing.T) {
+	var log bytes.Buffer
+	calls := 0
+	err := Until(context.Background(), Options{Description: "the third call", Timeout: 10 * time.Second, Backoff: fast, Log: &log},
+		func(ctx context.Context) (string, bool, error) {
+			calls++
+			if calls == 1 {
+				return "", false, errors.New("connection refused")
+			}
+			return fmt.Sprintf("call %d", calls), calls == 3, nil
+		})
+	if err != nil {
+		t.Fatalf("expected to succeed, got %s", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+	for _, expected := range []string{
+		"Waiting up to 10 seconds for the third call...\n",
+		"attempt 1 at +",
+		"error: connection refused\n",
+		"): call 2\n",
+		"attempt 3 at +",
+		"): call 3\n",
+	} {
+		if !strings.Contains(log.String(), expected) {
+			t.Errorf("expected the log to contain %q:\n%s", expected, log.String())
+		}
+	}
+	if strings.Contains(log.String(), "FAIL") {
+		t.Errorf("expected no summary after a success:\n%s", log.String())
+	}
+}
+
+func TestUntilTimeout(t *testing.T) {
+	var log bytes.Buffer
+	calls := 0
+	err := Until(context.Background(), Options{Description: "never", Timeout: 50 * time.Millisecond, Backoff: fast, Log: &log},
+		func(ctx context.Context) (string, bool, error) {
+			calls++
+			return "not yet", false, nil
+		})
+	retryErr, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("expected an *Error, got %#v", err)
+	}
+	if retryErr.Cause != context.DeadlineExceeded {
+		t.Errorf("expected to give up on the deadline, got %v", retryErr.Cause)
+	}
+	if len(retryErr.Attempts) != calls || calls < 2 {
+		t.Errorf("expected every one of %d calls to be recorded, got %d", calls, len(retryErr.Attempts))
+	}
+	for i, a := range retryErr.Attempts {
+		if a.Number != i+1 || a.Result != "not yet" {
+			t.Errorf("unexpected attempt %d: %+v", i, a)
+		}
+	}
+	if !strings.HasPrefix(err.Error(), "gave up waiting for never after ") || !strings.HasSuffix(err.Error(), "): not yet") {
+		t.Errorf("unexpected error message: %s", err)
+	}
+
+	summary := retryErr.Summary()
+	if !strings.HasPrefix(summary, "FAIL: gave up waiting for never after ") {
+		t.Errorf("unexpected summary:\n%s", summary)
+	}
+	if lines := strings.Count(summary, "\n    attempt "); lines != calls {
+		t.Errorf("expected the summary to list %d attempts, got %d:\n%s", calls, lines, summary)
+	}
+	if !strings.HasSuffix(log.String(), summary) {
+		t.Errorf("expected the summary to end the log:\n%s", log.String())
+	}
+}
+
+func TestUntilCancelled(t *testing.T) {
+	var log bytes.Buffer
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := Until(ctx, Options{Description: "anything", Log: &log}, func(ctx context.Context) (string, bool, error) {
+		t.Fatal("expected no attempt once the context is done")
+		return "", true, nil
+	})
+	retryErr, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("expected an *Error, got %#v", err)
+	}
+	if retryErr.Cause != context.Canceled || len(retryErr.Attempts) != 0 {
+		t.Errorf("expected to give up before the first attempt, got %+v", retryErr)
+	}
+	if expected := "gave up waiting for anything before the first attempt: context canceled"; err.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, err)
+	}
+	if !strings.HasPrefix(log.String(), "Waiting for anything...\n") {
+		t.Errorf("expected the log not to mention a timeout:\n%s", log.String())
+	}
+}
+
+// TestUntilCancelledDuringBackoff checks that Until gives up while waiting to retry, rather than
+// sleeping out the backoff.
+func TestUntilCancelledDuringBackoff(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := Until(ctx, Options{Backoff: Backoff{Initial: time.Minute}, Log: &bytes.Buffer{}},
+		func(ctx context.Context) (string, bool, error) { return "", false, nil })
+	if err == nil {
+		t.Fatal("expected to give up")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("expected to give up as soon as the context was done, took %s", elapsed)
+	}
+}
+
+// TestUntilPassesContext checks that an attempt sees the deadline, so that it can stop a command
+// that outlives it.
+func TestUntilPassesContext(t *testing.T) {
+	err := Until(context.Background(), Options{Timeout: 50 * time.Millisecond, Log: &bytes.Buffer{}},
+		func(ctx context.Context) (string, bool, error) {
+			if _, ok := ctx.Deadline(); !ok {
+				t.Error("expected the attempt's context to have a deadline")
+			}
+			<-ctx.Done()
+			return "", false, ctx.Err()
+		})
+	retryErr, ok := err.(*Error)
+	if !ok || len(retryErr.Attempts) != 1 || retryErr.Attempts[0].Err != context.DeadlineExceeded {
+		t.Errorf("expected the one attempt to end with the deadline, got %#v", err)
+	}
+}
+
+func TestAttemptString(t *testing.T) {
+	for _, test := range []struct {
+		attempt  Attempt
+		expected string
+	}{
+		{
+			Attempt{Number: 2, Elapsed: 1500*time.Millisecond + 123*time.Microsecond, Duration: 20 * time.Millisecond, Result: "exit 0: ok"},
+			"attempt 2 at +1.5s (took 20ms): exit 0: ok",
+		},
+		{
+			Attempt{Number: 1, Result: "ignored", Err: errors.New("boom")},
+			"attempt 1 at +0s (took 0s): error: boom",
+		},
+	} {
+		if got := test.attempt.String(); got != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, got)
+		}
+	}
+}
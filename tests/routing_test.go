@@ -1,14 +1,11 @@
 package tests
 
 import (
-	"fmt"
-	"net/http"
-	"strconv"
-
 	"github.com/deis/workflow-e2e/tests/cmd"
 	"github.com/deis/workflow-e2e/tests/cmd/apps"
 	"github.com/deis/workflow-e2e/tests/cmd/auth"
 	"github.com/deis/workflow-e2e/tests/cmd/builds"
+	"github.com/deis/workflow-e2e/tests/http"
 	"github.com/deis/workflow-e2e/tests/model"
 	"github.com/deis/workflow-e2e/tests/settings"
 
@@ -54,13 +51,10 @@ var _ = Describe("deis routing", func() {
 			})
 
 			Specify("that user can view app when routing is enabled", func() {
-				cmdRetryTimeout := 60
-				curlCmd := model.Cmd{CommandLineString: fmt.Sprintf(`curl -sL -w "%%{http_code}\\n" "%s" -o /dev/null`, app.URL)}
-				Eventually(cmd.Retry(curlCmd, strconv.Itoa(http.StatusOK), cmdRetryTimeout)).Should(BeTrue())
+				http.Probe{URL: app.URL}.Until(settings.DefaultEventuallyTimeout, http.RespondWith(200))
 			})
 
 			Specify("that user can disable routing", func() {
-				cmdRetryTimeout := 60
 				sess, err := cmd.Start("deis routing:disable --app=%s", &user, app.Name)
 				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("done"))
 				Expect(err).NotTo(HaveOccurred())
@@ -71,8 +65,7 @@ var _ = Describe("deis routing", func() {
 				Expect(err).NotTo(HaveOccurred())
 				Eventually(sess).Should(Exit(0))
 
-				curlCmd := model.Cmd{CommandLineString: fmt.Sprintf(`curl -sL -w "%%{http_code}\\n" "%s" -o /dev/null`, app.URL)}
-				Eventually(cmd.Retry(curlCmd, strconv.Itoa(http.StatusNotFound), cmdRetryTimeout)).Should(BeTrue())
+				http.Probe{URL: app.URL}.Until(settings.DefaultEventuallyTimeout, http.RespondWith(404))
 			})
 		})
 	})
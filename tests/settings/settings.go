@@ -4,28 +4,73 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"time"
 
-	"github.com/deis/workflow-e2e/tests/util"
-)
-
-const (
-	DeisRootHostname = "k8s.local"
+	"github.com/deis/workflow-e2e/tests/naming"
+	"github.com/deis/workflow-e2e/tests/resolver"
 )
 
 var (
+	// DeisRootHostname is the domain under which the router serves the controller, the builder
+	// and apps. It depends on how hostnames are resolved (see tests/resolver).
+	DeisRootHostname         = resolver.RootHostname()
 	ActualHome               = os.Getenv("HOME")
 	TestHome                 string
 	TestRoot                 string
 	DeisControllerURL        string
 	DefaultEventuallyTimeout time.Duration
 	MaxEventuallyTimeout     time.Duration
-	GitSSH                   string
 	Debug                    = os.Getenv("DEBUG") != ""
+	// BuilderHost and BuilderPort, if set, are where git connects to the builder over SSH, whatever
+	// host and port the deis git remote of an app names. They are taken from DEIS_BUILDER_HOST and
+	// DEIS_BUILDER_PORT.
+	BuilderHost = os.Getenv("DEIS_BUILDER_HOST")
+	BuilderPort int
+	// SSH is the ssh executable git connects to the builder with. It is taken from E2E_SSH; if that
+	// is not set, the ssh on the $PATH is used.
+	SSH = os.Getenv("E2E_SSH")
+	// InsecureBuilderHostKey lets pushes go ahead without checking the builder's host key when it
+	// cannot be captured at the start of the suite, which otherwise fails. It is taken from
+	// E2E_INSECURE_BUILDER_HOST_KEY.
+	InsecureBuilderHostKey = os.Getenv("E2E_INSECURE_BUILDER_HOST_KEY") == "true"
+	// FixtureBaseImage is the image the sample apps built from a Dockerfile are based on (see
+	// tests/fixtures). It is taken from E2E_FIXTURE_BASE_IMAGE, so that clusters that cannot pull
+	// from Docker Hub can use a mirror.
+	FixtureBaseImage = os.Getenv("E2E_FIXTURE_BASE_IMAGE")
+	// Online makes specs also deploy the example apps on GitHub, as well as the sample apps in
+	// tests/fixtures. It is taken from E2E_ONLINE.
+	Online = os.Getenv("E2E_ONLINE") == "true"
+	// UseFakeController points the suite at an in-process fake controller (see tests/fake)
+	// instead of a Workflow install, so that the helpers can be exercised without a cluster.
+	UseFakeController = os.Getenv("DEIS_FAKE_CONTROLLER") == "true"
+	// APIFixtures makes the helpers used to set up and tear down specs (registering and cancelling
+	// users, creating apps and builds) talk to the controller's API directly instead of running
+	// `deis`. Spec bodies always use the CLI.
+	APIFixtures = os.Getenv("API_FIXTURES") == "true"
 )
 
 func init() {
-	DeisControllerURL = getControllerURL()
+	// Everything this run creates is named after the run ID (see tests/naming). It is taken from
+	// E2E_RUN_ID if that is set; otherwise the first Ginkgo node generates one and shares it with
+	// the others.
+	if runID := os.Getenv("E2E_RUN_ID"); runID == "" {
+		naming.RunID = naming.NewRunID()
+	} else if !naming.ValidRunID(runID) {
+		log.Fatalf("E2E_RUN_ID %q must match %s", runID, naming.RunIDPattern)
+	} else {
+		naming.RunID = runID
+	}
+	if port := os.Getenv("DEIS_BUILDER_PORT"); port != "" {
+		var err error
+		if BuilderPort, err = strconv.Atoi(port); err != nil || BuilderPort <= 0 || BuilderPort > 65535 {
+			log.Fatalf("DEIS_BUILDER_PORT %q must be a port number", port)
+		}
+	}
+	// When using the fake controller, DeisControllerURL is set once the fake has been started.
+	if !UseFakeController {
+		DeisControllerURL = getControllerURL()
+	}
 	defaultEventuallyTimeoutStr := os.Getenv("DEFAULT_EVENTUALLY_TIMEOUT")
 	if defaultEventuallyTimeoutStr == "" {
 		DefaultEventuallyTimeout = 60 * time.Second
@@ -48,15 +93,20 @@ func getControllerURL() string {
 		return controllerURL
 	}
 
+	// the deis CLI and ssh run in processes of their own, which cannot use an in-process resolver
+	if resolver.Mode == resolver.Dialer {
+		log.Fatalf("DEIS_RESOLVER=%s requires DEIS_CONTROLLER_URL to be set", resolver.Dialer)
+	}
+
 	// otherwise, rely on kubernetes and some DNS magic
 	host := "deis." + DeisRootHostname
-	if err := util.AddToEtcHosts(host); err != nil {
-		log.Fatalf("Could not write %s to /etc/hosts (%s)", host, err)
+	if err := resolver.Register(host); err != nil {
+		log.Fatalf("Could not resolve %s to the router (%s)", host, err)
 	}
 	// also gotta write a route for the builder
 	builderHost := "deis-builder." + DeisRootHostname
-	if err := util.AddToEtcHosts(builderHost); err != nil {
-		log.Fatalf("Could not write %s to /etc/hosts (%s)", builderHost, err)
+	if err := resolver.Register(builderHost); err != nil {
+		log.Fatalf("Could not resolve %s to the router (%s)", builderHost, err)
 	}
 
 	port := os.Getenv("DEIS_ROUTER_SERVICE_PORT")
@@ -1,14 +1,14 @@
 package tests
 
 import (
-	"regexp"
-	"strings"
+	"sort"
 
 	deis "github.com/deis/controller-sdk-go"
 	"github.com/deis/workflow-e2e/tests/cmd"
 	"github.com/deis/workflow-e2e/tests/cmd/apps"
 	"github.com/deis/workflow-e2e/tests/cmd/auth"
 	"github.com/deis/workflow-e2e/tests/cmd/builds"
+	"github.com/deis/workflow-e2e/tests/k8s"
 	"github.com/deis/workflow-e2e/tests/model"
 	"github.com/deis/workflow-e2e/tests/settings"
 	"github.com/deis/workflow-e2e/tests/util"
@@ -47,6 +47,20 @@ var _ = Describe("deis tags", func() {
 				apps.Destroy(user, app)
 			})
 
+			// nodeLabel finds a valid tag to set: the first label, by key, of the first node.
+			nodeLabel := func() []string {
+				nodes, err := k8s.NodesWithLabel("", "")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(nodes).NotTo(BeEmpty())
+				keys := make([]string, 0, len(nodes[0].Labels))
+				for key := range nodes[0].Labels {
+					keys = append(keys, key)
+				}
+				Expect(keys).NotTo(BeEmpty())
+				sort.Strings(keys)
+				return []string{keys[0], nodes[0].Labels[keys[0]]}
+			}
+
 			Specify("that user can list that app's tags", func() {
 				sess, err := cmd.Start("deis tags:list --app=%s", &user, app.Name)
 				Eventually(sess).Should(Say("=== %s Tags", app.Name))
@@ -71,19 +85,9 @@ var _ = Describe("deis tags", func() {
 			})
 
 			Specify("that user can set a valid tag", func() {
-				// Find a valid tag to set
-				// Use original $HOME dir or else kubectl can't find its config
-				sess, err := cmd.Start("HOME=%s kubectl get nodes -o jsonpath={.items[*].metadata..labels}", nil, settings.ActualHome)
-				Expect(err).NotTo(HaveOccurred())
-				Eventually(sess).Should(Exit(0))
+				label := nodeLabel()
 
-				// grep output like "map[kubernetes.io/hostname:192.168.64.2 node:worker1]"
-				re := regexp.MustCompile(`([\w\.\-]{0,253}/?[-_\.\w]{1,63}:[-_\.\w]{1,63})`)
-				pairs := re.FindAllString(string(sess.Out.Contents()), -1)
-				// Use the first key:value pair found
-				label := strings.Split(pairs[0], ":")
-
-				sess, err = cmd.Start("deis tags:set --app=%s %s=%s", &user, app.Name, label[0], label[1])
+				sess, err := cmd.Start("deis tags:set --app=%s %s=%s", &user, app.Name, label[0], label[1])
 				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Tags", app.Name))
 				Eventually(sess).Should(Say(`%s\s+%s`, label[0], label[1]))
 				Expect(err).NotTo(HaveOccurred())
@@ -101,19 +105,9 @@ var _ = Describe("deis tags", func() {
 				var label []string
 
 				BeforeEach(func() {
-					// Find a valid tag to set
-					// Use original $HOME dir or else kubectl can't find its config
-					sess, err := cmd.Start("HOME=%s kubectl get nodes -o jsonpath={.items[*].metadata..labels}", nil, settings.ActualHome)
-					Expect(err).NotTo(HaveOccurred())
-					Eventually(sess).Should(Exit(0))
-
-					// grep output like "map[kubernetes.io/hostname:192.168.64.2 node:worker1]"
-					re := regexp.MustCompile(`([\w\.\-]{0,253}/?[-_\.\w]{1,63}:[-_\.\w]{1,63})`)
-					pairs := re.FindAllString(string(sess.Out.Contents()), -1)
-					// Use the first key:value pair found
-					label = strings.Split(pairs[0], ":")
+					label = nodeLabel()
 
-					sess, err = cmd.Start("deis tags:set --app=%s %s=%s", &user, app.Name, label[0], label[1])
+					sess, err := cmd.Start("deis tags:set --app=%s %s=%s", &user, app.Name, label[0], label[1])
 					Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("=== %s Tags", app.Name))
 					Eventually(sess).Should(Say(`%s\s+%s`, label[0], label[1]))
 					Expect(err).NotTo(HaveOccurred())
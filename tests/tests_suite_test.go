@@ -1,25 +1,57 @@
 package tests
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
 	"os"
 	"os/exec"
-	"path"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/deis/workflow-e2e/tests/api"
+	"github.com/deis/workflow-e2e/tests/cmd"
 	"github.com/deis/workflow-e2e/tests/cmd/auth"
+	"github.com/deis/workflow-e2e/tests/cmd/keys"
+	"github.com/deis/workflow-e2e/tests/fake"
+	"github.com/deis/workflow-e2e/tests/model"
+	"github.com/deis/workflow-e2e/tests/naming"
+	"github.com/deis/workflow-e2e/tests/reaper"
+	"github.com/deis/workflow-e2e/tests/resolver"
 	"github.com/deis/workflow-e2e/tests/settings"
+	"github.com/deis/workflow-e2e/tests/transport"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/config"
 	"github.com/onsi/ginkgo/reporters"
 	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/ssh"
 )
 
+// suiteData is what the first Ginkgo node hands to every node once one-time setup is done.
+type suiteData struct {
+	TestHome      string
+	ControllerURL string
+	RunID         string
+	BuilderHost   string
+	BuilderPort   int
+	// BuilderHostKeyPinned is true if the builder's host key was captured (see knownHostsPath).
+	BuilderHostKeyPinned bool
+}
+
+// registry records everything this node creates, so that it can be reaped at the end of the
+// suite if a spec fails to clean up after itself.
+var registry *reaper.Registry
+
+// fakeController is only set on the first Ginkgo node, and only when settings.UseFakeController
+// is true.
+var fakeController *fake.Controller
+
+// fakeBuilder is started along with fakeController.
+var fakeBuilder *fake.Builder
+
 func init() {
 	rand.Seed(time.Now().UnixNano())
 }
@@ -29,6 +61,12 @@ func TestTests(t *testing.T) {
 
 	enableJunit := os.Getenv("JUNIT")
 	if enableJunit == "true" {
+		// Record every command this node runs alongside its JUnit report.
+		eventLogPath := filepath.Join(settings.ActualHome, fmt.Sprintf("cmd-events-%d.jsonl", GinkgoConfig.ParallelNode))
+		if err := cmd.EnableEventLog(eventLogPath); err != nil {
+			t.Fatalf("Could not open command event log %s (%s)", eventLogPath, err)
+		}
+		defer cmd.CloseEventLog()
 		junitReporter := reporters.NewJUnitReporter(filepath.Join(settings.ActualHome, fmt.Sprintf("junit-%d.xml", GinkgoConfig.ParallelNode)))
 		RunSpecsWithDefaultAndCustomReporters(t, "Deis Workflow", []Reporter{junitReporter})
 	} else {
@@ -39,6 +77,10 @@ func TestTests(t *testing.T) {
 // SynchronizedBeforeSuite will run once and only once, even when tests are parallelized. It
 // performs all the one-time setup required by the test suite.
 var _ = SynchronizedBeforeSuite(func() []byte {
+	// Everything this run creates is named after the run ID; print it so that leftovers can be
+	// found again (see cmd/workflow-e2e-cleanup).
+	fmt.Printf("Run ID: %s\n", naming.RunID)
+
 	// Verify the "deis" executable is on the $PATH
 	output, err := exec.LookPath("deis")
 	Expect(err).NotTo(HaveOccurred(), output)
@@ -52,18 +94,6 @@ var _ = SynchronizedBeforeSuite(func() []byte {
 	// Ginkgo processes.
 	os.Setenv("HOME", settings.TestHome)
 
-	// Create and install a git wrapper script. This will allow us to always specify the private
-	// key to use by means of an environment variable. This is a convenience that helps us run
-	// tests in parallel, where each test user might have its own keys.
-	sshHome := path.Join(testHome, ".ssh")
-	os.MkdirAll(sshHome, 0777)
-	settings.GitSSH = path.Join(sshHome, "git-ssh")
-	sshFlags := "-o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null"
-	if settings.Debug {
-		sshFlags = sshFlags + " -v"
-	}
-	ioutil.WriteFile(settings.GitSSH, []byte(fmt.Sprintf("#!/bin/sh\nSSH_ORIGINAL_COMMAND=\"ssh $@\"\nexec /usr/bin/ssh %s -i \"$GIT_KEY\" \"$@\"\n", sshFlags)), 0777)
-
 	// Set $HOME before we go any further. The user registration step below will need this to be
 	// set correctly in order for the profile containing the user's auth token to be written to
 	// the correct directory.
@@ -73,26 +103,83 @@ var _ = SynchronizedBeforeSuite(func() []byte {
 	// registration and we'll want this timeout set before then.
 	SetDefaultEventuallyTimeout(settings.DefaultEventuallyTimeout)
 
+	// Start the fake controller if we were asked to. It lives in this process, so it is available
+	// to all Ginkgo nodes for as long as this node is running.
+	if settings.UseFakeController {
+		fakeController = fake.NewController()
+		settings.DeisControllerURL = fakeController.URL
+		// Pushes go to a fake builder that takes the keys registered with the fake controller.
+		fakeBuilder, err = fake.NewBuilder(fakeController)
+		Expect(err).NotTo(HaveOccurred())
+		settings.BuilderHost, settings.BuilderPort = fakeBuilder.Host, fakeBuilder.Port
+	}
+
+	// Capture the builder's host key once, so that every push can check that it reaches the same
+	// builder. Pushing without checking it has to be asked for, e.g. to run only specs that never
+	// push against a cluster whose builder cannot be reached.
+	sd := suiteData{TestHome: testHome, ControllerURL: settings.DeisControllerURL, RunID: naming.RunID,
+		BuilderHost: settings.BuilderHost, BuilderPort: settings.BuilderPort}
+	hostKey, err := captureBuilderHostKey()
+	switch {
+	case err == nil:
+		Expect(transport.WriteKnownHosts(knownHostsPath(testHome), hostKey)).To(Succeed())
+		sd.BuilderHostKeyPinned = true
+	case settings.InsecureBuilderHostKey:
+		fmt.Printf("WARNING: could not capture the builder's host key, so pushes will not check it (%s)\n", err)
+	default:
+		Expect(err).NotTo(HaveOccurred(), "could not capture the builder's host key; set DEIS_BUILDER_HOST and "+
+			"DEIS_BUILDER_PORT to where it can be reached, or E2E_INSECURE_BUILDER_HOST_KEY=true to push without checking it")
+	}
+
 	// ATTEMPT to register the admin user. Since the FIRST user to regiser in a new cluster is
 	// automatically the admin, it's vitally important that this happen now. If the admin user
 	// already exists, this step will attempt to login as that user.
 	auth.RegisterAdmin()
 
-	// Return the value of testHome as bytes. Ginkgo will pass these to the function below, which
-	// will be executed on every node (like BeforeSuite would if we were using it.)
-	return []byte(testHome)
+	// Return the value of testHome, the controller URL, the run ID and where the builder is as
+	// bytes. Ginkgo will pass these to the function below, which will be executed on every node
+	// (like BeforeSuite would if we were using it.)
+	data, err := json.Marshal(sd)
+	Expect(err).NotTo(HaveOccurred())
+	return data
 }, func(data []byte) {
-	settings.TestHome = string(data)
+	var sd suiteData
+	Expect(json.Unmarshal(data, &sd)).To(Succeed())
+	settings.TestHome = sd.TestHome
+	settings.DeisControllerURL = sd.ControllerURL
+	// Every node names things after the run ID chosen by the first node, including the hostnames it
+	// registered before it knew the run ID.
+	naming.RunID = sd.RunID
+	Expect(resolver.AdoptRunID()).To(Succeed())
 
 	// Set $HOME for the benefit of all commands we will fork to execute.
 	os.Setenv("HOME", settings.TestHome)
 
-	// Derive settings.GitSSH from settings.TestHome.
-	sshHome := path.Join(settings.TestHome, ".ssh")
-	settings.GitSSH = path.Join(sshHome, "git-ssh")
+	// Push to the builder the first node found, checking its host key if the first node pinned it.
+	settings.BuilderHost, settings.BuilderPort = sd.BuilderHost, sd.BuilderPort
+	transport.Default = transport.Transport{
+		SSH:     settings.SSH,
+		Host:    settings.BuilderHost,
+		Port:    settings.BuilderPort,
+		Verbose: settings.Debug,
+	}
+	if sd.BuilderHostKeyPinned {
+		transport.Default.KnownHosts = knownHostsPath(settings.TestHome)
+	}
+
+	// Have RSA keys ready by the time the first spec needs one.
+	Expect(keys.Pregenerate(keys.RSA, 0)).To(Succeed())
 
 	// Set the defaultEventuallyTimeout for ALL Ginko nodes.
 	SetDefaultEventuallyTimeout(settings.DefaultEventuallyTimeout)
+
+	// Start recording the resources this node creates. The registry lives outside of the temporary
+	// home directory, so that anything leaked by a run that never reaches the end of the suite can
+	// still be reaped with cmd/workflow-e2e-reaper. It is named after the run ID, as other runs may
+	// share the same $HOME.
+	var err error
+	registry, err = reaper.Enable(reaper.Path(settings.ActualHome, naming.RunID, GinkgoConfig.ParallelNode))
+	Expect(err).NotTo(HaveOccurred())
 })
 
 var _ = BeforeEach(func() {
@@ -106,7 +193,44 @@ var _ = BeforeEach(func() {
 	// But note that all test users and tests still share a common $HOME!
 })
 
-var _ = SynchronizedAfterSuite(func() {}, func() {
+var _ = SynchronizedAfterSuite(func() {
+	// Delete anything this node's specs left behind, while the admin still exists to do it.
+	if registry == nil || len(registry.Resources()) == 0 {
+		return
+	}
+	admin, err := api.Login(settings.DeisControllerURL, model.Admin.Username, model.Admin.Password)
+	if err != nil {
+		fmt.Printf("WARNING: could not log in as %s to reap leaked resources (%s)\n", model.Admin.Username, err)
+		return
+	}
+	if err := registry.Reap(admin, GinkgoWriter); err != nil {
+		fmt.Printf("WARNING: %s\n", err)
+	}
+}, func() {
 	auth.CancelAdmin()
 	os.RemoveAll(settings.TestHome)
+	// Every node is done with the hostnames registered for this run.
+	if err := resolver.Cleanup(); err != nil {
+		fmt.Printf("WARNING: could not clean up hostnames registered for this run (%s)\n", err)
+	}
+	if fakeBuilder != nil {
+		fakeBuilder.Close()
+	}
+	if fakeController != nil {
+		fakeController.Close()
+	}
 })
+
+// captureBuilderHostKey returns the host key the builder presents.
+func captureBuilderHostKey() (ssh.PublicKey, error) {
+	addr, err := transport.Transport{Host: settings.BuilderHost, Port: settings.BuilderPort}.Addr(settings.DeisControllerURL)
+	if err != nil {
+		return nil, err
+	}
+	return transport.Scan(addr, 30*time.Second)
+}
+
+// knownHostsPath returns the known_hosts file the builder's host key is pinned in.
+func knownHostsPath(testHome string) string {
+	return filepath.Join(testHome, ".ssh", "known_hosts_builder")
+}
@@ -1,13 +1,11 @@
 package tests
 
 import (
-	"fmt"
-	"strconv"
-
 	"github.com/deis/workflow-e2e/tests/cmd"
 	"github.com/deis/workflow-e2e/tests/cmd/apps"
 	"github.com/deis/workflow-e2e/tests/cmd/auth"
 	"github.com/deis/workflow-e2e/tests/cmd/builds"
+	"github.com/deis/workflow-e2e/tests/http"
 	"github.com/deis/workflow-e2e/tests/model"
 	"github.com/deis/workflow-e2e/tests/settings"
 
@@ -50,19 +48,15 @@ var _ = Describe("deis tls", func() {
 				Expect(err).NotTo(HaveOccurred())
 				Eventually(sess).Should(Exit(0))
 
-				// curl the app's root URL and ensure we get a 301 redirect
-				cmdRetryTimeout := 60
-				curlCmd := model.Cmd{CommandLineString: fmt.Sprintf(`curl -sL -w "%%{http_code}\\n" "%s" -o /dev/null`, app.URL)}
-				Eventually(cmd.Retry(curlCmd, strconv.Itoa(301), cmdRetryTimeout)).Should(BeTrue())
+				// request the app's root URL and ensure we get a 301 redirect to HTTPS
+				http.Probe{URL: app.URL}.Until(settings.DefaultEventuallyTimeout, http.RedirectToHTTPS())
 
 				sess, err = cmd.Start("deis tls:disable --app=%s", &user, app.Name)
 				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("done"))
 				Expect(err).NotTo(HaveOccurred())
 				Eventually(sess).Should(Exit(0))
 
-				cmdRetryTimeout = 60
-				curlCmd = model.Cmd{CommandLineString: fmt.Sprintf(`curl -sL -w "%%{http_code}\\n" "%s" -o /dev/null`, app.URL)}
-				Eventually(cmd.Retry(curlCmd, strconv.Itoa(200), cmdRetryTimeout)).Should(BeTrue())
+				http.Probe{URL: app.URL}.Until(settings.DefaultEventuallyTimeout, http.RespondWith(200))
 			})
 		})
 	})
@@ -0,0 +1,164 @@
+// Package transport sets up how git reaches the builder over SSH.
+//
+// Every push runs git with GIT_SSH_COMMAND set to an ssh command line naming the pushing user's
+// key, so that users pushing in parallel never pick up each other's keys. Rather than turning host
+// key checking off, the builder's host key can be captured once at the start of the suite with
+// Scan and pinned with WriteKnownHosts, after which ssh refuses to push to any other host.
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deis/workflow-e2e/tests/cmd"
+	"github.com/deis/workflow-e2e/tests/resolver"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// DefaultBuilderPort is the port the builder listens on for SSH behind the router.
+const DefaultBuilderPort = 2222
+
+// HostKeyAlias is the name the builder's host key is pinned under, whatever address it is reached
+// at.
+const HostKeyAlias = "deis-builder"
+
+// Transport describes the ssh command git uses to reach the builder.
+type Transport struct {
+	// SSH is the ssh executable. If it is empty, the ssh on the $PATH is used.
+	SSH string
+	// Host and Port, if set, are where ssh connects to instead of the host and port the deis git
+	// remote names.
+	Host string
+	Port int
+	// KnownHosts is a known_hosts file that pins the builder's host key under HostKeyAlias (see
+	// WriteKnownHosts). If it is empty, the host key is not checked at all.
+	KnownHosts string
+	// Verbose makes ssh print what it is doing.
+	Verbose bool
+}
+
+// Default is the transport the git helpers use. It is set up at the start of the suite.
+var Default Transport
+
+// Command returns the ssh command line, as GIT_SSH_COMMAND expects it, that connects to the builder
+// with the private key at keyPath and no other.
+func (t Transport) Command(keyPath string) (string, error) {
+	sshPath := t.SSH
+	if sshPath == "" {
+		var err error
+		if sshPath, err = exec.LookPath("ssh"); err != nil {
+			return "", err
+		}
+	}
+	args := []string{sshPath, "-i", keyPath, "-o", "IdentitiesOnly=yes", "-o", "BatchMode=yes"}
+	// ssh takes the first value it is given for an option, so these win over the port git passes.
+	if t.Host != "" {
+		args = append(args, "-o", "HostName="+t.Host)
+	}
+	if t.Port != 0 {
+		args = append(args, "-o", "Port="+strconv.Itoa(t.Port))
+	}
+	if t.KnownHosts != "" {
+		args = append(args,
+			"-o", "HostKeyAlias="+HostKeyAlias,
+			"-o", "StrictHostKeyChecking=yes",
+			"-o", "UserKnownHostsFile="+t.KnownHosts,
+			"-o", "GlobalKnownHostsFile=/dev/null")
+	} else {
+		args = append(args, "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null")
+	}
+	if t.Verbose {
+		args = append(args, "-v")
+	}
+	for i, arg := range args {
+		args[i] = cmd.Quote(arg)
+	}
+	return strings.Join(args, " "), nil
+}
+
+// Env returns the environment variable that makes git connect to the builder with the private key
+// at keyPath.
+func (t Transport) Env(keyPath string) (string, error) {
+	command, err := t.Command(keyPath)
+	if err != nil {
+		return "", err
+	}
+	return "GIT_SSH_COMMAND=" + command, nil
+}
+
+// Addr returns the address of the builder: Host and Port if they are set, or otherwise the address
+// the deis CLI names in the git remotes of apps on the controller at controllerURL.
+func (t Transport) Addr(controllerURL string) (string, error) {
+	host, port := t.Host, t.Port
+	if host == "" {
+		u, err := url.Parse(controllerURL)
+		if err != nil {
+			return "", err
+		}
+		if u.Host == "" {
+			return "", fmt.Errorf("controller URL %q has no host", controllerURL)
+		}
+		host = u.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.Replace(host, "deis.", "deis-builder.", 1)
+	}
+	if port == 0 {
+		port = DefaultBuilderPort
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port)), nil
+}
+
+// errCaptured ends an SSH handshake once the host key has been seen.
+var errCaptured = errors.New("host key captured")
+
+// Scan connects to the SSH server at addr through tests/resolver and returns its host key, without
+// authenticating.
+func Scan(addr string, timeout time.Duration) (ssh.PublicKey, error) {
+	var hostKey ssh.PublicKey
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	config := &ssh.ClientConfig{
+		User: "git",
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			hostKey = key
+			return errCaptured
+		},
+		Timeout: timeout,
+	}
+	conn, err := resolver.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	if _, _, _, err := ssh.NewClientConn(conn, addr, config); hostKey == nil {
+		if err == nil {
+			err = fmt.Errorf("%s presented no host key", addr)
+		}
+		return nil, err
+	}
+	return hostKey, nil
+}
+
+// WriteKnownHosts writes a known_hosts file to path that pins the key under HostKeyAlias.
+func WriteKnownHosts(path string, key ssh.PublicKey) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	line := fmt.Sprintf("%s %s", HostKeyAlias, ssh.MarshalAuthorizedKey(key))
+	return ioutil.WriteFile(path, []byte(line), 0600)
+}
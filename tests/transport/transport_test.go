@@ -0,0 +1,200 @@
+package transport
+
+import (
+	"crypto/rand"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deis/workflow-e2e/tests/api"
+	"github.com/deis/workflow-e2e/tests/cmd/keys"
+	"github.com/deis/workflow-e2e/tests/fake"
+
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestCommand(t *testing.T) {
+	for _, test := range []struct {
+		transport Transport
+		expected  string
+	}{
+		{
+			Transport{SSH: "/opt/ssh"},
+			"'/opt/ssh' '-i' '/keys/it'\\''s' '-o' 'IdentitiesOnly=yes' '-o' 'BatchMode=yes' '-o' 'StrictHostKeyChecking=no' '-o' 'UserKnownHostsFile=/dev/null'",
+		},
+		{
+			Transport{SSH: "/opt/ssh", Host: "192.0.2.10", Port: 30222, KnownHosts: "/home/.ssh/known_hosts_builder", Verbose: true},
+			"'/opt/ssh' '-i' '/keys/it'\\''s' '-o' 'IdentitiesOnly=yes' '-o' 'BatchMode=yes' '-o' 'HostName=192.0.2.10' '-o' 'Port=30222' '-o' 'HostKeyAlias=deis-builder' '-o' 'StrictHostKeyChecking=yes' '-o' 'UserKnownHostsFile=/home/.ssh/known_hosts_builder' '-o' 'GlobalKnownHostsFile=/dev/null' '-v'",
+		},
+	} {
+		got, err := test.transport.Command("/keys/it's")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.expected {
+			t.Errorf("%+v: expected %s, got %s", test.transport, test.expected, got)
+		}
+	}
+
+	// Without a path, ssh is looked up on the $PATH.
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", "/nonexistent")
+	if _, err := (Transport{}).Command("key"); err == nil {
+		t.Error("expected an error when there is no ssh on the $PATH")
+	}
+}
+
+func TestAddr(t *testing.T) {
+	for _, test := range []struct {
+		transport     Transport
+		controllerURL string
+		expected      string
+	}{
+		{Transport{}, "http://deis.k8s.local", "deis-builder.k8s.local:2222"},
+		{Transport{}, "https://deis.192.0.2.10.nip.io:8443", "deis-builder.192.0.2.10.nip.io:2222"},
+		{Transport{Port: 30222}, "http://deis.k8s.local", "deis-builder.k8s.local:30222"},
+		{Transport{Host: "192.0.2.10"}, "http://deis.k8s.local", "192.0.2.10:2222"},
+		{Transport{Host: "::1", Port: 2200}, "", "[::1]:2200"},
+	} {
+		got, err := test.transport.Addr(test.controllerURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.expected {
+			t.Errorf("%+v.Addr(%q): expected %s, got %s", test.transport, test.controllerURL, test.expected, got)
+		}
+	}
+	if _, err := (Transport{}).Addr("/no/host"); err == nil {
+		t.Error("expected an error for a controller URL without a host")
+	}
+}
+
+// TestPush pushes to a fake builder named by a git remote that ssh could not otherwise reach,
+// pinning the builder's host key.
+func TestPush(t *testing.T) {
+	dir, err := ioutil.TempDir("", "transport-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	controller := fake.NewController()
+	defer controller.Close()
+	if _, err := api.NewClient(controller.URL).Register("admin", "admin", "admin@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	client, err := api.Login(controller.URL, "admin", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateApp("my-app"); err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(dir, "key")
+	writeKey(t, client, keyPath)
+	builder, err := fake.NewBuilder(controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer builder.Close()
+	impostor, err := fake.NewBuilder(controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer impostor.Close()
+
+	repo := filepath.Join(dir, "my-app")
+	git(t, "", "init", "--quiet", repo)
+	git(t, repo, "checkout", "--quiet", "-b", "master")
+	if err := ioutil.WriteFile(filepath.Join(repo, "README"), []byte("my-app\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git(t, repo, "add", "README")
+	git(t, repo, "-c", "user.name=Test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "initial")
+	git(t, repo, "remote", "add", "deis", "ssh://git@deis-builder.invalid:2222/my-app.git")
+
+	transport := Transport{Host: builder.Host, Port: builder.Port, KnownHosts: filepath.Join(dir, "known_hosts")}
+	addr, err := transport.Addr("http://deis.invalid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := Scan(addr, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(hostKey.Marshal()) != string(builder.HostKey.Marshal()) {
+		t.Fatalf("expected to capture the builder's host key %s, got %s", ssh.FingerprintSHA256(builder.HostKey), ssh.FingerprintSHA256(hostKey))
+	}
+	if err := WriteKnownHosts(transport.KnownHosts, hostKey); err != nil {
+		t.Fatal(err)
+	}
+
+	// A builder with another host key is refused before anything is pushed.
+	impostorTransport := transport
+	impostorTransport.Host, impostorTransport.Port = impostor.Host, impostor.Port
+	if output, err := push(t, impostorTransport, repo, keyPath); err == nil || !strings.Contains(output, "Host key verification failed") {
+		t.Errorf("expected pushing to a builder with another host key to fail, got %v:\n%s", err, output)
+	}
+	if got := impostor.Pushes("my-app"); got != 0 {
+		t.Errorf("expected nothing to be pushed to a builder with another host key, got %d pushes", got)
+	}
+
+	if output, err := push(t, transport, repo, keyPath); err != nil || !strings.Contains(output, "Done, my-app:v2 deployed to Workflow") {
+		t.Errorf("expected the push to succeed, got %v:\n%s", err, output)
+	}
+}
+
+func TestScanError(t *testing.T) {
+	if _, err := Scan("127.0.0.1:1", time.Second); err == nil {
+		t.Error("expected an error scanning a port nothing listens on")
+	}
+}
+
+func push(t *testing.T, transport Transport, repo, keyPath string) (string, error) {
+	env, err := transport.Env(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("git", "push", "deis", "master")
+	cmd.Dir = repo
+	cmd.Env = append(os.Environ(), env)
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}
+
+// writeKey generates a key, writes its private half to path and registers it for the client's
+// user.
+func writeKey(t *testing.T, client *api.Client, path string) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := keys.MarshalPrivateKey(private, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	sshPublic, err := ssh.NewPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateKey("key", strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPublic)))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func git(t *testing.T, dir string, args ...string) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %s: %s: %s", strings.Join(args, " "), err, output)
+	}
+}
@@ -1,13 +1,11 @@
 package tests
 
 import (
-	"fmt"
-	"strconv"
-
 	"github.com/deis/workflow-e2e/tests/cmd"
 	"github.com/deis/workflow-e2e/tests/cmd/apps"
 	"github.com/deis/workflow-e2e/tests/cmd/auth"
 	"github.com/deis/workflow-e2e/tests/cmd/builds"
+	"github.com/deis/workflow-e2e/tests/http"
 	"github.com/deis/workflow-e2e/tests/model"
 	"github.com/deis/workflow-e2e/tests/settings"
 
@@ -51,10 +49,7 @@ var _ = Describe("deis whitelist", func() {
 			})
 
 			Specify("can view app when no addresses whitelist", func() {
-				// curl the app's root URL and print just the HTTP response code
-				cmdRetryTimeout := 60
-				curlCmd := model.Cmd{CommandLineString: fmt.Sprintf(`curl -sL -w "%%{http_code}\\n" "%s" -o /dev/null`, app.URL)}
-				Eventually(cmd.Retry(curlCmd, strconv.Itoa(200), cmdRetryTimeout)).Should(BeTrue())
+				http.Probe{URL: app.URL}.Until(settings.DefaultEventuallyTimeout, http.RespondWith(200))
 			})
 
 			Specify("can add/remove addresses from the whitelist", func() {
@@ -63,29 +58,21 @@ var _ = Describe("deis whitelist", func() {
 				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("done"))
 				Eventually(sess).Should(Exit(0))
 
-				// curl the app's root URL and print just the HTTP response code
-				cmdRetryTimeout := 60
-				curlCmd := model.Cmd{CommandLineString: fmt.Sprintf(`curl -sL -w "%%{http_code}\\n" "%s" -o /dev/null`, app.URL)}
-				Eventually(cmd.Retry(curlCmd, strconv.Itoa(403), cmdRetryTimeout)).Should(BeTrue())
+				http.Probe{URL: app.URL}.Until(settings.DefaultEventuallyTimeout, http.RespondWith(403))
 
 				sess, err = cmd.Start("deis whitelist:add 0.0.0.0/0 --app=%s", &user, app.Name)
 				Expect(err).NotTo(HaveOccurred())
 				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("done"))
 				Eventually(sess).Should(Exit(0))
 
-				cmdRetryTimeout = 60
-				curlCmd = model.Cmd{CommandLineString: fmt.Sprintf(`curl -sL -w "%%{http_code}\\n" "%s" -o /dev/null`, app.URL)}
-				Eventually(cmd.Retry(curlCmd, strconv.Itoa(200), cmdRetryTimeout)).Should(BeTrue())
+				http.Probe{URL: app.URL}.Until(settings.DefaultEventuallyTimeout, http.RespondWith(200))
 
 				sess, err = cmd.Start("deis whitelist:remove 0.0.0.0/0 --app=%s", &user, app.Name)
 				Expect(err).NotTo(HaveOccurred())
 				Eventually(sess, settings.MaxEventuallyTimeout).Should(Say("done"))
 				Eventually(sess).Should(Exit(0))
 
-				// curl the app's root URL and print just the HTTP response code
-				cmdRetryTimeout = 60
-				curlCmd = model.Cmd{CommandLineString: fmt.Sprintf(`curl -sL -w "%%{http_code}\\n" "%s" -o /dev/null`, app.URL)}
-				Eventually(cmd.Retry(curlCmd, strconv.Itoa(403), cmdRetryTimeout)).Should(BeTrue())
+				http.Probe{URL: app.URL}.Until(settings.DefaultEventuallyTimeout, http.RespondWith(403))
 			})
 		})
 	})